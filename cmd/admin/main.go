@@ -0,0 +1,168 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "time"
+
+    "github.com/nbaisland/nbaisland/internal/config"
+    "github.com/nbaisland/nbaisland/internal/logger"
+    "github.com/nbaisland/nbaisland/internal/models"
+    "github.com/nbaisland/nbaisland/internal/repository"
+    "github.com/nbaisland/nbaisland/internal/service"
+)
+
+const usage = `Usage: go run cmd/admin/main.go <command>
+
+Commands:
+  season status                                   Show every season and the current one
+  season create <nba_season> [currency] [capacity] Create an upcoming season
+  season start <id>                               Grant currency, open islands
+  season end                                      Freeze trading, archive standings and positions
+  season rollover                                 End the current season and start the next NBA season
+  user promote <username>                         Make a user an admin
+  user demote <username>                          Remove a user's admin role and sign them out`
+
+func main() {
+    if len(os.Args) < 3 {
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+    if err := logger.InitLogger("dev"); err != nil {
+        log.Fatal("Failed to initialize logger:", err)
+    }
+    defer logger.Sync()
+
+    cfg := config.Load()
+    dsn := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v",
+        cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName, cfg.DBSSLMODE)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    pool, err := repository.NewDB(ctx, dsn)
+    cancel()
+    if err != nil {
+        log.Fatalf("Failed to connect to DB: %v", err)
+    }
+    defer pool.Close()
+
+    ctx = context.Background()
+    seasonService := service.NewSeasonService(&repository.PSQLSeasonRepo{Pool: pool})
+
+    switch os.Args[1] {
+    case "season":
+        runSeason(ctx, seasonService, os.Args[2:])
+    case "user":
+        users := service.NewUserService(&repository.PSQLUserRepo{Pool: pool})
+        users.Sessions = &repository.PSQLSessionRepo{Pool: pool}
+        runUser(ctx, users, os.Args[2:])
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+}
+
+func runSeason(ctx context.Context, seasons *service.SeasonService, args []string) {
+    switch args[0] {
+    case "status":
+        all, err := seasons.GetAll(ctx)
+        if err != nil {
+            log.Fatalf("Could not list seasons: %v", err)
+        }
+        for _, s := range all {
+            fmt.Printf("%d\t%s\t%s\tcurrency=%v\tcapacity=%d\n", s.ID, s.NBASeason, s.Status, s.StartingCurrency, s.IslandCapacity)
+        }
+        current, err := seasons.GetCurrent(ctx)
+        if err != nil {
+            log.Fatalf("Could not find current season: %v", err)
+        }
+        fmt.Printf("Current season: %s (%s)\n", current.NBASeason, current.Status)
+
+    case "create":
+        if len(args) < 2 {
+            fmt.Println(usage)
+            os.Exit(1)
+        }
+        currency := 10000.0
+        capacity := 20
+        if len(args) > 2 {
+            v, err := strconv.ParseFloat(args[2], 64)
+            if err != nil {
+                log.Fatalf("Invalid currency %q", args[2])
+            }
+            currency = v
+        }
+        if len(args) > 3 {
+            v, err := strconv.Atoi(args[3])
+            if err != nil {
+                log.Fatalf("Invalid capacity %q", args[3])
+            }
+            capacity = v
+        }
+        s, err := seasons.Create(ctx, args[1], currency, capacity)
+        if err != nil {
+            log.Fatalf("Could not create season: %v", err)
+        }
+        fmt.Printf("Created season %d (%s)\n", s.ID, s.NBASeason)
+
+    case "start":
+        if len(args) < 2 {
+            fmt.Println(usage)
+            os.Exit(1)
+        }
+        id, err := strconv.ParseInt(args[1], 10, 64)
+        if err != nil {
+            log.Fatalf("Invalid season id %q", args[1])
+        }
+        if err := seasons.Start(ctx, id); err != nil {
+            log.Fatalf("Could not start season: %v", err)
+        }
+        fmt.Printf("Season %d started\n", id)
+
+    case "end":
+        s, err := seasons.End(ctx)
+        if err != nil {
+            log.Fatalf("Could not end season: %v", err)
+        }
+        fmt.Printf("Season %s ended, standings archived\n", s.NBASeason)
+
+    case "rollover":
+        s, err := seasons.Rollover(ctx)
+        if err != nil {
+            log.Fatalf("Rollover failed: %v", err)
+        }
+        fmt.Printf("Rolled over to season %s\n", s.NBASeason)
+
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+}
+
+func runUser(ctx context.Context, users *service.UserService, args []string) {
+    if len(args) < 2 {
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+    var role string
+    switch args[0] {
+    case "promote":
+        role = models.RoleAdmin
+    case "demote":
+        role = models.RoleUser
+    default:
+        fmt.Println(usage)
+        os.Exit(1)
+    }
+    u, err := users.SetRole(ctx, args[1], role)
+    if err != nil {
+        log.Fatalf("Could not %s %s: %v", args[0], args[1], err)
+    }
+    if role == models.RoleUser {
+        fmt.Printf("%s is now %s; any admin sessions were revoked\n", u.Username, u.Role)
+        return
+    }
+    fmt.Printf("%s is now %s; it takes effect on their next token refresh\n", u.Username, u.Role)
+}
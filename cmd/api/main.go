@@ -2,8 +2,11 @@ package main
 
 import (
     "context"
+    "encoding/json"
+    "errors"
     "fmt"
     "log"
+    netmail "net/mail"
     "os"
     "os/signal"
     "strings"
@@ -15,11 +18,15 @@ import (
     "go.uber.org/zap"
 
     "github.com/nbaisland/nbaisland/internal/api"
+    "github.com/nbaisland/nbaisland/internal/auth"
     "github.com/nbaisland/nbaisland/internal/config"
     "github.com/nbaisland/nbaisland/internal/database"
     "github.com/nbaisland/nbaisland/internal/logger"
+    "github.com/nbaisland/nbaisland/internal/mail"
     "github.com/nbaisland/nbaisland/internal/middleware"
+    "github.com/nbaisland/nbaisland/internal/models"
     "github.com/nbaisland/nbaisland/internal/nba"
+    "github.com/nbaisland/nbaisland/internal/oidc"
     "github.com/nbaisland/nbaisland/internal/repository"
     "github.com/nbaisland/nbaisland/internal/scheduler"
     "github.com/nbaisland/nbaisland/internal/service"
@@ -46,6 +53,13 @@ func main() {
     } else {
 	    gin.SetMode(gin.DebugMode)
     }
+    jwtKeys, err := loadJWTKeys(cfg)
+    if err != nil {
+        logger.Log.Fatal("Failed to load JWT keys", zap.Error(err))
+    }
+    auth.Configure(jwtKeys)
+    logger.Log.Info("JWT keys loaded", zap.String("active_kid", jwtKeys.Active))
+
     dsn := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v",
         cfg.DBUser,
         cfg.DBPassword,
@@ -62,9 +76,10 @@ func main() {
             zap.Error(err),
         )
     }
-    ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 
     pool, err := repository.NewDB(ctx, dsn)
+    cancel()
     if err != nil {
         logger.Log.Fatal("Failed to connect to database", zap.Error(err))
     }
@@ -78,56 +93,226 @@ func main() {
     userRepo := &repository.PSQLUserRepo{Pool: pool}
     UserService := service.NewUserService(userRepo)
 
+    sessionRepo := &repository.PSQLSessionRepo{Pool: pool}
+    SessionService := service.NewSessionService(sessionRepo, userRepo, time.Duration(cfg.RefreshTokenDays)*24*time.Hour)
+
+    apiKeyRepo := &repository.PSQLAPIKeyRepo{Pool: pool}
+    APIKeyService := service.NewAPIKeyService(apiKeyRepo)
+
+    loginRepo := &repository.PSQLLoginRepo{Pool: pool}
+    LoginService := service.NewLoginService(loginRepo)
+    LoginService.Policy.MaxUserFailures = cfg.LoginMaxUserFailures
+    LoginService.Policy.MaxIPFailures = cfg.LoginMaxIPFailures
+
+    mailer, err := newMailer(cfg)
+    if err != nil {
+        logger.Log.Fatal("Failed to configure mailer", zap.Error(err))
+    }
+    accountTokenRepo := &repository.PSQLAccountTokenRepo{Pool: pool}
+    AccountService := service.NewAccountService(userRepo, accountTokenRepo, mailer, SessionService, strings.TrimRight(cfg.AppURL, "/"))
+
     playerRepo := &repository.PSQLPlayerRepo{Pool: pool}
     PlayerService := service.NewPlayerService(playerRepo)
 
     transactionRepo := &repository.PSQLTransactionRepo{Pool: pool}
     TransactionService := service.NewTransactionService(transactionRepo, playerRepo, userRepo)
+    TransactionService.RequireVerifiedEmail = cfg.RequireVerifiedEmail
+    TransactionService.Fees = service.FeeSchedule{
+        BuyPct:          cfg.FeeBuyPct,
+        SellPct:         cfg.FeeSellPct,
+        Minimum:         cfg.FeeMinimum,
+        EarlyFlipPct:    cfg.EarlyFlipPenalty,
+        EarlyFlipWindow: time.Duration(cfg.EarlyFlipDays) * 24 * time.Hour,
+    }
+    TransactionService.Holding = service.HoldingRules{
+        MinHoldPeriod: time.Duration(cfg.MinHoldHours) * time.Hour,
+        FIFOLots:      cfg.LotTracking == "fifo",
+    }
+
+    seasonRepo := &repository.PSQLSeasonRepo{Pool: pool}
+    SeasonService := service.NewSeasonService(seasonRepo)
+    TransactionService.Seasons = SeasonService
+
+    oidcProviders := make(map[string]*oidc.Provider)
+    for _, p := range cfg.OIDCProviders {
+        oidcProviders[p.Name] = oidc.NewProvider(oidc.Config{
+            Name:         p.Name,
+            IssuerURL:    p.Issuer,
+            ClientID:     p.ClientID,
+            ClientSecret: p.ClientSecret,
+            RedirectURL:  p.RedirectURL,
+            Scopes:       p.Scopes,
+        }, nil)
+        logger.Log.Info("OIDC provider configured", zap.String("provider", p.Name), zap.String("issuer", p.Issuer))
+    }
+    identityRepo := &repository.PSQLIdentityRepo{Pool: pool}
+    OIDCService := service.NewOIDCService(oidcProviders, identityRepo, userRepo, SeasonService)
+
+    leagueRepo := &repository.PSQLLeagueRepo{Pool: pool}
+    LeagueService := service.NewLeagueService(leagueRepo, SeasonService)
+    TransactionService.Leagues = leagueRepo
+
+    draftRepo := &repository.PSQLDraftRepo{Pool: pool}
+    DraftService := service.NewDraftService(draftRepo, leagueRepo, playerRepo)
+    TransactionService.Drafts = DraftService
+
+    claimRepo := &repository.PSQLClaimRepo{Pool: pool}
+    ClaimService := service.NewClaimService(claimRepo)
+    TransactionService.Claims = ClaimService
+    if _, err := ClaimService.Backfill(context.Background()); err != nil {
+        logger.Log.Error("Failed to backfill claims", zap.Error(err))
+    }
+
+    publicRepo := &repository.PSQLPublicRepo{Pool: pool}
+    PublicService := service.NewPublicService(userRepo, playerRepo, publicRepo)
+
+    leaderboardRepo := &repository.PSQLLeaderboardRepo{Pool: pool}
+    LeaderboardService := service.NewLeaderboardService(leaderboardRepo)
+
+    portfolioRepo := &repository.PSQLPortfolioRepo{Pool: pool}
+    PortfolioService := service.NewPortfolioService(portfolioRepo)
 
     priceHistoryRepo := &repository.PSQLPlayerPriceRepo{Pool: pool}
     PriceService := service.NewPriceHistoryService(priceHistoryRepo)
 
     playerMapRepo := &repository.PlayerMapRepo{Pool: pool}
 
+    eventRepo := &repository.PSQLEventRepo{Pool: pool}
+    StreamService := service.NewStreamService(eventRepo, transactionRepo)
+
+    webhookRepo := &repository.PSQLWebhookRepo{Pool: pool}
+    WebhookService := service.NewWebhookService(webhookRepo)
+    WebhookService.RequireHTTPS = cfg.ENV == "production"
+    WebhookService.AllowPrivateNetworks = cfg.WebhookAllowPrivate && cfg.ENV != "production"
+
+    notificationRepo := &repository.PSQLNotificationRepo{Pool: pool}
+    NotificationService := service.NewNotificationService(notificationRepo, mailer, strings.TrimRight(cfg.AppURL, "/"))
+
+    alertRepo := &repository.PSQLAlertRepo{Pool: pool}
+    AlertService := service.NewAlertService(alertRepo, playerRepo, NotificationService)
+
+    DividendService := service.NewDividendService(&repository.PSQLDividendRepo{Pool: pool})
+    DividendService.PerStat = cfg.DividendPerStat
+
     valueService := service.NewValueService(playerRepo, nbaRepo, playerMapRepo)
+    StreamService.Watch(models.EventPriceChanged, AlertService.PriceChanged)
     HealthService := service.NewHealthService(pool)
 
-    AuthHandler := &api.AuthHandler{UserService: UserService}
+    AuthHandler := &api.AuthHandler{UserService: UserService, SeasonService: SeasonService, SessionService: SessionService, LoginService: LoginService, AccountService: AccountService}
+    apiKeyHandler := &api.APIKeyHandler{APIKeyService: APIKeyService}
+    oidcHandler := &api.OIDCHandler{OIDCService: OIDCService, SessionService: SessionService}
     userHandler := &api.UserHandler{UserService: UserService}
     playerHandler := &api.PlayerHandler{PlayerService: PlayerService}
     transactionHandler := &api.TransactionHandler{TransactionService: TransactionService}
     healthHandler := &api.HealthHandler{HealthService: HealthService}
     priceHistoryHandler := &api.PriceHistoryHandler{PriceHistoryService: PriceService}
+    claimHandler := &api.ClaimHandler{ClaimService: ClaimService}
+    publicHandler := &api.PublicHandler{PublicService: PublicService}
+    seasonHandler := &api.SeasonHandler{SeasonService: SeasonService}
+    leaderboardHandler := &api.LeaderboardHandler{LeaderboardService: LeaderboardService}
+    portfolioHandler := &api.PortfolioHandler{PortfolioService: PortfolioService}
+    leagueHandler := &api.LeagueHandler{LeagueService: LeagueService, LeaderboardService: LeaderboardService}
+    draftHandler := &api.DraftHandler{DraftService: DraftService}
+    streamHandler := &api.StreamHandler{StreamService: StreamService}
+    webhookHandler := &api.WebhookHandler{WebhookService: WebhookService}
+    alertHandler := &api.AlertHandler{AlertService: AlertService}
+    notificationHandler := &api.NotificationHandler{NotificationService: NotificationService}
 
     // #TODO: NBA Handler (admin only features).. scores etc
 
     sched := scheduler.New()
+    adminHandler := &api.AdminHandler{Scheduler: sched, LoginService: LoginService}
 
     sched.AddWeekly("Weekly Dividend", 4, 0, func(ctx context.Context) error {
         logger.Log.Info("Running scheduled weekly NBA stats update")
-        return nbaService.UpdateAllWeeklyStats(ctx, "2025-26")
+        season, err := SeasonService.CurrentNBASeason(ctx)
+        if err != nil {
+            return err
+        }
+        if err := nbaService.UpdateAllWeeklyStats(ctx, season); err != nil {
+            publishIngestionFailed(ctx, StreamService, "Weekly Dividend", err)
+            return err
+        }
+        return DividendService.PayWeekly(ctx)
     })
 
     sched.AddNightly("Season Stats", 2, 0, func(ctx context.Context) error {
         logger.Log.Info("Running scheduled season NBA stats update")
-        return nbaService.UpdateAllSeasonStats(ctx, "2025-26")
+        season, err := SeasonService.CurrentNBASeason(ctx)
+        if err != nil {
+            return err
+        }
+        if err := nbaService.UpdateAllSeasonStats(ctx, season); err != nil {
+            publishIngestionFailed(ctx, StreamService, "Season Stats", err)
+            return err
+        }
+        return nil
     })
 
     sched.AddNightly("Daily Update", 2, 40, func(ctx context.Context) error {
         logger.Log.Info("Daily Value Update")
-        return valueService.UpdateValueForAllPlayers(ctx, "2025-26")
+        season, err := SeasonService.CurrentNBASeason(ctx)
+        if err != nil {
+            return err
+        }
+        return valueService.UpdateValueForAllPlayers(ctx, season)
+    })
+
+    // Snapshots record the values Daily Update has just set, so they run
+    // once it finishes rather than at a clock time that assumes it has.
+    sched.AddAfter("Portfolio Snapshot", "Daily Update", func(ctx context.Context) error {
+        logger.Log.Info("Snapshotting portfolios")
+        return PortfolioService.SnapshotAll(ctx)
+    })
+
+    sched.AddAfter("Leaderboard Snapshot", "Daily Update", func(ctx context.Context) error {
+        logger.Log.Info("Snapshotting leaderboards")
+        return LeaderboardService.SnapshotAll(ctx)
     })
 
+    sched.AddNightly("Session Cleanup", 4, 30, func(ctx context.Context) error {
+        if err := SessionService.Cleanup(ctx); err != nil {
+            return err
+        }
+        if err := LoginService.Cleanup(ctx); err != nil {
+            return err
+        }
+        if err := AccountService.Cleanup(ctx); err != nil {
+            return err
+        }
+        if err := OIDCService.Cleanup(ctx); err != nil {
+            return err
+        }
+        if err := WebhookService.PruneDeliveries(ctx, 30*24*time.Hour); err != nil {
+            return err
+        }
+        return NotificationService.Cleanup(ctx, 90*24*time.Hour)
+    })
+
+    sched.AddInterval("Draft Auto-Pick", 10*time.Second, func(ctx context.Context) error {
+        return DraftService.AutoPickExpired(ctx)
+    })
+
+    sched.AddInterval("Webhook Deliveries", 5*time.Second, WebhookService.DeliverDue)
+
     appCtx, appCancel := context.WithCancel(context.Background())
     defer appCancel()
 
     sched.Start(appCtx)
+    go StreamService.Run(appCtx)
+    go AlertService.Run(appCtx)
 
     r := gin.New()
+    // ClientIP keys the login throttle, so X-Forwarded-For is only believed
+    // from configured proxies.
+    if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+        logger.Log.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+    }
 
     r.Use(gin.Recovery())
     r.Use(middleware.RequestIDMiddleware()) 
     r.Use(middleware.LoggingMiddleware())
+    r.Use(middleware.ErrorHandler())
     allowedOrigins := []string{"http://localhost:3000"}
     if cfg.CORSOrigin != "" {
         envOrigins := strings.Split(cfg.CORSOrigin, ",")
@@ -139,49 +324,36 @@ func main() {
     r.Use(cors.New(cors.Config{
         AllowOrigins:     allowedOrigins,
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-        AllowHeaders:     []string{"Content-Type", "Authorization"},
+        AllowHeaders:     []string{"Content-Type", "Authorization", "X-API-Key"},
         ExposeHeaders:    []string{"Content-Length"},
         AllowCredentials: true,
         MaxAge:           12 * time.Hour,
     }))
 
-    r.GET("/health", healthHandler.CheckHealth)
-    r.GET("/ready", healthHandler.CheckReady)
-
-    r.POST("/auth/register", AuthHandler.Register)
-    r.POST("/auth/login", AuthHandler.Login)
-
-    api := r.Group("/api")
-    api.Use(middleware.AuthMiddleware())
-    {
-        api.GET("/users", userHandler.GetUsers)
-        api.GET("/users/:id", userHandler.GetUserByID)
-        api.GET("/users/username/:username", userHandler.GetUserByUsername)
-
-        api.GET("/players", playerHandler.GetPlayersByID)
-        api.GET("/players/:id", playerHandler.GetPlayerByID)
-        api.GET("/players/name/:slug", playerHandler.GetPlayerBySlug)
-        api.GET("/players/:id/price-history", priceHistoryHandler.GetPlayerPriceHistory)
-        api.GET("/auth/me", AuthHandler.GetCurrentUser)
-        // api.POST("/auth/logout", AuthHandler.Logout)
-
-        api.DELETE("/users/:id", userHandler.DeleteUser)
-
-        api.POST("/players", playerHandler.CreatePlayer)
-        api.DELETE("/players/:id", playerHandler.DeletePlayer)
-
-        api.GET("/transactions", transactionHandler.GetTransactions)
-        api.POST("/transactions/buy", transactionHandler.BuyTransaction)
-        api.POST("/transactions/sell", transactionHandler.SellTransaction)
-        api.GET("/transactions/:id", transactionHandler.GetTransactionByID)
-
-        api.GET("/positions", transactionHandler.GetPositions)
-
-        api.GET("/users/:id/transactions", transactionHandler.GetTransactionsOfUser)
-        api.GET("/users/:id/positions", transactionHandler.GetPositionsOfUser)
-        api.GET("/players/:id/transactions", transactionHandler.GetTransactionsOfPlayer)
-        api.GET("/players/:id/positions", transactionHandler.GetPositionsOfPlayer)
-    }
+    api.RegisterRoutes(r, &api.Handlers{
+        SessionService: SessionService,
+        APIKeyService:  APIKeyService,
+        Auth:           AuthHandler,
+        APIKey:         apiKeyHandler,
+        OIDC:           oidcHandler,
+        User:           userHandler,
+        Player:         playerHandler,
+        Transaction:    transactionHandler,
+        Health:         healthHandler,
+        PriceHistory:   priceHistoryHandler,
+        Claim:          claimHandler,
+        Public:         publicHandler,
+        Season:         seasonHandler,
+        Leaderboard:    leaderboardHandler,
+        Portfolio:      portfolioHandler,
+        League:         leagueHandler,
+        Draft:          draftHandler,
+        Admin:          adminHandler,
+        Stream:         streamHandler,
+        Webhook:        webhookHandler,
+        Alert:          alertHandler,
+        Notification:   notificationHandler,
+    })
 
     go func() {
         if err := r.Run(":8080"); err != nil {
@@ -197,4 +369,56 @@ func main() {
     logger.Log.Info("Shutting down server")
 
     appCancel()
-}
\ No newline at end of file
+}
+// publishIngestionFailed tells admins' webhooks that a stats import failed.
+func publishIngestionFailed(ctx context.Context, streams *service.StreamService, job string, jobErr error) {
+    data, _ := json.Marshal(map[string]string{"job": job, "error": jobErr.Error()})
+    err := streams.Publish(context.WithoutCancel(ctx), &models.MarketEvent{Type: models.EventIngestionFailed, Data: data})
+    if err != nil {
+        logger.Log.Error("Failed to publish ingestion failure", zap.String("job", job), zap.Error(err))
+    }
+}
+
+// loadJWTKeys reads the signing keys from config. Production refuses to start
+// without them; elsewhere a throwaway key is generated.
+func loadJWTKeys(cfg *config.Config) (*auth.KeySet, error) {
+    ttl := time.Duration(cfg.AccessTokenMinutes) * time.Minute
+    if cfg.JWTKeys == "" {
+        if cfg.ENV == "production" {
+            return nil, errors.New("JWT_KEYS must be set in production")
+        }
+        logger.Log.Warn("JWT_KEYS not set, using an ephemeral development key; tokens will not survive a restart")
+        return auth.EphemeralKeySet(cfg.JWTIssuer, cfg.JWTAudience, ttl)
+    }
+    keys, err := auth.ParseKeySpecs(cfg.JWTKeys)
+    if err != nil {
+        return nil, err
+    }
+    return auth.NewKeySet(keys, cfg.JWTActiveKID, cfg.JWTIssuer, cfg.JWTAudience, ttl)
+}
+
+// newMailer picks the mailer from config. Production must use SMTP.
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+    switch cfg.Mailer {
+    case "smtp":
+        if cfg.SMTPHost == "" {
+            return nil, errors.New("SMTP_HOST must be set when MAILER=smtp")
+        }
+        if _, err := netmail.ParseAddress(cfg.MailFrom); err != nil {
+            return nil, fmt.Errorf("MAIL_FROM %q is not a valid address: %w", cfg.MailFrom, err)
+        }
+        return &mail.SMTPMailer{
+            Host:     cfg.SMTPHost,
+            Port:     cfg.SMTPPort,
+            Username: cfg.SMTPUser,
+            Password: cfg.SMTPPassword,
+            From:     cfg.MailFrom,
+        }, nil
+    case "log":
+        if cfg.ENV == "production" {
+            return nil, errors.New("MAILER=log is not allowed in production")
+        }
+        return &mail.LogMailer{From: cfg.MailFrom, Dir: cfg.MailDir}, nil
+    }
+    return nil, fmt.Errorf("unknown MAILER %q, want smtp or log", cfg.Mailer)
+}
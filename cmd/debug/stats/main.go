@@ -9,6 +9,7 @@ import (
     "github.com/nbaisland/nbaisland/internal/config"
     "github.com/nbaisland/nbaisland/internal/nba"
     "github.com/nbaisland/nbaisland/internal/repository"
+    "github.com/nbaisland/nbaisland/internal/service"
 )
 
 func main() {
@@ -34,8 +35,14 @@ func main() {
     nbaService := nba.NewNBAService(nbaClient, nbaRepo, pool)
 
     ctx = context.Background()
+
+    seasonService := service.NewSeasonService(&repository.PSQLSeasonRepo{Pool: pool})
+    season, err := seasonService.CurrentNBASeason(ctx)
+    if err != nil {
+        log.Fatalf("Could not determine current season: %v", err)
+    }
     
-    err = nbaService.UpdateAllSeasonStats(ctx, "2025-26")
+    err = nbaService.UpdateAllSeasonStats(ctx, season)
     if err != nil {
         log.Fatalf("Error updating player season stats: %v", err)
     }
@@ -42,7 +42,12 @@ func main() {
     valueService := service.NewValueService(playerRepo, nbaRepo, playerMapRepo)
     
     ctx = context.Background()
-    err = valueService.UpdateValueForAllPlayers(ctx, "2025-26")
+    seasonService := service.NewSeasonService(&repository.PSQLSeasonRepo{Pool: pool})
+    season, err := seasonService.CurrentNBASeason(ctx)
+    if err != nil {
+        logger.Log.Fatal("Could not determine current season:", zap.Error(err))
+    }
+    err = valueService.UpdateValueForAllPlayers(ctx, season)
     if err != nil {
         logger.Log.Fatal("Error updating player values:", zap.Error(err))
     }
@@ -9,6 +9,7 @@ import (
     "github.com/nbaisland/nbaisland/internal/config"
     "github.com/nbaisland/nbaisland/internal/nba"
     "github.com/nbaisland/nbaisland/internal/repository"
+    "github.com/nbaisland/nbaisland/internal/service"
 )
 
 func main() {
@@ -32,14 +33,20 @@ func main() {
     nbaService := nba.NewNBAService(nbaClient, nbaRepo, pool)
 
     ctx = context.Background()
+
+    seasonService := service.NewSeasonService(&repository.PSQLSeasonRepo{Pool: pool})
+    season, err := seasonService.CurrentNBASeason(ctx)
+    if err != nil {
+        log.Fatalf("Could not determine current season: %v", err)
+    }
     
     log.Println("Seeding players with 10+ games...")
-    if err := nbaService.SeedTopPlayers(ctx, "2025-26", 10); err != nil {
+    if err := nbaService.SeedTopPlayers(ctx, season, 10); err != nil {
         log.Fatalf("Seed failed: %v", err)
     }
     
     log.Println("Loading initial season stats...")
-    if err := nbaService.UpdateAllSeasonStats(ctx, "2025-26"); err != nil {
+    if err := nbaService.UpdateAllSeasonStats(ctx, season); err != nil {
         log.Fatalf("Season stats failed: %v", err)
     }
     log.Println("Loading career stats...")
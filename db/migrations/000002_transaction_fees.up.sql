@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+    ADD COLUMN fee NUMERIC(18,6) NOT NULL DEFAULT 0 CHECK (fee >= 0);
+
+CREATE INDEX idx_transactions_user_asset_timestamp
+ON transactions(user_id, asset_id, "timestamp" DESC);
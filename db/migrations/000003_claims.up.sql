@@ -0,0 +1,26 @@
+CREATE TABLE claims (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    player_id BIGINT NOT NULL,
+    transaction_id BIGINT NOT NULL,
+    rank INTEGER NOT NULL CHECK (rank > 0),
+    entry_value NUMERIC(18,6) NOT NULL,
+    claimed_at TIMESTAMPTZ NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE,
+    CONSTRAINT claims_user_player_key UNIQUE (user_id, player_id)
+);
+
+CREATE INDEX idx_claims_player_rank ON claims(player_id, rank);
+
+ALTER TABLE claims
+    ADD CONSTRAINT claims_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE claims
+    ADD CONSTRAINT claims_player_id_fkey
+    FOREIGN KEY (player_id) REFERENCES players(id);
+
+ALTER TABLE claims
+    ADD CONSTRAINT claims_transaction_id_fkey
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS listed_on_islands,
+    DROP COLUMN IF EXISTS profile_public;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN profile_public BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN listed_on_islands BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,31 @@
+DROP TABLE IF EXISTS season_positions;
+DROP TABLE IF EXISTS season_standings;
+
+DROP MATERIALIZED VIEW IF EXISTS positions_mv;
+
+CREATE MATERIALIZED VIEW positions_mv AS
+SELECT
+    user_id,
+    asset_id,
+    SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) AS quantity,
+    CASE
+        WHEN SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END) > 0
+        THEN
+            SUM(CASE WHEN type = 'BUY' THEN quantity * price ELSE 0 END)
+            / SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END)
+        ELSE 0
+    END AS average_cost
+FROM transactions
+GROUP BY user_id, asset_id
+HAVING SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) > 0;
+
+CREATE INDEX idx_positions_mv_user_asset
+ON positions_mv(user_id, asset_id);
+
+ALTER TABLE players DROP COLUMN IF EXISTS base_capacity;
+
+DROP INDEX IF EXISTS idx_transactions_season_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS season_id;
+
+DROP FUNCTION IF EXISTS current_season_id;
+DROP TABLE IF EXISTS seasons;
@@ -0,0 +1,85 @@
+CREATE TABLE seasons (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    nba_season VARCHAR(10) NOT NULL UNIQUE,
+    status VARCHAR(10) NOT NULL DEFAULT 'upcoming',
+    starting_currency NUMERIC NOT NULL DEFAULT 10000,
+    island_capacity INTEGER NOT NULL DEFAULT 20,
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    CONSTRAINT seasons_status_check CHECK (status IN ('upcoming', 'active', 'ended'))
+);
+
+CREATE UNIQUE INDEX seasons_single_active ON seasons ((true)) WHERE status = 'active';
+
+INSERT INTO seasons (nba_season, status, started_at) VALUES ('2025-26', 'active', now());
+
+-- The season trades are booked against: the newest season that has started.
+CREATE FUNCTION current_season_id() RETURNS INTEGER
+LANGUAGE sql STABLE AS $$
+    SELECT id FROM seasons WHERE status <> 'upcoming' ORDER BY id DESC LIMIT 1
+$$;
+
+ALTER TABLE transactions ADD COLUMN season_id INTEGER;
+UPDATE transactions SET season_id = current_season_id();
+ALTER TABLE transactions
+    ALTER COLUMN season_id SET DEFAULT current_season_id(),
+    ALTER COLUMN season_id SET NOT NULL;
+
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_season_id_fkey
+    FOREIGN KEY (season_id) REFERENCES seasons(id);
+
+CREATE INDEX idx_transactions_season_id ON transactions(season_id);
+
+-- The capacity each island reopens at when a season starts; NULL uses the
+-- season's island_capacity. Existing islands keep their current size:
+-- what is left plus what is held.
+ALTER TABLE players ADD COLUMN base_capacity INTEGER;
+UPDATE players p SET base_capacity = p.capacity + COALESCE((
+    SELECT SUM(CASE WHEN t.type = 'BUY' THEN t.quantity ELSE -t.quantity END)
+    FROM transactions t
+    WHERE t.asset_id = p.id
+), 0);
+
+DROP MATERIALIZED VIEW IF EXISTS positions_mv;
+
+CREATE MATERIALIZED VIEW positions_mv AS
+SELECT
+    user_id,
+    asset_id,
+    SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) AS quantity,
+    CASE
+        WHEN SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END) > 0
+        THEN
+            SUM(CASE WHEN type = 'BUY' THEN quantity * price ELSE 0 END)
+            / SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END)
+        ELSE 0
+    END AS average_cost
+FROM transactions
+WHERE season_id = current_season_id()
+GROUP BY user_id, asset_id
+HAVING SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) > 0;
+
+CREATE INDEX idx_positions_mv_user_asset
+ON positions_mv(user_id, asset_id);
+
+CREATE TABLE season_standings (
+    season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    cash NUMERIC NOT NULL,
+    position_value NUMERIC NOT NULL,
+    net_worth NUMERIC NOT NULL,
+    PRIMARY KEY (season_id, user_id)
+);
+
+CREATE TABLE season_positions (
+    season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    asset_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    quantity NUMERIC(18,6) NOT NULL,
+    average_cost NUMERIC(18,6) NOT NULL,
+    final_value NUMERIC(18,6) NOT NULL,
+    PRIMARY KEY (season_id, user_id, asset_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE leaderboard_snapshots (
+    snapshot_date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    score NUMERIC NOT NULL,
+    PRIMARY KEY (snapshot_date, kind, user_id)
+);
+
+CREATE INDEX idx_leaderboard_snapshots_kind_user
+ON leaderboard_snapshots(kind, user_id, snapshot_date DESC);
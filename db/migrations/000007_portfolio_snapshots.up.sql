@@ -0,0 +1,12 @@
+CREATE TABLE portfolio_snapshots (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    snapshot_date DATE NOT NULL,
+    cash NUMERIC(18,6) NOT NULL,
+    market_value NUMERIC(18,6) NOT NULL,
+    cost_basis NUMERIC(18,6) NOT NULL,
+    "timestamp" TIMESTAMPTZ DEFAULT now() NOT NULL,
+    PRIMARY KEY (user_id, snapshot_date)
+);
+
+CREATE INDEX idx_portfolio_snapshots_user_timestamp
+ON portfolio_snapshots(user_id, "timestamp" DESC);
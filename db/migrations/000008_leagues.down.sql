@@ -0,0 +1,35 @@
+-- League trades have no place on the global islands once leagues are gone.
+DELETE FROM transactions WHERE league_id IS NOT NULL;
+
+DELETE FROM season_positions WHERE league_id <> 0;
+ALTER TABLE season_positions DROP CONSTRAINT season_positions_pkey;
+ALTER TABLE season_positions DROP COLUMN league_id;
+ALTER TABLE season_positions ADD PRIMARY KEY (season_id, user_id, asset_id);
+
+DROP MATERIALIZED VIEW IF EXISTS positions_mv;
+
+CREATE MATERIALIZED VIEW positions_mv AS
+SELECT
+    user_id,
+    asset_id,
+    SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) AS quantity,
+    CASE
+        WHEN SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END) > 0
+        THEN
+            SUM(CASE WHEN type = 'BUY' THEN quantity * price ELSE 0 END)
+            / SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END)
+        ELSE 0
+    END AS average_cost
+FROM transactions
+WHERE season_id = current_season_id()
+GROUP BY user_id, asset_id
+HAVING SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) > 0;
+
+CREATE INDEX idx_positions_mv_user_asset
+ON positions_mv(user_id, asset_id);
+
+DROP INDEX IF EXISTS idx_transactions_league_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS league_id;
+
+DROP TABLE IF EXISTS league_members;
+DROP TABLE IF EXISTS leagues;
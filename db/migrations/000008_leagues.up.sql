@@ -0,0 +1,58 @@
+CREATE TABLE leagues (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    invite_code VARCHAR(16) NOT NULL UNIQUE,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Both NULL: members play on their global islands and the league only
+    -- ranks them. Both set: a contained game with its own wallets and islands.
+    starting_currency NUMERIC,
+    island_capacity INTEGER,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    CONSTRAINT leagues_contained_check CHECK ((starting_currency IS NULL) = (island_capacity IS NULL))
+);
+
+CREATE TABLE league_members (
+    league_id INTEGER NOT NULL REFERENCES leagues(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency NUMERIC,
+    joined_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    PRIMARY KEY (league_id, user_id)
+);
+
+CREATE INDEX idx_league_members_user_id ON league_members(user_id);
+
+ALTER TABLE transactions
+    ADD COLUMN league_id INTEGER REFERENCES leagues(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_transactions_league_id ON transactions(league_id) WHERE league_id IS NOT NULL;
+
+DROP MATERIALIZED VIEW IF EXISTS positions_mv;
+
+-- league_id is 0 for positions on the global islands.
+CREATE MATERIALIZED VIEW positions_mv AS
+SELECT
+    user_id,
+    asset_id,
+    COALESCE(league_id, 0) AS league_id,
+    SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) AS quantity,
+    CASE
+        WHEN SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END) > 0
+        THEN
+            SUM(CASE WHEN type = 'BUY' THEN quantity * price ELSE 0 END)
+            / SUM(CASE WHEN type = 'BUY' THEN quantity ELSE 0 END)
+        ELSE 0
+    END AS average_cost
+FROM transactions
+WHERE season_id = current_season_id()
+GROUP BY user_id, asset_id, COALESCE(league_id, 0)
+HAVING SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END) > 0;
+
+CREATE INDEX idx_positions_mv_user_asset
+ON positions_mv(user_id, asset_id);
+
+CREATE INDEX idx_positions_mv_league_asset
+ON positions_mv(league_id, asset_id);
+
+ALTER TABLE season_positions ADD COLUMN league_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE season_positions DROP CONSTRAINT season_positions_pkey;
+ALTER TABLE season_positions ADD PRIMARY KEY (season_id, user_id, asset_id, league_id);
@@ -0,0 +1,46 @@
+CREATE TABLE drafts (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    league_id INTEGER NOT NULL REFERENCES leagues(id) ON DELETE CASCADE,
+    season_id INTEGER NOT NULL DEFAULT current_season_id() REFERENCES seasons(id),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    rounds INTEGER NOT NULL,
+    pick_seconds INTEGER NOT NULL,
+    shares_per_pick INTEGER NOT NULL,
+    max_islands INTEGER NOT NULL,
+    -- Picks cost the player's value at pick time times this.
+    price_pct NUMERIC NOT NULL DEFAULT 1,
+    current_pick INTEGER NOT NULL DEFAULT 1,
+    pick_deadline TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    CONSTRAINT drafts_status_check CHECK (status IN ('pending', 'active', 'completed')),
+    CONSTRAINT drafts_settings_check CHECK (rounds > 0 AND pick_seconds > 0 AND shares_per_pick > 0 AND max_islands > 0 AND price_pct >= 0),
+    UNIQUE (league_id, season_id)
+);
+
+CREATE INDEX idx_drafts_active_deadline ON drafts(pick_deadline) WHERE status = 'active';
+
+-- Slot order for round one; even rounds run in reverse.
+CREATE TABLE draft_order (
+    draft_id INTEGER NOT NULL REFERENCES drafts(id) ON DELETE CASCADE,
+    slot INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (draft_id, slot),
+    UNIQUE (draft_id, user_id)
+);
+
+CREATE TABLE draft_picks (
+    draft_id INTEGER NOT NULL REFERENCES drafts(id) ON DELETE CASCADE,
+    pick_number INTEGER NOT NULL,
+    round INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- NULL when an auto-pick found nothing the user could take.
+    player_id INTEGER REFERENCES players(id),
+    quantity INTEGER NOT NULL DEFAULT 0,
+    price NUMERIC NOT NULL DEFAULT 0,
+    auto BOOLEAN NOT NULL DEFAULT false,
+    transaction_id INTEGER REFERENCES transactions(id) ON DELETE SET NULL,
+    picked_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    PRIMARY KEY (draft_id, pick_number)
+);
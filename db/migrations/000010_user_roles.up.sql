@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
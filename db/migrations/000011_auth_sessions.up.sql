@@ -0,0 +1,18 @@
+-- One row per refresh token. Refreshing marks the presented token used and
+-- issues its successor in the same family; a used token coming back means it
+-- was stolen, so the whole family is revoked.
+CREATE TABLE auth_sessions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    family_id VARCHAR(32) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_auth_sessions_family_id ON auth_sessions(family_id);
+CREATE INDEX idx_auth_sessions_user_id ON auth_sessions(user_id);
@@ -0,0 +1,22 @@
+-- Consecutive failed logins per key ("user:<name>" or "ip:<addr>"). Kept in
+-- the database so every replica sees the same counts and lockouts.
+CREATE TABLE login_throttles (
+    key VARCHAR(128) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE failed_logins (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    -- NULL when the username does not exist.
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ip VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    reason VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_failed_logins_created_at ON failed_logins(created_at);
+CREATE INDEX idx_failed_logins_username ON failed_logins(username, created_at);
@@ -0,0 +1,23 @@
+-- External OIDC identities linked to local accounts.
+CREATE TABLE user_identities (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    last_login_at TIMESTAMPTZ,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+-- In-flight sign-ins, between redirecting to the provider and its callback.
+-- user_id is set when a signed-in user is linking another identity.
+CREATE TABLE oidc_states (
+    state VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
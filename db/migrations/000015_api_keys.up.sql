@@ -0,0 +1,21 @@
+-- User-managed API keys for scripts. Only a SHA-256 hash of the key is kept;
+-- prefix is its first characters so users can tell their keys apart.
+-- window_start/window_count implement the per-minute rate limit in the
+-- database so every replica enforces the same budget.
+CREATE TABLE api_keys (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    rate_limit_per_minute INTEGER NOT NULL DEFAULT 60 CHECK (rate_limit_per_minute > 0),
+    window_start TIMESTAMPTZ NOT NULL DEFAULT now(),
+    window_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_players_capacity_id;
+DROP INDEX IF EXISTS idx_players_value_id;
+DROP INDEX IF EXISTS idx_transactions_asset_timestamp_id;
+DROP INDEX IF EXISTS idx_transactions_user_timestamp_id;
+DROP INDEX IF EXISTS idx_transactions_timestamp_id;
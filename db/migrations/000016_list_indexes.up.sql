@@ -0,0 +1,7 @@
+-- Keyset pagination orders every list by its sort column and then the
+-- primary key, so these indexes let a page start at its cursor directly.
+CREATE INDEX idx_transactions_timestamp_id ON transactions("timestamp", id);
+CREATE INDEX idx_transactions_user_timestamp_id ON transactions(user_id, "timestamp", id);
+CREATE INDEX idx_transactions_asset_timestamp_id ON transactions(asset_id, "timestamp", id);
+CREATE INDEX idx_players_value_id ON players(value, id);
+CREATE INDEX idx_players_capacity_id ON players(capacity, id);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trigger_notify_dividend ON dividend_payments;
+DROP FUNCTION IF EXISTS notify_dividend;
+DROP TABLE IF EXISTS dividend_payments;
+DROP TRIGGER IF EXISTS trigger_notify_transaction ON transactions;
+DROP FUNCTION IF EXISTS notify_transaction;
+DROP TRIGGER IF EXISTS trigger_notify_player_value_change ON players;
+DROP FUNCTION IF EXISTS notify_player_value_change;
@@ -0,0 +1,99 @@
+-- Every API replica LISTENs on market_events and relays what it hears to
+-- its stream subscribers. Notifying from triggers covers every writer: the
+-- nightly value update, intraday moves, admin edits, draft picks and the
+-- weekly dividend.
+
+-- One row per user, player and NBA week: what a holder of the player's
+-- global island was paid for the week's stats. The unique key makes paying
+-- a week twice a no-op.
+CREATE TABLE dividend_payments (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    season_id INTEGER NOT NULL REFERENCES seasons(id),
+    week_end DATE NOT NULL,
+    quantity NUMERIC(18,6) NOT NULL,
+    per_share NUMERIC(18,6) NOT NULL,
+    amount NUMERIC(18,6) NOT NULL,
+    paid_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    UNIQUE (user_id, player_id, week_end)
+);
+
+CREATE INDEX idx_dividend_payments_user ON dividend_payments(user_id, paid_at);
+
+CREATE FUNCTION notify_player_value_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF NEW.value IS DISTINCT FROM OLD.value THEN
+        PERFORM pg_notify('market_events', json_build_object(
+            'type', 'price.changed',
+            'player_id', NEW.id,
+            'at', now(),
+            'data', json_build_object(
+                'name', NEW.name,
+                'slug', NEW.slug,
+                'old_value', OLD.value,
+                'value', NEW.value
+            )
+        )::text);
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trigger_notify_player_value_change
+AFTER UPDATE OF value ON players
+FOR EACH ROW
+EXECUTE FUNCTION notify_player_value_change();
+
+CREATE FUNCTION notify_transaction() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', json_build_object(
+        'type', 'trade.executed',
+        'player_id', NEW.asset_id,
+        'user_id', NEW.user_id,
+        'league_id', COALESCE(NEW.league_id, 0),
+        'at', NEW."timestamp",
+        'data', json_build_object(
+            'id', NEW.id,
+            'type', NEW.type,
+            'quantity', NEW.quantity,
+            'price', NEW.price,
+            'fee', NEW.fee
+        )
+    )::text);
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trigger_notify_transaction
+AFTER INSERT ON transactions
+FOR EACH ROW
+EXECUTE FUNCTION notify_transaction();
+
+CREATE FUNCTION notify_dividend() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', json_build_object(
+        'type', 'dividend.paid',
+        'player_id', NEW.player_id,
+        'user_id', NEW.user_id,
+        'league_id', 0,
+        'at', NEW.paid_at,
+        'data', json_build_object(
+            'id', NEW.id,
+            'week_end', NEW.week_end,
+            'quantity', NEW.quantity,
+            'per_share', NEW.per_share,
+            'amount', NEW.amount
+        )
+    )::text);
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trigger_notify_dividend
+AFTER INSERT ON dividend_payments
+FOR EACH ROW
+EXECUTE FUNCTION notify_dividend();
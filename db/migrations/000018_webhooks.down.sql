@@ -0,0 +1,66 @@
+CREATE OR REPLACE FUNCTION notify_player_value_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF NEW.value IS DISTINCT FROM OLD.value THEN
+        PERFORM pg_notify('market_events', json_build_object(
+            'type', 'price.changed',
+            'player_id', NEW.id,
+            'at', now(),
+            'data', json_build_object(
+                'name', NEW.name,
+                'slug', NEW.slug,
+                'old_value', OLD.value,
+                'value', NEW.value
+            )
+        )::text);
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION notify_transaction() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', json_build_object(
+        'type', 'trade.executed',
+        'player_id', NEW.asset_id,
+        'user_id', NEW.user_id,
+        'league_id', COALESCE(NEW.league_id, 0),
+        'at', NEW."timestamp",
+        'data', json_build_object(
+            'id', NEW.id,
+            'type', NEW.type,
+            'quantity', NEW.quantity,
+            'price', NEW.price,
+            'fee', NEW.fee
+        )
+    )::text);
+    RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION notify_dividend() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', json_build_object(
+        'type', 'dividend.paid',
+        'player_id', NEW.player_id,
+        'user_id', NEW.user_id,
+        'league_id', 0,
+        'at', NEW.paid_at,
+        'data', json_build_object(
+            'id', NEW.id,
+            'week_end', NEW.week_end,
+            'quantity', NEW.quantity,
+            'per_share', NEW.per_share,
+            'amount', NEW.amount
+        )
+    )::text);
+    RETURN NEW;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS publish_market_event;
+DROP FUNCTION IF EXISTS enqueue_webhooks;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
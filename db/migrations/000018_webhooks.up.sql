@@ -0,0 +1,128 @@
+-- Outbound webhooks. The secret signs each payload, so unlike API keys it is
+-- kept as issued. min_trade_value, when set, limits trade.executed to trades
+-- worth at least that much.
+CREATE TABLE webhooks (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    min_trade_value NUMERIC,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+-- One row per event per webhook. Workers claim due rows by pushing
+-- locked_until forward, so replicas never send the same delivery at once.
+CREATE TABLE webhook_deliveries (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    locked_until TIMESTAMPTZ,
+    last_attempt_at TIMESTAMPTZ,
+    last_status_code INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    CONSTRAINT webhook_deliveries_status_check CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at, id);
+
+-- enqueue_webhooks queues event for every webhook that subscribes to its
+-- type and may see it: trades on the global islands or by the owner,
+-- dividends paid to the owner, and ingestion failures for admins.
+CREATE FUNCTION enqueue_webhooks(event JSONB) RETURNS void
+LANGUAGE sql AS $$
+    INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+    SELECT w.id, event->>'type', event
+    FROM webhooks w
+    JOIN users u ON u.id = w.user_id
+    WHERE (event->>'type') = ANY(w.event_types)
+      AND CASE event->>'type'
+        WHEN 'trade.executed' THEN
+            (COALESCE((event->>'league_id')::bigint, 0) = 0 OR (event->>'user_id')::bigint = w.user_id)
+            AND (w.min_trade_value IS NULL
+                 OR (event->'data'->>'quantity')::numeric * (event->'data'->>'price')::numeric >= w.min_trade_value)
+        WHEN 'dividend.paid' THEN (event->>'user_id')::bigint = w.user_id
+        WHEN 'ingestion.failed' THEN u.role = 'admin'
+        ELSE true
+      END;
+$$;
+
+-- publish_market_event sends event to stream listeners and webhooks alike.
+CREATE FUNCTION publish_market_event(event JSONB) RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', event::text);
+    PERFORM enqueue_webhooks(event);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION notify_player_value_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF NEW.value IS DISTINCT FROM OLD.value THEN
+        PERFORM publish_market_event(jsonb_build_object(
+            'type', 'price.changed',
+            'player_id', NEW.id,
+            'at', now(),
+            'data', jsonb_build_object(
+                'name', NEW.name,
+                'slug', NEW.slug,
+                'old_value', OLD.value,
+                'value', NEW.value
+            )
+        ));
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION notify_transaction() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM publish_market_event(jsonb_build_object(
+        'type', 'trade.executed',
+        'player_id', NEW.asset_id,
+        'user_id', NEW.user_id,
+        'league_id', COALESCE(NEW.league_id, 0),
+        'at', NEW."timestamp",
+        'data', jsonb_build_object(
+            'id', NEW.id,
+            'type', NEW.type,
+            'quantity', NEW.quantity,
+            'price', NEW.price,
+            'fee', NEW.fee
+        )
+    ));
+    RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION notify_dividend() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM publish_market_event(jsonb_build_object(
+        'type', 'dividend.paid',
+        'player_id', NEW.player_id,
+        'user_id', NEW.user_id,
+        'league_id', 0,
+        'at', NEW.paid_at,
+        'data', jsonb_build_object(
+            'id', NEW.id,
+            'week_end', NEW.week_end,
+            'quantity', NEW.quantity,
+            'per_share', NEW.per_share,
+            'amount', NEW.amount
+        )
+    ));
+    RETURN NEW;
+END;
+$$;
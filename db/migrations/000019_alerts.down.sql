@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION publish_market_event(event JSONB) RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', event::text);
+    PERFORM enqueue_webhooks(event);
+END;
+$$;
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS price_alerts;
+DROP TABLE IF EXISTS watchlist_items;
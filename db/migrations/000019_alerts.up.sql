@@ -0,0 +1,64 @@
+CREATE TABLE watchlist_items (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    PRIMARY KEY (user_id, player_id)
+);
+
+-- Price alerts fire when their condition becomes true: above and below
+-- compare the value to threshold; change compares the percent move since
+-- window_hours ago to threshold, either way. An alert disarms when it fires
+-- and rearms once its condition is false again, so it fires once per
+-- crossing rather than on every update.
+CREATE TABLE price_alerts (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL,
+    threshold NUMERIC NOT NULL,
+    window_hours INTEGER,
+    email BOOLEAN NOT NULL DEFAULT false,
+    armed BOOLEAN NOT NULL DEFAULT true,
+    last_triggered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    CONSTRAINT price_alerts_kind_check CHECK (kind IN ('above', 'below', 'change')),
+    CONSTRAINT price_alerts_window_check CHECK ((kind = 'change') = (window_hours IS NOT NULL))
+);
+
+CREATE INDEX idx_price_alerts_player_id ON price_alerts(player_id);
+CREATE INDEX idx_price_alerts_user_id ON price_alerts(user_id);
+
+CREATE TABLE notifications (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    player_id INTEGER REFERENCES players(id) ON DELETE SET NULL,
+    data JSONB,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, created_at, id);
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+-- Dividends reach the user's notifications the same way they reach
+-- webhooks: from the dividend.paid event each payment publishes.
+CREATE OR REPLACE FUNCTION publish_market_event(event JSONB) RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('market_events', event::text);
+    PERFORM enqueue_webhooks(event);
+    IF event->>'type' = 'dividend.paid' THEN
+        INSERT INTO notifications (user_id, type, title, body, player_id, data)
+        SELECT u.id, 'dividend', 'Dividend paid',
+               COALESCE(p.name, 'An island you hold') || ' paid you a dividend of '
+                   || round((event->'data'->>'amount')::numeric, 2) || '.',
+               p.id, event->'data'
+        FROM users u
+        LEFT JOIN players p ON p.id = (event->>'player_id')::bigint
+        WHERE u.id = (event->>'user_id')::bigint;
+    END IF;
+END;
+$$;
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/scheduler"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type AdminHandler struct {
+	Scheduler    *scheduler.Scheduler
+	LoginService *service.LoginService
+}
+
+func (h *AdminHandler) GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Scheduler.Jobs())
+}
+
+func (h *AdminHandler) RunJob(c *gin.Context) {
+	slug := c.Param("slug")
+	if err := h.Scheduler.Trigger(slug); err != nil {
+		if errors.Is(err, scheduler.ErrJobNotFound) {
+			abortWithError(c, apperror.NotFound("JOB_NOT_FOUND", err.Error()))
+			return
+		}
+		abortWithError(c, apperror.Internal(err, "Could not start job"))
+		return
+	}
+
+	logger.Log.Info("admin triggered job",
+		zap.String("job", slug),
+		zap.Any("user_id", c.Value("user_id")),
+	)
+	c.JSON(http.StatusAccepted, gin.H{"started": slug})
+}
+
+// GetFailedLogins lists recent failed logins, newest first, optionally for
+// one ?username.
+func (h *AdminHandler) GetFailedLogins(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		abortWithError(c, apperror.InvalidRequest("limit must be between 1 and 1000"))
+		return
+	}
+
+	failures, err := h.LoginService.GetFailedLogins(c.Request.Context(), c.Query("username"), limit)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load failed logins"))
+		return
+	}
+	c.JSON(http.StatusOK, failures)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type AlertHandler struct {
+	AlertService *service.AlertService
+}
+
+type WatchRequest struct {
+	PlayerID int64 `json:"player_id" binding:"required"`
+}
+
+func (h *AlertHandler) GetWatchlist(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	items, err := h.AlertService.Watchlist(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load watchlist"))
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *AlertHandler) Watch(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	var req WatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+	if err := h.AlertService.Watch(c.Request.Context(), claims.UserID, req.PlayerID); err != nil {
+		abortWithError(c, apperror.From(err, "Could not add to watchlist"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Player added to watchlist"})
+}
+
+func (h *AlertHandler) Unwatch(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	playerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid player id"))
+		return
+	}
+	if err := h.AlertService.Unwatch(c.Request.Context(), claims.UserID, playerID); err != nil {
+		abortWithError(c, apperror.From(err, "Could not remove from watchlist"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Player removed from watchlist"})
+}
+
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	alerts, err := h.AlertService.GetAlerts(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load alerts"))
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (h *AlertHandler) CreateAlert(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	var req service.CreateAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+	alert, err := h.AlertService.CreateAlert(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not create alert"))
+		return
+	}
+	c.JSON(http.StatusCreated, alert)
+}
+
+func (h *AlertHandler) DeleteAlert(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid alert id"))
+		return
+	}
+	if err := h.AlertService.DeleteAlert(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithError(c, apperror.From(err, "Could not delete alert"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alert deleted"})
+}
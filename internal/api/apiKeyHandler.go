@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type APIKeyHandler struct {
+	APIKeyService *service.APIKeyService
+}
+
+// CreatedAPIKey is returned once, at creation; Key is the only time the
+// plaintext key is shown.
+type CreatedAPIKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	keys, err := h.APIKeyService.GetByUserID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load API keys"))
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	var req service.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+
+	key, plaintext, err := h.APIKeyService.Create(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not create API key"))
+		return
+	}
+
+	logger.Log.Info("API key created",
+		zap.Int64("user_id", claims.UserID),
+		zap.Int64("key_id", key.ID),
+		zap.Strings("scopes", key.Scopes),
+	)
+	c.JSON(http.StatusCreated, CreatedAPIKey{APIKey: key, Key: plaintext})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid API key id"))
+		return
+	}
+
+	if err := h.APIKeyService.Revoke(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithError(c, apperror.From(err, "Could not revoke API key"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -1,10 +1,14 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 	"github.com/gin-gonic/gin"
     "go.uber.org/zap"
 
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/logger"
 	"github.com/nbaisland/nbaisland/internal/service"
 	"github.com/nbaisland/nbaisland/internal/auth"
@@ -15,6 +19,8 @@ type RegisterRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Email    string `json:"email"`
+	// Name is the display name; it defaults to the username.
+	Name     string `json:"name"`
 }
 
 type LoginRequest struct {
@@ -22,181 +28,345 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse carries a short-lived access token in Token and the refresh
+// token that renews it. Each refresh token works once.
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
 type AuthResponse struct {
-	Token    string `json:"token"`
+	TokenResponse
 	UserID   int64    `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 }
 
 type AuthHandler struct {
 	UserService *service.UserService
+	SeasonService *service.SeasonService
+	SessionService *service.SessionService
+	LoginService *service.LoginService
+	AccountService *service.AccountService
+}
+
+var errInvalidCredentials = apperror.Unauthenticated("INVALID_CREDENTIALS", "Invalid credentials")
+
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func requestClient(c *gin.Context) service.Client {
+	return service.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+func tokenResponse(pair *service.TokenPair) TokenResponse {
+	return TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt,
+	}
 }
 func (h *AuthHandler) Register(c *gin.Context) {
 	ctx := c.Request.Context()
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Error("Failed to Register User",
+		logger.Log.Warn("Failed to Register User",
 			zap.Error(err),
 			zap.String("handler", "Register"),
-			zap.Any("Request", req),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return
-	}
-
-	if req.Username == "" {
-		logger.Log.Debug("Blank Username",
-			zap.String("handler", "Register"),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username required"})
-		return
-	}
-	if req.Password == "" {
-		logger.Log.Debug("Blank Password",
-			zap.String("handler", "Register"),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password required"})
-		return
-	}
-	if req.Email == "" {
-		logger.Log.Debug("Blank Email",
-			zap.String("handler", "Register"),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email required"})
-		return
-	}
-
-	existingUser, _ :=  h.UserService.GetByUsername(ctx, req.Username)
-	if existingUser != nil {
-		logger.Log.Debug("Existing user already exists",
-			zap.String("handler", "Register"),
-			zap.String("Email", req.Username),
 		)
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		abortWithError(c, errInvalidRequest)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(req.Password)
+	startingCurrency, err := h.SeasonService.StartingCurrency(ctx)
 	if err != nil {
-		logger.Log.Debug("Problem hashing password",
-			zap.String("handler", "Register"),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user, password issue"})
+		abortWithError(c, apperror.Internal(err, "Error creating user"))
 		return
 	}
 
-	user := &models.User{
+	user, err := h.UserService.Register(ctx, service.RegisterInput{
 		Username: req.Username,
-		Password: hashedPassword,
+		Password: req.Password,
 		Email:    req.Email,
-		Currency: 10000.0,
+		Name:     req.Name,
+	}, startingCurrency)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Error creating user"))
+		return
 	}
 
-	if err := h.UserService.CreateUser(ctx, user); err != nil {
-		logger.Log.Error("Failed to Create User",
+	if err := h.AccountService.SendVerification(ctx, user); err != nil {
+		logger.Log.Error("Could not send verification email",
 			zap.Error(err),
 			zap.String("handler", "Register"),
-			zap.Any("User", user),
+			zap.Int64("user_id", user.ID),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
-		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Username)
+	tokens, err := h.SessionService.Start(ctx, user, requestClient(c))
 	if err != nil {
-		logger.Log.Error("Could not create a token",
-			zap.Error(err),
-			zap.String("handler", "Register"),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		abortWithError(c, apperror.Internal(err, "Error generating token"))
 		return
 	}
 
 	c.JSON(http.StatusOK, AuthResponse{
-		Token:    token,
+		TokenResponse: tokenResponse(tokens),
 		UserID:   user.ID,
 		Username: user.Username,
+		Role:     user.Role,
 	})
 }
 
+// Login answers every rejected attempt with the same 401, whether the user
+// is unknown or the password is wrong, and 429 while the username or client
+// IP is locked out after repeated failures.
 func (h *AuthHandler) Login(c *gin.Context) {
 	ctx := c.Request.Context()
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Error("Failed to Parse Login message",
+		logger.Log.Warn("Failed to Parse Login message",
 			zap.Error(err),
-			zap.String("handler", "Register"),
-			zap.Any("Request", req),
+			zap.String("handler", "Login"),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		abortWithError(c, errInvalidRequest)
 		return
 	}
+	client := requestClient(c)
+
+	if err := h.LoginService.Check(ctx, req.Username, client); err != nil {
+		var lockout *service.LockoutError
+		if errors.As(err, &lockout) {
+			h.recordFailedLogin(c, req.Username, nil, models.LoginFailureLockedOut)
+			retryAfter := int(time.Until(lockout.Until).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortWithError(c, service.ErrLoginLockedOut.WithDetail("retry_after", retryAfter))
+			return
+		}
+		abortWithError(c, apperror.Internal(err, "Error logging in"))
+		return
+	}
+
 	user, err := h.UserService.GetByUsername(ctx, req.Username)
 	if err != nil {
-		logger.Log.Debug("Could not get username",
-			zap.String("handler", "Login"),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		abortWithError(c, apperror.Internal(err, "Error logging in"))
 		return
 	}
-	if user == nil {
-		logger.Log.Debug("User doesnt exist",
-			zap.String("handler", "Login"),
-			zap.String("Username", req.Username),
-		)
-		c.JSON(http.StatusNotFound, gin.H{"error" : "User Does Not exist"})
-		return
+
+	hashedPassword := ""
+	if user != nil {
+		hashedPassword = user.Password
 	}
-	
-	if !auth.CheckPassword(user.Password, req.Password) {
-		logger.Log.Debug("Invalid Password ",
-			zap.String("handler", "Login"),
-		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if !auth.CheckPasswordOrDummy(hashedPassword, req.Password) {
+		if user == nil {
+			h.recordFailedLogin(c, req.Username, nil, models.LoginFailureUnknownUser)
+		} else {
+			h.recordFailedLogin(c, req.Username, &user.ID, models.LoginFailureBadPassword)
+		}
+		abortWithError(c, errInvalidCredentials)
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Username)
-	if err != nil {
-		logger.Log.Error("Could not create a token",
+	if err := h.LoginService.Success(ctx, req.Username); err != nil {
+		logger.Log.Error("Could not reset login throttle",
 			zap.Error(err),
 			zap.String("handler", "Login"),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+	}
+
+	tokens, err := h.SessionService.Start(ctx, user, client)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Error generating token"))
 		return
 	}
 	c.JSON(http.StatusOK, AuthResponse{
-		Token:    token,
+		TokenResponse: tokenResponse(tokens),
 		UserID:   user.ID,
 		Username: user.Username,
+		Role:     user.Role,
 	})
 }
 
+// recordFailedLogin audits a failure. A failure to record it is logged but
+// does not change the response the client gets.
+func (h *AuthHandler) recordFailedLogin(c *gin.Context, username string, userID *int64, reason string) {
+	logger.Log.Info("Failed login",
+		zap.String("handler", "Login"),
+		zap.String("username", username),
+		zap.String("reason", reason),
+		zap.String("ip", c.ClientIP()),
+	)
+	if err := h.LoginService.Failure(c.Request.Context(), username, userID, requestClient(c), reason); err != nil {
+		logger.Log.Error("Could not record failed login",
+			zap.Error(err),
+			zap.String("handler", "Login"),
+		)
+	}
+}
+
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	ctx := c.Request.Context()
-	claims, ok := c.Get("user")
+	claims, ok := currentClaims(c)
 	if !ok {
-		logger.Log.Error("Could not get Current user",
-			zap.String("handler", "GetCurrentUser"),
-			zap.Any("Claims", claims),
+		return
+	}
+
+	user, err := h.UserService.GetByID(ctx, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Error fetching user"))
+		return
+	}
+	if user == nil {
+		abortWithError(c, service.ErrUserNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		abortWithError(c, apperror.InvalidRequest("refresh_token required"))
+		return
+	}
+
+	tokens, err := h.SessionService.Refresh(ctx, req.RefreshToken, requestClient(c))
+	if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+		logger.Log.Warn("Rejected refresh token",
+			zap.Error(err),
+			zap.String("handler", "Refresh"),
+			zap.String("ip", c.ClientIP()),
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		abortWithError(c, err)
+		return
+	}
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Error refreshing session"))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// Logout revokes the session the access token was issued from. Its refresh
+// token stops working at once, and so do its access tokens.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+
+	if err := h.SessionService.Logout(c.Request.Context(), claims.SessionID); err != nil {
+		abortWithError(c, apperror.Internal(err, "Error logging out"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll revokes every session the user has, on every device.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
 		return
 	}
 
-	authClaims, ok := claims.(*auth.Claims)
+	if err := h.SessionService.LogoutAll(c.Request.Context(), claims.UserID); err != nil {
+		abortWithError(c, apperror.Internal(err, "Error logging out"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		abortWithError(c, apperror.InvalidRequest("token required"))
+		return
+	}
+
+	if err := h.AccountService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		abortWithError(c, apperror.From(err, "Error verifying email"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	claims, ok := currentClaims(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	user, err := h.UserService.GetByID(ctx, authClaims.UserID)
+	if err := h.AccountService.ResendVerification(c.Request.Context(), claims.UserID); err != nil {
+		abortWithError(c, apperror.From(err, "Error sending verification email"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword answers the same way whether or not the email has an
+// account. Requests are throttled per IP like failed logins.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		abortWithError(c, apperror.InvalidRequest("email required"))
+		return
+	}
+
+	until, err := h.LoginService.ThrottleReset(c.Request.Context(), requestClient(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		logger.Log.Error("Could not throttle password reset",
+			zap.Error(err),
+			zap.String("handler", "ForgotPassword"),
+		)
+	}
+	if !until.IsZero() {
+		retryAfter := int(time.Until(until).Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		abortWithError(c, service.ErrResetThrottled.WithDetail("retry_after", retryAfter))
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	if err := h.AccountService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		logger.Log.Error("Failed to start password reset",
+			zap.Error(err),
+			zap.String("handler", "ForgotPassword"),
+		)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If that email has an account, a reset link is on its way"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		abortWithError(c, apperror.InvalidRequest("token required"))
+		return
+	}
+	if err := h.AccountService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		abortWithError(c, apperror.From(err, "Error resetting password"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset; log in with your new password"})
 }
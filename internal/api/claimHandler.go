@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type ClaimHandler struct {
+	ClaimService *service.ClaimService
+}
+
+func (h *ClaimHandler) GetClaimsOfUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		logger.Log.Warn("invalid user id parameter",
+			zap.String("param", idStr),
+			zap.String("route", c.FullPath()),
+		)
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	claims, err := h.ClaimService.GetByUserID(ctx, id)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch claims"))
+		return
+	}
+
+	if claims == nil {
+		c.JSON(http.StatusOK, []map[string]interface{}{})
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+func (h *ClaimHandler) VerifyClaims(c *gin.Context) {
+	// claims/verify?receipt=<hash>
+	result, err := h.ClaimService.Verify(c.Request.Context(), c.Query("receipt"))
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to verify claims"))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/openapi"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// DocsHandler serves the OpenAPI document RegisterRoutes builds once every
+// route is mounted.
+type DocsHandler struct {
+	mu   sync.RWMutex
+	spec []byte
+}
+
+func (h *DocsHandler) setSpec(doc *openapi.Document) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	h.mu.Lock()
+	h.spec = raw
+	h.mu.Unlock()
+}
+
+func (h *DocsHandler) GetSpec(c *gin.Context) {
+	h.mu.RLock()
+	spec := h.spec
+	h.mu.RUnlock()
+	if spec == nil {
+		abortWithError(c, apperror.New(apperror.KindUnavailable, "SPEC_UNAVAILABLE", "The API document is not ready"))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+func (h *DocsHandler) GetUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// openAPISpec documents routes from routeDocs. Routes without an entry are
+// returned in undocumented rather than guessed at.
+func openAPISpec(routes gin.RoutesInfo) (*openapi.Document, []string) {
+	schemas := openapi.NewSchemas()
+	errorSchema := schemas.Define("Error", &openapi.Schema{
+		Type:        "object",
+		Description: "Every error response. Some codes add fields, such as retry_after on RATE_LIMITED or fields on VALIDATION_FAILED.",
+		Properties: map[string]*openapi.Schema{
+			"error":      {Type: "string", Description: "What went wrong, for people."},
+			"code":       {Type: "string", Description: "A stable UPPER_SNAKE code, for programs."},
+			"request_id": {Type: "string"},
+		},
+		Required:             []string{"error", "code"},
+		AdditionalProperties: true,
+	})
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "NBA Island API",
+			Version:     "0.1.0",
+			Description: "Lists are paged with ?limit, ?sort and ?cursor; pass next_cursor back as cursor for the next page.",
+		},
+		Tags:  docTags,
+		Paths: map[string]openapi.PathItem{},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "An access token from /auth/login, /auth/register or /auth/refresh.",
+				},
+				"apiKey": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-API-Key",
+					Description: "An API key. The read scope allows GET requests; anything else needs trade.",
+				},
+			},
+		},
+	}
+
+	var undocumented []string
+	for _, route := range routes {
+		rd, ok := routeDocs[route.Method+" "+route.Path]
+		if !ok {
+			undocumented = append(undocumented, route.Method+" "+route.Path)
+			continue
+		}
+		path, params := openapi.Path(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = openapi.PathItem{}
+		}
+		op := rd.operation(schemas, params, errorSchema)
+		op.OperationID = operationID(route.Handler)
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+	doc.Components.Schemas = schemas.Components()
+	return doc, undocumented
+}
+
+// operationID turns a handler name such as
+// ".../internal/api.(*UserHandler).GetUsers-fm" into "User.GetUsers".
+func operationID(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = strings.TrimPrefix(name, "api.")
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.NewReplacer("(*", "", ")", "", "Handler", "").Replace(name)
+}
+
+type access int
+
+const (
+	accessPublic access = iota
+	// accessUser takes an access token or an API key.
+	accessUser
+	// accessSession takes an access token only.
+	accessSession
+	accessAdmin
+)
+
+type docParam struct {
+	name     string
+	typ      string
+	desc     string
+	required bool
+	enum     []string
+}
+
+// routeDoc describes a route for the OpenAPI document. body and resp are
+// zero values of the request and response types.
+type routeDoc struct {
+	summary     string
+	description string
+	tag         string
+	access      access
+	query       []docParam
+	list        *listSpec
+	body        interface{}
+	status      int
+	resp        interface{}
+	// contentType is the response type when it is not JSON.
+	contentType string
+}
+
+func (rd routeDoc) operation(schemas *openapi.Schemas, pathParams []string, errorSchema *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:     rd.summary,
+		Description: rd.description,
+		Tags:        []string{rd.tag},
+		Responses:   map[string]*openapi.Response{},
+	}
+
+	for _, name := range pathParams {
+		schema := &openapi.Schema{Type: "string"}
+		if name == "id" {
+			schema = &openapi.Schema{Type: "integer", Format: "int64"}
+		}
+		op.Parameters = append(op.Parameters, openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	query := rd.query
+	if rd.list != nil {
+		query = append(query, rd.list.params()...)
+	}
+	for _, p := range query {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        p.name,
+			In:          "query",
+			Description: p.desc,
+			Required:    p.required,
+			Schema:      paramSchema(p),
+		})
+	}
+
+	if rd.body != nil {
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(schemas.For(rd.body))}
+	}
+
+	status := rd.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &openapi.Response{Description: http.StatusText(status)}
+	switch {
+	case rd.contentType != "":
+		resp.Content = map[string]openapi.MediaType{rd.contentType: {Schema: &openapi.Schema{Type: "string"}}}
+	case rd.resp != nil:
+		resp.Content = openapi.JSON(schemas.For(rd.resp))
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+
+	errorResponse := func(description string) *openapi.Response {
+		return &openapi.Response{Description: description, Content: openapi.JSON(errorSchema)}
+	}
+	switch rd.access {
+	case accessUser:
+		op.Security = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+	case accessSession:
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+	case accessAdmin:
+		op.Security = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+		op.Description = strings.TrimSpace(op.Description + " Requires the admin role.")
+	}
+	if rd.access != accessPublic {
+		op.Responses["401"] = errorResponse("Missing or invalid credentials")
+	}
+	op.Responses["default"] = errorResponse("Error")
+	return op
+}
+
+func paramSchema(p docParam) *openapi.Schema {
+	switch p.typ {
+	case "integer":
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case "number":
+		return &openapi.Schema{Type: "number", Format: "double"}
+	case "date-time":
+		return &openapi.Schema{Type: "string", Format: "date-time"}
+	case "boolean":
+		return &openapi.Schema{Type: "boolean"}
+	}
+	return &openapi.Schema{Type: "string", Enum: p.enum}
+}
+
+// params documents the paging parameters parsePage reads.
+func (s listSpec) params() []docParam {
+	sorts := make([]string, 0, 2*len(s.sorts))
+	for _, sort := range s.sorts {
+		sorts = append(sorts, sort, "-"+sort)
+	}
+	def := s.defaultSort
+	if s.defaultDesc {
+		def = "-" + def
+	}
+	return []docParam{
+		{name: "limit", typ: "integer", desc: "Page size, 1 to " + strconv.Itoa(maxPageLimit) + "; defaults to " + strconv.Itoa(defaultPageLimit) + "."},
+		{name: "sort", typ: "string", desc: "Sort column, descending with a leading -; defaults to " + def + ".", enum: sorts},
+		{name: "cursor", typ: "string", desc: "next_cursor of the previous page, requested with the same sort."},
+	}
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/openapi"
+	"github.com/nbaisland/nbaisland/internal/scheduler"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+var docTags = []openapi.Tag{
+	{Name: "System", Description: "Health checks and this document."},
+	{Name: "Auth", Description: "Accounts, sessions and sign-in providers."},
+	{Name: "Users"},
+	{Name: "Players", Description: "NBA players and their islands."},
+	{Name: "Trading", Description: "Buying, selling and the resulting positions."},
+	{Name: "Claims", Description: "Hash-chained records of each user's first buy of a player."},
+	{Name: "Seasons"},
+	{Name: "Leaderboards"},
+	{Name: "Leagues", Description: "Private leagues and their drafts."},
+	{Name: "Alerts", Description: "Watchlists, price alerts and the notifications they send."},
+	{Name: "API keys"},
+	{Name: "Webhooks", Description: "Signed HTTP callbacks for market events."},
+	{Name: "Public", Description: "Pages anyone can see without signing in."},
+	{Name: "Admin"},
+}
+
+// Shapes handlers build with gin.H.
+type (
+	messageResponse struct {
+		Message string `json:"message"`
+	}
+	statusResponse struct {
+		Status string `json:"status"`
+	}
+	authorizationURLResponse struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	unreadCountResponse struct {
+		Unread int `json:"unread"`
+	}
+	markedResponse struct {
+		Marked int64 `json:"marked"`
+	}
+	sellResponse struct {
+		Proceeds    float64             `json:"proceeds"`
+		Fee         float64             `json:"fee"`
+		Transaction *models.Transaction `json:"transaction"`
+	}
+)
+
+var (
+	docLeagueIDParam    = docParam{name: "league_id", typ: "integer", desc: "Only this league's islands; 0 is the global islands. A league is visible only to its members and admins."}
+	docUserIDParam      = docParam{name: "user_id", typ: "integer", desc: "Only this user."}
+	docPlayerIDParam    = docParam{name: "player_id", typ: "integer", desc: "Only this player."}
+	docTypeParam        = docParam{name: "type", typ: "string", enum: []string{"BUY", "SELL"}}
+	docFromParam        = docParam{name: "from", typ: "date-time", desc: "RFC 3339 time or YYYY-MM-DD date."}
+	docToParam          = docParam{name: "to", typ: "date-time", desc: "RFC 3339 time or YYYY-MM-DD date."}
+	docRangeParam       = docParam{name: "range", typ: "string", desc: "How far back to go; defaults to 30d.", enum: []string{"7d", "30d", "90d", "1y", "all"}}
+	docHistoryFromParam = docParam{name: "from", typ: "date-time", desc: "Start instead of range; RFC 3339 time or YYYY-MM-DD date. Defaults to the first record."}
+	docHistoryToParam   = docParam{name: "to", typ: "date-time", desc: "End instead of range; RFC 3339 time or YYYY-MM-DD date. Defaults to now."}
+	docPageParams       = []docParam{
+		{name: "page", typ: "integer", desc: "1-based page; defaults to 1."},
+		{name: "limit", typ: "integer", desc: "Entries per page; defaults to 25."},
+	}
+)
+
+// routeDocs documents every route RegisterRoutes mounts, keyed by method
+// and gin path.
+var routeDocs = map[string]routeDoc{
+	"GET /openapi.json": {summary: "This OpenAPI document", tag: "System", resp: map[string]interface{}{}},
+	"GET /docs":         {summary: "Browse this document", tag: "System", contentType: "text/html"},
+	"GET /health":       {summary: "Liveness check", tag: "System", resp: statusResponse{}},
+	"GET /ready":        {summary: "Readiness check, including the database", tag: "System", resp: statusResponse{}},
+
+	"POST /auth/register": {summary: "Create an account and sign in", tag: "Auth", body: RegisterRequest{}, resp: AuthResponse{}},
+	"POST /auth/login":    {summary: "Sign in with a username and password", tag: "Auth", body: LoginRequest{}, resp: AuthResponse{}},
+	"POST /auth/refresh": {
+		summary:     "Exchange a refresh token for new tokens",
+		description: "Each refresh token works once; reusing one revokes the session.",
+		tag:         "Auth", body: RefreshRequest{}, resp: TokenResponse{},
+	},
+	"POST /auth/logout":          {summary: "End this session", tag: "Auth", access: accessSession, resp: messageResponse{}},
+	"POST /auth/logout-all":      {summary: "End every session of this user", tag: "Auth", access: accessSession, resp: messageResponse{}},
+	"POST /auth/email/verify":    {summary: "Verify an email address with the emailed token", tag: "Auth", body: TokenRequest{}, resp: messageResponse{}},
+	"POST /auth/password/forgot": {
+		summary:     "Email a password reset link",
+		description: "An account is mailed at most one link every five minutes, and each IP is throttled like failed logins.",
+		tag:         "Auth", body: ForgotPasswordRequest{}, status: http.StatusAccepted, resp: messageResponse{},
+	},
+	"POST /auth/password/reset":  {summary: "Set a new password with the emailed token", tag: "Auth", body: ResetPasswordRequest{}, resp: messageResponse{}},
+	"GET /auth/oidc/providers": {summary: "Configured sign-in providers", tag: "Auth", resp: struct {
+		Providers []string `json:"providers"`
+	}{}},
+	"POST /auth/oidc/:provider/start":    {summary: "Start signing in with a provider", tag: "Auth", resp: authorizationURLResponse{}},
+	"POST /auth/oidc/:provider/callback": {summary: "Finish signing in with a provider", tag: "Auth", body: OIDCCallbackRequest{}, resp: AuthResponse{}},
+	"GET /api/auth/me":                   {summary: "The signed-in user", tag: "Auth", access: accessUser, resp: models.User{}},
+	"POST /api/auth/email/resend":        {summary: "Resend the verification email", tag: "Auth", access: accessSession, status: http.StatusAccepted, resp: messageResponse{}},
+	"GET /api/auth/identities":           {summary: "Provider identities linked to the signed-in user", tag: "Auth", access: accessUser, resp: []*models.UserIdentity{}},
+	"POST /api/auth/oidc/:provider/link": {summary: "Start linking a provider identity to the signed-in user", tag: "Auth", access: accessSession, resp: authorizationURLResponse{}},
+	"POST /api/auth/oidc/:provider/link/callback": {
+		summary:     "Finish linking a provider identity",
+		description: "Takes the code and state from the provider's redirect after a link started by the same user. Link states are rejected on the sign-in callback.",
+		tag:         "Auth", access: accessSession, body: OIDCCallbackRequest{}, resp: []*models.UserIdentity{},
+	},
+
+	"GET /claims/verify": {
+		summary: "Verify the claim chain",
+		tag:     "Claims",
+		query:   []docParam{{name: "receipt", typ: "string", desc: "A claim hash to look up while verifying."}},
+		resp:    models.ClaimVerification{},
+	},
+	"GET /api/users/:id/claims": {summary: "A user's claims", tag: "Claims", access: accessUser, resp: []*models.Claim{}},
+
+	"GET /public/users/:username":      {summary: "A user's public profile", tag: "Public", resp: models.PublicProfile{}},
+	"GET /public/players/:slug":        {summary: "A player's island: top holders and earliest claimers", tag: "Public", resp: models.PlayerIsland{}},
+	"GET /public/claims/:id/badge.svg": {summary: "A claim badge", tag: "Public", contentType: "image/svg+xml"},
+
+	"GET /api/users": {
+		summary: "List users",
+		tag:     "Users",
+		access:  accessUser,
+		query:   []docParam{{name: "q", typ: "string", desc: "Part of a username or name."}},
+		list:    &userListSpec,
+		resp:    models.Page[*models.User]{},
+	},
+	"GET /api/users/:id":                {summary: "A user", tag: "Users", access: accessUser, resp: models.User{}},
+	"GET /api/users/username/:username": {summary: "A user by username", tag: "Users", access: accessUser, resp: models.User{}},
+	"GET /api/users/:id/privacy":        {summary: "A user's privacy settings", description: "Only the user or an admin.", tag: "Users", access: accessUser, resp: models.PrivacySettings{}},
+	"PUT /api/users/:id/privacy":        {summary: "Change a user's privacy settings", description: "Only the user or an admin.", tag: "Users", access: accessSession, body: models.PrivacySettings{}, resp: models.PrivacySettings{}},
+	"GET /api/users/:id/portfolio-history": {
+		summary: "A user's daily portfolio value",
+		tag:     "Users",
+		access:  accessUser,
+		query:   []docParam{docRangeParam},
+		resp:    []models.PortfolioPoint{},
+	},
+
+	"GET /api/players": {
+		summary:     "List players, or fetch several by ID",
+		description: "With ids, returns those players as an array and ignores the other parameters; otherwise a page of players.",
+		tag:         "Players",
+		access:      accessUser,
+		query: []docParam{
+			{name: "ids", typ: "string", desc: "Comma-separated player IDs."},
+			{name: "q", typ: "string", desc: "Part of a player's name."},
+			{name: "min_value", typ: "number"},
+			{name: "max_value", typ: "number"},
+			{name: "min_capacity", typ: "integer", desc: "Least capacity still available to buy."},
+		},
+		list: &playerListSpec,
+		resp: models.Page[*models.Player]{},
+	},
+	"GET /api/players/:id":        {summary: "A player", tag: "Players", access: accessUser, resp: models.Player{}},
+	"GET /api/players/name/:slug": {summary: "A player by slug", tag: "Players", access: accessUser, resp: models.Player{}},
+	"GET /api/players/:id/price-history": {
+		summary:     "A player's value over time",
+		description: "Covers range, or from and to, which may not be combined with it. Long windows are downsampled to at most 500 points, keeping the last value in each slice of the window.",
+		tag:         "Players",
+		access:      accessUser,
+		query:       []docParam{docRangeParam, docHistoryFromParam, docHistoryToParam},
+		resp:        []models.PricePoint{},
+	},
+	"GET /api/players/:id/candles": {
+		summary:     "A player's value as open/high/low/close candles with trade volume",
+		description: "Covers range, or from and to, which may not be combined with it. Without interval the finest of hour, day and week that gives at most 1000 candles is used. Intervals without a value change are flat at the previous close. Volume and trades count the player's global island.",
+		tag:         "Players",
+		access:      accessUser,
+		query: []docParam{
+			{name: "interval", typ: "string", enum: []string{models.CandleHour, models.CandleDay, models.CandleWeek}},
+			docRangeParam,
+			docHistoryFromParam,
+			docHistoryToParam,
+		},
+		resp: models.CandleSeries{},
+	},
+
+	"GET /api/transactions": {
+		summary:     "List transactions",
+		description: "Without league_id, admins see every league's trades and everyone else the global islands'.",
+		tag:         "Trading",
+		access:      accessUser,
+		query:       []docParam{docTypeParam, docUserIDParam, docPlayerIDParam, docLeagueIDParam, docFromParam, docToParam},
+		list:        &transactionListSpec,
+		resp:        models.Page[*models.Transaction]{},
+	},
+	"POST /api/transactions/buy":  {summary: "Buy shares of a player", tag: "Trading", access: accessUser, body: TransactionRequest{}, resp: models.Transaction{}},
+	"POST /api/transactions/sell": {summary: "Sell shares of a player", tag: "Trading", access: accessUser, body: TransactionRequest{}, resp: sellResponse{}},
+	"GET /api/transactions/:id":   {summary: "A transaction", tag: "Trading", access: accessUser, resp: models.Transaction{}},
+	"GET /api/users/:id/transactions": {
+		summary: "A user's transactions",
+		tag:     "Trading",
+		access:  accessUser,
+		query:   []docParam{docTypeParam, docPlayerIDParam, docLeagueIDParam, docFromParam, docToParam},
+		list:    &transactionListSpec,
+		resp:    models.Page[*models.Transaction]{},
+	},
+	"GET /api/players/:id/transactions": {
+		summary: "A player's transactions",
+		tag:     "Trading",
+		access:  accessUser,
+		query:   []docParam{docTypeParam, docUserIDParam, docLeagueIDParam, docFromParam, docToParam},
+		list:    &transactionListSpec,
+		resp:    models.Page[*models.Transaction]{},
+	},
+	"GET /api/positions": {
+		summary:     "List positions, on the global islands unless league_id is set",
+		description: "Lots are included when user_id or player_id is set.",
+		tag:         "Trading",
+		access:      accessUser,
+		query:       []docParam{docUserIDParam, docPlayerIDParam, docLeagueIDParam},
+		list:        &positionListSpec,
+		resp:        models.Page[*models.Position]{},
+	},
+	"GET /api/users/:id/positions": {
+		summary: "A user's positions, on the global islands unless league_id is set",
+		tag:     "Trading",
+		access:  accessUser,
+		query:   []docParam{docPlayerIDParam, docLeagueIDParam},
+		list:    &positionListSpec,
+		resp:    models.Page[*models.Position]{},
+	},
+	"GET /api/players/:id/positions": {
+		summary: "A player's holders, on the global islands unless league_id is set",
+		tag:     "Trading",
+		access:  accessUser,
+		query:   []docParam{docUserIDParam, docLeagueIDParam},
+		list:    &positionListSpec,
+		resp:    models.Page[*models.Position]{},
+	},
+
+	"GET /api/stream": {
+		summary:     "Live price changes, trades and dividends as server-sent events",
+		description: "Each event is named by its type and carries a MarketEvent as JSON. Without filters it sends every price change, trades on islands the user holds, and the user's own trades and dividends. player_ids narrows everything to those players; user_ids narrows trades to those traders; either also includes global trades by anyone. The stream starts with a ready event and ends with a dropped event if the client falls behind. Browsers' EventSource cannot send the Authorization header, so use a fetch-based client or an API key.",
+		tag:         "Trading",
+		access:      accessUser,
+		query: []docParam{
+			{name: "types", typ: "string", desc: "Comma-separated event types: price.changed, trade.executed, dividend.paid."},
+			{name: "player_ids", typ: "string", desc: "Comma-separated player IDs."},
+			{name: "user_ids", typ: "string", desc: "Comma-separated user IDs."},
+		},
+		contentType: "text/event-stream",
+	},
+
+	"GET /api/watchlist":        {summary: "The user's watchlist with current values", tag: "Alerts", access: accessUser, resp: []*models.WatchlistItem{}},
+	"POST /api/watchlist":       {summary: "Add a player to the watchlist", tag: "Alerts", access: accessUser, body: WatchRequest{}, resp: messageResponse{}},
+	"DELETE /api/watchlist/:id": {summary: "Remove a player, by player ID, from the watchlist", tag: "Alerts", access: accessUser, resp: messageResponse{}},
+	"GET /api/alerts":           {summary: "The user's price alerts", tag: "Alerts", access: accessUser, resp: []*models.PriceAlert{}},
+	"POST /api/alerts": {
+		summary:     "Create a price alert",
+		description: "above and below fire when the player's value reaches threshold; change fires when the value has moved by threshold percent, either way, since window_hours ago. An alert fires once per crossing: it rearms when its condition stops holding. Alerts are checked when values update and once on creation. With email set, the notification is also emailed if the user's address is verified.",
+		tag:         "Alerts",
+		access:      accessUser,
+		body:        service.CreateAlertRequest{},
+		status:      http.StatusCreated,
+		resp:        models.PriceAlert{},
+	},
+	"DELETE /api/alerts/:id": {summary: "Delete a price alert", tag: "Alerts", access: accessUser, resp: messageResponse{}},
+	"GET /api/notifications": {
+		summary: "The user's notifications",
+		tag:     "Alerts",
+		access:  accessUser,
+		query:   []docParam{{name: "unread", typ: "boolean", desc: "Only unread notifications."}},
+		list:    &notificationListSpec,
+		resp:    models.Page[*models.Notification]{},
+	},
+	"GET /api/notifications/unread-count": {summary: "How many notifications are unread", tag: "Alerts", access: accessUser, resp: unreadCountResponse{}},
+	"POST /api/notifications/read-all":    {summary: "Mark every notification read", tag: "Alerts", access: accessUser, resp: markedResponse{}},
+	"POST /api/notifications/:id/read":    {summary: "Mark a notification read", tag: "Alerts", access: accessUser, resp: messageResponse{}},
+
+	"GET /api/seasons":               {summary: "All seasons", tag: "Seasons", access: accessUser, resp: []*models.Season{}},
+	"GET /api/seasons/current":       {summary: "The current season", tag: "Seasons", access: accessUser, resp: models.Season{}},
+	"GET /api/seasons/:id/standings": {summary: "A season's final standings", tag: "Seasons", access: accessUser, resp: []*models.SeasonStanding{}},
+
+	"GET /api/leaderboards/:kind": {
+		summary: "A leaderboard",
+		tag:     "Leaderboards",
+		access:  accessUser,
+		query: append([]docParam{
+			{name: "window", typ: "string", desc: "Score change over this window; defaults to all.", enum: []string{"all", "1d", "7d", "30d"}},
+		}, docPageParams...),
+		resp: models.Leaderboard{},
+	},
+	"GET /api/leaderboards/:kind/history": {
+		summary: "A user's daily rank on a leaderboard",
+		tag:     "Leaderboards",
+		access:  accessUser,
+		query:   []docParam{{name: "user_id", typ: "integer", required: true}, docRangeParam},
+		resp:    []models.LeaderboardSnapshot{},
+	},
+
+	"GET /api/leagues":             {summary: "The signed-in user's leagues", tag: "Leagues", access: accessUser, resp: []*models.League{}},
+	"POST /api/leagues":            {summary: "Create a league", tag: "Leagues", access: accessUser, body: service.CreateLeagueRequest{}, status: http.StatusCreated, resp: models.League{}},
+	"POST /api/leagues/join":       {summary: "Join a league with its invite code", tag: "Leagues", access: accessUser, body: JoinLeagueRequest{}, resp: models.League{}},
+	"GET /api/leagues/:id":         {summary: "A league the user belongs to", tag: "Leagues", access: accessUser, resp: models.League{}},
+	"GET /api/leagues/:id/members": {summary: "A league's members", tag: "Leagues", access: accessUser, resp: []*models.LeagueMember{}},
+	"POST /api/leagues/:id/leave": {summary: "Leave a league", tag: "Leagues", access: accessUser, resp: struct {
+		LeftLeagueID int64 `json:"left_league_id"`
+	}{}},
+	"GET /api/leagues/:id/leaderboards/:kind": {summary: "A league's leaderboard", tag: "Leagues", access: accessUser, query: docPageParams, resp: models.Leaderboard{}},
+	"GET /api/leagues/:id/draft":              {summary: "A league's draft", tag: "Leagues", access: accessUser, resp: models.Draft{}},
+	"POST /api/leagues/:id/draft":             {summary: "Schedule a league's draft", tag: "Leagues", access: accessUser, body: service.CreateDraftRequest{}, status: http.StatusCreated, resp: models.Draft{}},
+	"POST /api/leagues/:id/draft/start":       {summary: "Start a league's draft", tag: "Leagues", access: accessUser, resp: models.Draft{}},
+	"POST /api/leagues/:id/draft/picks":       {summary: "Make the user's pick", tag: "Leagues", access: accessUser, body: DraftPickRequest{}, resp: models.Draft{}},
+
+	"GET /api/api-keys":        {summary: "The user's API keys", tag: "API keys", access: accessSession, resp: []*models.APIKey{}},
+	"POST /api/api-keys":       {summary: "Create an API key", description: "The key is only ever shown in this response.", tag: "API keys", access: accessSession, body: service.CreateAPIKeyRequest{}, status: http.StatusCreated, resp: CreatedAPIKey{}},
+	"DELETE /api/api-keys/:id": {summary: "Revoke an API key", tag: "API keys", access: accessSession, resp: messageResponse{}},
+
+	"GET /api/webhooks": {summary: "The user's webhooks", tag: "Webhooks", access: accessSession, resp: []*models.Webhook{}},
+	"POST /api/webhooks": {
+		summary:     "Create a webhook",
+		description: "Each matching event is POSTed as JSON with X-NBAIsland-Event, X-NBAIsland-Delivery and X-NBAIsland-Signature headers. The signature is t=<unix seconds>,v1=<hex HMAC-SHA256 of \"<t>.<body>\" keyed by the secret>; the secret is only ever shown in this response. Trades are sent for the global islands and the owner's own league trades; dividends only for the owner; ingestion.failed only to admins. Failed deliveries are retried with exponential backoff.",
+		tag:         "Webhooks",
+		access:      accessSession,
+		body:        service.CreateWebhookRequest{},
+		status:      http.StatusCreated,
+		resp:        CreatedWebhook{},
+	},
+	"DELETE /api/webhooks/:id": {summary: "Delete a webhook and its delivery log", tag: "Webhooks", access: accessSession, resp: messageResponse{}},
+	"GET /api/webhooks/:id/deliveries": {
+		summary: "A webhook's delivery log",
+		tag:     "Webhooks",
+		access:  accessSession,
+		query:   []docParam{{name: "status", typ: "string", enum: deliveryStatuses}},
+		list:    &deliveryListSpec,
+		resp:    models.Page[*models.WebhookDelivery]{},
+	},
+
+	"POST /api/admin/players":       {summary: "Create a player", tag: "Admin", access: accessAdmin, body: CreatePlayer{}, resp: CreatePlayer{}},
+	"DELETE /api/admin/players/:id": {summary: "Delete a player", tag: "Admin", access: accessAdmin, resp: int64(0)},
+	"DELETE /api/admin/users/:id": {summary: "Delete a user", tag: "Admin", access: accessAdmin, resp: struct {
+		DeletedUserID int64 `json:"deleted_user_id"`
+	}{}},
+	"GET /api/admin/economy": {summary: "Totals across the economy", tag: "Admin", access: accessAdmin, resp: models.EconomySummary{}},
+	"GET /api/admin/failed-logins": {
+		summary: "Recent failed logins, newest first",
+		tag:     "Admin",
+		access:  accessAdmin,
+		query: []docParam{
+			{name: "username", typ: "string"},
+			{name: "limit", typ: "integer", desc: "1 to 1000; defaults to 100."},
+		},
+		resp: []*models.FailedLogin{},
+	},
+	"GET /api/admin/jobs": {summary: "Scheduled jobs", tag: "Admin", access: accessAdmin, resp: []scheduler.JobInfo{}},
+	"POST /api/admin/jobs/:slug/run": {summary: "Run a scheduled job now", tag: "Admin", access: accessAdmin, status: http.StatusAccepted, resp: struct {
+		Started string `json:"started"`
+	}{}},
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/openapi"
+)
+
+func newDocumentedRouter(t *testing.T) (*gin.Engine, *openapi.Document) {
+	t.Helper()
+	r := gin.New()
+	RegisterRoutes(r, &Handlers{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d: %s", w.Code, w.Body)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("/openapi.json is not a document: %v", err)
+	}
+	return r, &doc
+}
+
+func TestEveryRouteIsInOpenAPISpec(t *testing.T) {
+	r, doc := newDocumentedRouter(t)
+
+	for _, route := range r.Routes() {
+		path, params := openapi.Path(route.Path)
+		op := doc.Paths[path][strings.ToLower(route.Method)]
+		if op == nil {
+			t.Errorf("%s %s is missing from the OpenAPI document; add it to routeDocs", route.Method, route.Path)
+			continue
+		}
+		for _, name := range params {
+			found := false
+			for _, p := range op.Parameters {
+				found = found || (p.In == "path" && p.Name == name)
+			}
+			if !found {
+				t.Errorf("%s %s does not document path parameter %s", route.Method, route.Path, name)
+			}
+		}
+	}
+}
+
+func TestRouteDocsOnlyDescribeRegisteredRoutes(t *testing.T) {
+	r, _ := newDocumentedRouter(t)
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for key := range routeDocs {
+		if !registered[key] {
+			t.Errorf("routeDocs has %q, which is not a registered route", key)
+		}
+	}
+}
+
+func TestOpenAPISchemaReferencesResolve(t *testing.T) {
+	_, doc := newDocumentedRouter(t)
+
+	raw, _ := json.Marshal(doc)
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	var tree interface{}
+	json.Unmarshal(raw, &tree)
+	walk(tree)
+
+	if len(refs) == 0 {
+		t.Fatal("document references no schemas")
+	}
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("%s does not resolve", ref)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type DraftHandler struct {
+	DraftService *service.DraftService
+}
+
+type DraftPickRequest struct {
+	PlayerID int64 `json:"player_id" binding:"required"`
+}
+
+func (h *DraftHandler) CreateDraft(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	leagueID, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+
+	draft, err := h.DraftService.Create(c.Request.Context(), leagueID, claims.UserID, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not create draft"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, draft)
+}
+
+func (h *DraftHandler) GetDraft(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	leagueID, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	draft, err := h.DraftService.Get(c.Request.Context(), leagueID, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch draft"))
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+func (h *DraftHandler) StartDraft(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	leagueID, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	draft, err := h.DraftService.Start(c.Request.Context(), leagueID, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not start draft"))
+		return
+	}
+
+	logger.Log.Info("draft started",
+		zap.Int64("league_id", leagueID),
+		zap.Int64("draft_id", draft.ID),
+	)
+	c.JSON(http.StatusOK, draft)
+}
+
+func (h *DraftHandler) MakePick(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	leagueID, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req DraftPickRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a player_id"))
+		return
+	}
+
+	draft, err := h.DraftService.Pick(c.Request.Context(), leagueID, claims.UserID, req.PlayerID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not make pick"))
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
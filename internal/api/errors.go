@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+)
+
+// Errors several handlers share.
+var (
+	errUnauthorized   = apperror.Unauthenticated(apperror.CodeUnauthorized, "Unauthorized")
+	errInvalidRequest = apperror.InvalidRequest("Invalid request")
+	errInvalidID      = apperror.InvalidRequest("Provide a valid id")
+	errPlayerNotFound = apperror.NotFound("PLAYER_NOT_FOUND", "player not found")
+)
+
+// abortWithError hands err to middleware.ErrorHandler to render and stops
+// the chain. Pass service errors through apperror.From so anything that is
+// not an *apperror.Error is reported as a 500 without its details.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
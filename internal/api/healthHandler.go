@@ -1,11 +1,9 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
 	"github.com/gin-gonic/gin"
-    "go.uber.org/zap"
-	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/service"
 )
 
@@ -16,11 +14,7 @@ type HealthHandler struct {
 
 func (h *HealthHandler) CheckHealth(c *gin.Context){
 	if err := h.HealthService.Check(c.Request.Context()); err != nil {
-		logger.Log.Error("DB is not up",
-			zap.Error(err),
-			zap.String("handler", "CheckHealth"),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error" : fmt.Sprintf("Could not confirm db is up: %v", err)})
+		abortWithError(c, apperror.New(apperror.KindUnavailable, "DATABASE_UNAVAILABLE", "Could not confirm db is up").Wrap(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status" : "ok"})
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type LeaderboardHandler struct {
+	LeaderboardService *service.LeaderboardService
+}
+
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	// leaderboards/:kind?window=7d&page=1&limit=25
+	kind := c.Param("kind")
+	page, limit, err := parsePageNumber(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	board, err := h.LeaderboardService.Get(c.Request.Context(), kind, c.Query("window"), page, limit)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch leaderboard"))
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
+
+func (h *LeaderboardHandler) GetLeaderboardHistory(c *gin.Context) {
+	// leaderboards/:kind/history?user_id=3&range=30d
+	kind := c.Param("kind")
+	userID, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid user_id"))
+		return
+	}
+
+	timeRange := c.Query("range")
+	if timeRange == "" {
+		timeRange = "30d"
+	}
+
+	history, err := h.LeaderboardService.GetHistory(c.Request.Context(), kind, userID, timeRange)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch leaderboard history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
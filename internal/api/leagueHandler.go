@@ -0,0 +1,196 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type LeagueHandler struct {
+	LeagueService      *service.LeagueService
+	LeaderboardService *service.LeaderboardService
+}
+
+type JoinLeagueRequest struct {
+	InviteCode string `json:"invite_code" binding:"required"`
+}
+
+func currentClaims(c *gin.Context) (*auth.Claims, bool) {
+	claimsAny, ok := c.Get("user")
+	if !ok {
+		abortWithError(c, errUnauthorized)
+		return nil, false
+	}
+	return claimsAny.(*auth.Claims), true
+}
+
+func leagueIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, errInvalidID)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *LeagueHandler) CreateLeague(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateLeagueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+
+	league, err := h.LeagueService.Create(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not create league"))
+		return
+	}
+
+	logger.Log.Info("league created",
+		zap.Int64("league_id", league.ID),
+		zap.Int64("owner_id", claims.UserID),
+		zap.Bool("contained", league.Contained()),
+	)
+	c.JSON(http.StatusCreated, league)
+}
+
+func (h *LeagueHandler) GetMyLeagues(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+
+	leagues, err := h.LeagueService.GetByUserID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch leagues"))
+		return
+	}
+	for _, l := range leagues {
+		if l.OwnerID != claims.UserID {
+			l.InviteCode = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, leagues)
+}
+
+func (h *LeagueHandler) GetLeague(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	league, err := h.LeagueService.GetForMember(c.Request.Context(), id, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch league"))
+		return
+	}
+
+	c.JSON(http.StatusOK, league)
+}
+
+func (h *LeagueHandler) GetMembers(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.LeagueService.GetMembers(c.Request.Context(), id, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch league members"))
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *LeagueHandler) JoinLeague(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+
+	var req JoinLeagueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide an invite_code"))
+		return
+	}
+
+	league, err := h.LeagueService.Join(c.Request.Context(), claims.UserID, req.InviteCode)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not join league"))
+		return
+	}
+
+	c.JSON(http.StatusOK, league)
+}
+
+func (h *LeagueHandler) LeaveLeague(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.LeagueService.Leave(c.Request.Context(), id, claims.UserID); err != nil {
+		abortWithError(c, apperror.From(err, "Could not leave league"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"left_league_id": id})
+}
+
+func (h *LeagueHandler) GetLeaderboard(c *gin.Context) {
+	// leagues/:id/leaderboards/:kind?page=1&limit=25
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, ok := leagueIDParam(c)
+	if !ok {
+		return
+	}
+	kind := c.Param("kind")
+	page, limit, err := parsePageNumber(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	league, err := h.LeagueService.GetForMember(ctx, id, claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch leaderboard"))
+		return
+	}
+
+	board, err := h.LeaderboardService.GetLeague(ctx, league, kind, page, limit)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch leaderboard"))
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// listSpec is what a list endpoint lets clients sort by.
+type listSpec struct {
+	sorts       []string
+	defaultSort string
+	defaultDesc bool
+}
+
+// parsePage reads the paging query parameters shared by list endpoints:
+//
+//	?limit=50&sort=-value&cursor=...
+//
+// sort names one of spec's sorts, descending with a leading "-". cursor is
+// the next_cursor of the previous page and only works with the same sort.
+func parsePage(c *gin.Context, spec listSpec) (models.PageRequest, error) {
+	req := models.PageRequest{
+		Limit:  defaultPageLimit,
+		Sort:   spec.defaultSort,
+		Desc:   spec.defaultDesc,
+		Cursor: c.Query("cursor"),
+	}
+
+	limit, err := queryLimit(c, defaultPageLimit)
+	if err != nil {
+		return req, err
+	}
+	req.Limit = limit
+
+	if raw := c.Query("sort"); raw != "" {
+		req.Desc = strings.HasPrefix(raw, "-")
+		req.Sort = strings.TrimPrefix(raw, "-")
+		if !slices.Contains(spec.sorts, req.Sort) {
+			return req, apperror.InvalidRequest("sort must be one of " + strings.Join(spec.sorts, ", ") + ", with a leading - for descending")
+		}
+	}
+	return req, nil
+}
+
+// parsePageNumber reads the numbered paging of the leaderboards:
+//
+//	?page=2&limit=25
+//
+// page is 1-based and defaults to 1; limit is checked as parsePage checks
+// it, and zero leaves the default to the service.
+func parsePageNumber(c *gin.Context) (page int, limit int, err error) {
+	page = 1
+	if raw := c.Query("page"); raw != "" {
+		page, err = strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return 0, 0, apperror.InvalidRequest("page must be a positive integer")
+		}
+	}
+	limit, err = queryLimit(c, 0)
+	return page, limit, err
+}
+
+// queryLimit reads ?limit=, which must be between 1 and maxPageLimit.
+func queryLimit(c *gin.Context, def int) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, apperror.InvalidRequest("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+	}
+	return limit, nil
+}
+
+// queryFloat reads an optional number; nil means the parameter was absent.
+func queryFloat(c *gin.Context, name string) (*float64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, apperror.InvalidRequest(name + " must be a number")
+	}
+	return &v, nil
+}
+
+// queryInt reads an optional integer; nil means the parameter was absent.
+func queryInt(c *gin.Context, name string) (*int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, apperror.InvalidRequest(name + " must be a whole number")
+	}
+	return &v, nil
+}
+
+// queryID reads an optional positive ID; 0 means the parameter was absent.
+func queryID(c *gin.Context, name string) (int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 1 {
+		return 0, apperror.InvalidRequest("Provide a valid " + name)
+	}
+	return v, nil
+}
+
+// queryTime reads an optional RFC 3339 time or YYYY-MM-DD date (midnight
+// UTC); nil means the parameter was absent.
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, apperror.InvalidRequest(name + " must be an RFC 3339 time or a YYYY-MM-DD date")
+}
+
+// splitList splits a comma-separated parameter, skipping blanks.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// queryIDList reads an optional comma-separated list of IDs.
+func queryIDList(c *gin.Context, name string) ([]int64, error) {
+	var ids []int64
+	for _, raw := range splitList(c.Query(name)) {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id < 1 {
+			return nil, apperror.InvalidRequest(name + " must be a comma-separated list of IDs")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type NotificationHandler struct {
+	NotificationService *service.NotificationService
+}
+
+var notificationListSpec = listSpec{
+	sorts:       []string{models.NotificationSortCreatedAt},
+	defaultSort: models.NotificationSortCreatedAt,
+	defaultDesc: true,
+}
+
+// GetNotifications answers with a page of the user's notifications, newest
+// first, only the unread ones with ?unread=true.
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	filter := models.NotificationFilter{UserID: claims.UserID}
+	if raw := c.Query("unread"); raw != "" {
+		unread, err := strconv.ParseBool(raw)
+		if err != nil {
+			abortWithError(c, apperror.InvalidRequest("unread must be true or false"))
+			return
+		}
+		filter.UnreadOnly = unread
+	}
+	req, err := parsePage(c, notificationListSpec)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	page, err := h.NotificationService.List(c.Request.Context(), filter, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not load notifications"))
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	count, err := h.NotificationService.UnreadCount(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not count notifications"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid notification id"))
+		return
+	}
+	if err := h.NotificationService.MarkRead(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithError(c, apperror.From(err, "Could not mark notification read"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked read"})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	count, err := h.NotificationService.MarkAllRead(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not mark notifications read"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"marked": count})
+}
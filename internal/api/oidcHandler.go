@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// OIDCHandler signs users in with external identity providers. The web app
+// starts a sign-in, sends the user to the returned authorization_url, and
+// posts the code and state from the provider's redirect to the callback. A
+// link is finished on the link callback instead, with the user's session.
+type OIDCHandler struct {
+	OIDCService    *service.OIDCService
+	SessionService *service.SessionService
+}
+
+var errSignInFailed = apperror.Unauthenticated("SIGN_IN_FAILED", "Sign-in failed")
+
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.OIDCService.ProviderNames()})
+}
+
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	h.begin(c, nil)
+}
+
+// StartLink begins linking another identity to the signed-in user.
+func (h *OIDCHandler) StartLink(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	h.begin(c, &claims.UserID)
+}
+
+func (h *OIDCHandler) begin(c *gin.Context, linkUserID *int64) {
+	url, err := h.OIDCService.Begin(c.Request.Context(), c.Param("provider"), linkUserID)
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		abortWithError(c, appErr)
+		return
+	}
+	if err != nil {
+		abortWithError(c, apperror.New(apperror.KindUpstream, "PROVIDER_UNAVAILABLE", "Could not reach the sign-in provider").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": url})
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	ctx := c.Request.Context()
+	req, ok := bindCallback(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.OIDCService.Complete(ctx, c.Param("provider"), req.State, req.Code)
+	if !h.completed(c, "Callback", user, err) {
+		return
+	}
+
+	tokens, err := h.SessionService.Start(ctx, user, requestClient(c))
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Error generating token"))
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{
+		TokenResponse: tokenResponse(tokens),
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+	})
+}
+
+// LinkCallback finishes a link started with StartLink. It runs under the
+// signed-in user's session, which must belong to the user who started the
+// link, and returns their linked identities.
+func (h *OIDCHandler) LinkCallback(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	req, ok := bindCallback(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.OIDCService.CompleteLink(c.Request.Context(), c.Param("provider"), req.State, req.Code, claims.UserID)
+	if !h.completed(c, "LinkCallback", user, err) {
+		return
+	}
+	h.GetIdentities(c)
+}
+
+func bindCallback(c *gin.Context) (OIDCCallbackRequest, bool) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
+		abortWithError(c, apperror.InvalidRequest("code and state required"))
+		return req, false
+	}
+	return req, true
+}
+
+// completed aborts unless a callback produced a user.
+func (h *OIDCHandler) completed(c *gin.Context, handler string, user *models.User, err error) bool {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		abortWithError(c, appErr)
+		return false
+	}
+	if err != nil {
+		logger.Log.Warn("OIDC sign-in failed",
+			zap.Error(err),
+			zap.String("handler", handler),
+			zap.String("provider", c.Param("provider")),
+		)
+		abortWithError(c, errSignInFailed)
+		return false
+	}
+	if user == nil {
+		abortWithError(c, errSignInFailed)
+		return false
+	}
+	return true
+}
+
+func (h *OIDCHandler) GetIdentities(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	identities, err := h.OIDCService.GetIdentities(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load linked identities"))
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
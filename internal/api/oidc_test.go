@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/middleware"
+	"github.com/nbaisland/nbaisland/internal/oidc"
+	"github.com/nbaisland/nbaisland/internal/oidc/oidctest"
+	"github.com/nbaisland/nbaisland/internal/repository"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+func (r *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, u *models.User) error {
+	u.ID = int64(len(r.users) + 100)
+	u.Role = models.RoleUser
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *fakeUserRepo) MarkEmailVerified(ctx context.Context, id int64) error {
+	r.users[id].EmailVerified = true
+	return nil
+}
+
+type fakeIdentityRepo struct {
+	repository.IdentityRepository
+	identities []*models.UserIdentity
+	states     map[string]*models.OIDCState
+}
+
+func (r *fakeIdentityRepo) Get(ctx context.Context, provider string, subject string) (*models.UserIdentity, error) {
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeIdentityRepo) Create(ctx context.Context, i *models.UserIdentity) error {
+	i.ID = int64(len(r.identities) + 1)
+	r.identities = append(r.identities, i)
+	return nil
+}
+
+func (r *fakeIdentityRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.UserIdentity, error) {
+	var linked []*models.UserIdentity
+	for _, i := range r.identities {
+		if i.UserID == userID {
+			linked = append(linked, i)
+		}
+	}
+	return linked, nil
+}
+
+func (r *fakeIdentityRepo) TouchLogin(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (r *fakeIdentityRepo) CreateState(ctx context.Context, s *models.OIDCState) error {
+	r.states[s.State] = s
+	return nil
+}
+
+func (r *fakeIdentityRepo) ConsumeState(ctx context.Context, state string) (*models.OIDCState, error) {
+	s, ok := r.states[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(r.states, state)
+	return s, nil
+}
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, s *models.AuthSession) error {
+	return nil
+}
+
+type fakeSeasonRepo struct {
+	repository.SeasonRepository
+}
+
+func (r *fakeSeasonRepo) GetCurrent(ctx context.Context) (*models.Season, error) {
+	return &models.Season{ID: 1, StartingCurrency: 500, Status: models.SeasonActive}, nil
+}
+
+type oidcFixture struct {
+	issuer     *oidctest.Issuer
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+	router     *gin.Engine
+}
+
+// newOIDCFixture serves the OIDC routes against a mock issuer on httptest.
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	keys, err := auth.EphemeralKeySet("nbaisland", "nbaisland-api", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Configure(keys)
+
+	issuer, err := oidctest.NewIssuer("nbaisland", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(issuer.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		IssuerURL:    issuer.URL(),
+		ClientID:     "nbaisland",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://app.test/auth/callback/mock",
+	}, issuer.Server.Client())
+
+	users := newFakeUserRepo()
+	identities := &fakeIdentityRepo{states: map[string]*models.OIDCState{}}
+	seasons := service.NewSeasonService(&fakeSeasonRepo{})
+	handler := &OIDCHandler{
+		OIDCService:    service.NewOIDCService(map[string]*oidc.Provider{"mock": provider}, identities, users, seasons),
+		SessionService: service.NewSessionService(&fakeSessionRepo{}, users, time.Hour),
+	}
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.POST("/auth/oidc/:provider/start", handler.StartLogin)
+	r.POST("/auth/oidc/:provider/callback", handler.Callback)
+	// Stands in for the session middleware: X-User-ID is the signed-in user.
+	signedIn := func(c *gin.Context) {
+		id, _ := strconv.ParseInt(c.GetHeader("X-User-ID"), 10, 64)
+		c.Set("user", &auth.Claims{UserID: id})
+	}
+	r.POST("/api/auth/oidc/:provider/link", signedIn, handler.StartLink)
+	r.POST("/api/auth/oidc/:provider/link/callback", signedIn, handler.LinkCallback)
+	return &oidcFixture{issuer: issuer, users: users, identities: identities, router: r}
+}
+
+func (f *oidcFixture) post(t *testing.T, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return f.postAs(t, 0, path, body)
+}
+
+func (f *oidcFixture) postAs(t *testing.T, userID int64, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.Header.Set("X-User-ID", strconv.FormatInt(userID, 10))
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// signIn starts a sign-in, lets the mock issuer authorize it and posts the
+// callback, returning the callback response.
+func (f *oidcFixture) signIn(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	body := f.authorize(t, f.post(t, "/auth/oidc/mock/start", ""))
+	return f.post(t, "/auth/oidc/mock/callback", body)
+}
+
+// authorize lets the mock issuer authorize the flow started by start and
+// returns the callback body for it.
+func (f *oidcFixture) authorize(t *testing.T, start *httptest.ResponseRecorder) string {
+	t.Helper()
+	if start.Code != http.StatusOK {
+		t.Fatalf("start status = %d: %s", start.Code, start.Body)
+	}
+	var resp struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if err := json.Unmarshal(start.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := f.issuer.Authorize(resp.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(OIDCCallbackRequest{Code: code, State: state})
+	return string(body)
+}
+
+func TestOIDCFirstSignInCreatesLinkedAccount(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	w := f.signIn(t)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback status = %d: %s", w.Code, w.Body)
+	}
+	var resp AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := auth.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("issued token does not validate: %v", err)
+	}
+	user := f.users.users[claims.UserID]
+	if user == nil || user.Username != "player" || user.Email != "player@example.com" || !user.EmailVerified {
+		t.Fatalf("unexpected account: %+v", user)
+	}
+	if user.Currency != 500 {
+		t.Fatalf("currency = %v, want the season's starting currency", user.Currency)
+	}
+	if len(f.identities.identities) != 1 || f.identities.identities[0].UserID != user.ID {
+		t.Fatalf("identity not linked: %+v", f.identities.identities)
+	}
+}
+
+func TestOIDCReturningIdentitySignsIntoSameAccount(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	first := f.signIn(t)
+	second := f.signIn(t)
+
+	if second.Code != http.StatusOK {
+		t.Fatalf("second sign-in status = %d: %s", second.Code, second.Body)
+	}
+	var a, b AuthResponse
+	json.Unmarshal(first.Body.Bytes(), &a)
+	json.Unmarshal(second.Body.Bytes(), &b)
+	if a.UserID != b.UserID {
+		t.Fatalf("second sign-in got user %d, want %d", b.UserID, a.UserID)
+	}
+	if len(f.identities.identities) != 1 {
+		t.Fatalf("identities = %d, want 1", len(f.identities.identities))
+	}
+}
+
+func TestOIDCDoesNotTakeOverAccountByEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.users.users[1].Email = "player@example.com"
+
+	w := f.signIn(t)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if len(f.identities.identities) != 0 {
+		t.Fatalf("identity was linked to an existing account by email")
+	}
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	w := f.post(t, "/auth/oidc/mock/callback", `{"code": "abc", "state": "forged"}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCLinkAttachesIdentityToStartingUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	body := f.authorize(t, f.postAs(t, 1, "/api/auth/oidc/mock/link", ""))
+	w := f.postAs(t, 1, "/api/auth/oidc/mock/link/callback", body)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("link callback status = %d: %s", w.Code, w.Body)
+	}
+	if len(f.identities.identities) != 1 || f.identities.identities[0].UserID != 1 {
+		t.Fatalf("identity not linked to user 1: %+v", f.identities.identities)
+	}
+}
+
+func TestOIDCLinkCannotBeCompletedByAnotherUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	// Alice starts a link and gets Bob to post the callback with his identity.
+	body := f.authorize(t, f.postAs(t, 1, "/api/auth/oidc/mock/link", ""))
+	w := f.postAs(t, 2, "/api/auth/oidc/mock/link/callback", body)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+	if len(f.identities.identities) != 0 {
+		t.Fatalf("identity was linked: %+v", f.identities.identities)
+	}
+}
+
+func TestOIDCLinkStateRejectedOnSignInCallback(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	body := f.authorize(t, f.postAs(t, 1, "/api/auth/oidc/mock/link", ""))
+	w := f.post(t, "/auth/oidc/mock/callback", body)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+	if len(f.identities.identities) != 0 {
+		t.Fatalf("identity was linked: %+v", f.identities.identities)
+	}
+}
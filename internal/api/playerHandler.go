@@ -8,7 +8,9 @@ import (
     "go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
 	"github.com/nbaisland/nbaisland/internal/service"
 )
 
@@ -24,19 +26,41 @@ type PlayerHandler struct {
 }
 
 
+var playerListSpec = listSpec{
+	sorts:       []string{models.PlayerSortID, models.PlayerSortName, models.PlayerSortValue, models.PlayerSortCapacity},
+	defaultSort: models.PlayerSortID,
+}
+
+// GetPlayers lists players a page at a time, filtered by ?min_value=,
+// ?max_value=, ?min_capacity= (capacity left to buy) and a ?q= name search.
 func (h *PlayerHandler) GetPlayers(c *gin.Context) {
-	ctx := c.Request.Context()
-	players, err := h.PlayerService.GetAll(ctx)
+	var filter models.PlayerFilter
+	var err error
+	if filter.MinValue, err = queryFloat(c, "min_value"); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if filter.MaxValue, err = queryFloat(c, "max_value"); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if filter.MinCapacity, err = queryInt(c, "min_capacity"); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	filter.Search = strings.TrimSpace(c.Query("q"))
+	req, err := parsePage(c, playerListSpec)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 
+	page, err := h.PlayerService.List(c.Request.Context(), filter, req)
 	if err != nil {
-		logger.Log.Error("Failed to Fetch players",
-			zap.Error(err),
-			zap.String("handler", "GetPlayers"),
-		)
-		c.JSON(500, gin.H{"error" : fmt.Sprintf("failed to fetch players : %v", err)})
+		abortWithError(c, apperror.From(err, "failed to fetch players"))
 		return
 	}
-	c.JSON(200, players)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *PlayerHandler) GetPlayersByID(c *gin.Context) {
@@ -56,7 +80,7 @@ func (h *PlayerHandler) GetPlayersByID(c *gin.Context) {
 				zap.String("handler", "GetPlayersByID"),
 				zap.String("IDs", idsParam),
 			)
-			c.JSON(http.StatusBadRequest, gin.H{"error" : fmt.Sprintf("invalid id %v", p)})
+			abortWithError(c, apperror.InvalidRequest(fmt.Sprintf("invalid id %v", p)))
 			return
 		}
 		ids = append(ids, id)
@@ -65,15 +89,10 @@ func (h *PlayerHandler) GetPlayersByID(c *gin.Context) {
 	players, err := h.PlayerService.GetPlayersByIDs(ctx, ids)
 
 	if err != nil {
-		logger.Log.Error("Failed to Fetch players by id",
-			zap.Error(err),
-			zap.String("handler", "GetPlayersByID"),
-			zap.String("IDs", idsParam),
-		)
-		c.JSON(500, gin.H{"error" : fmt.Sprintf("failed to fetch players : %v", err)})
+		abortWithError(c, apperror.Internal(err, "failed to fetch players"))
 		return
 	}
-	c.JSON(200, players)
+	c.JSON(http.StatusOK, players)
 }
 
 func (h *PlayerHandler) GetPlayerByID(c *gin.Context) {
@@ -86,20 +105,19 @@ func (h *PlayerHandler) GetPlayerByID(c *gin.Context) {
 			zap.String("handler", "GetPlayerByID"),
 			zap.String("ID", idStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"Error" : "Provide a valid id"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 	player, err := h.PlayerService.GetPlayerByID(ctx, id)
 	if err != nil {
-		logger.Log.Error("Failed to Fetch player by id",
-			zap.Error(err),
-			zap.String("handler", "GetPlayer"),
-			zap.Int64("IDs", id),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"Error" : fmt.Sprintf("Could not find player: %v", err)}) 
+		abortWithError(c, apperror.Internal(err, "Could not fetch player"))
+		return
+	}
+	if player == nil {
+		abortWithError(c, errPlayerNotFound)
 		return
 	}
-	c.JSON(200, player)
+	c.JSON(http.StatusOK, player)
 }
 
 func (h *PlayerHandler) GetPlayerBySlug(c *gin.Context) {
@@ -110,41 +128,31 @@ func (h *PlayerHandler) GetPlayerBySlug(c *gin.Context) {
 			zap.String("handler", "GetPlayerBySlug"),
 			zap.String("slug", slug),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"Error" : "Provide a valid Slug"})
+		abortWithError(c, apperror.InvalidRequest("Provide a valid Slug"))
 		return
 	}
 	player, err := h.PlayerService.GetPlayerBySlug(ctx, slug)
 	if err != nil {
-		logger.Log.Error("Failed to Fetch player by slug",
-			zap.Error(err),
-			zap.String("handler", "GetPlayerBySlug"),
-			zap.String("Slug", slug),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"Error" : fmt.Sprintf("Could not find player: %v", err)}) 
+		abortWithError(c, apperror.Internal(err, "Could not fetch player"))
 		return
 	}
-	c.JSON(200, player)
+	if player == nil {
+		abortWithError(c, errPlayerNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, player)
 }
 
 func (h *PlayerHandler) CreatePlayer(c *gin.Context){
 	ctx := c.Request.Context()
 	var req CreatePlayer
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid request: %v", err),
-		})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
 		return
 	}
 	err := h.PlayerService.CreatePlayer(ctx, req.Name, req.Value, req.Capacity, req.Slug)
 	if err != nil {
-		logger.Log.Error("Failed to Create player",
-			zap.Error(err),
-			zap.String("handler", "CreatePlayer"),
-			zap.Any("Request", req),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Could not create player: %v", err),
-		})
+		abortWithError(c, apperror.Internal(err, "Could not create player"))
 		return
 	}
 	c.JSON(http.StatusOK, req)
@@ -160,20 +168,12 @@ func (h *PlayerHandler) DeletePlayer(c *gin.Context){
 			zap.String("handler", "DeletePlayer"),
 			zap.String("id", idStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error" : "Provide a valid id",
-		})
+		abortWithError(c, errInvalidID)
+		return
 	}
 	err = h.PlayerService.DeletePlayer(ctx, id)
 	if err != nil {
-		logger.Log.Error("Could Not delete Player",
-			zap.Error(err),
-			zap.String("handler", "DeletePlayer"),
-			zap.Int64("id", id),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error" : "Could Not delete player",
-		})
+		abortWithError(c, apperror.Internal(err, "Could not delete player"))
 		return
 	}
 	c.JSON(http.StatusOK, id)
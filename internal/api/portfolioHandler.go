@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type PortfolioHandler struct {
+	PortfolioService *service.PortfolioService
+}
+
+func (h *PortfolioHandler) GetPortfolioHistory(c *gin.Context) {
+	// users/:id/portfolio-history?range=7d
+	idStr := c.Param("id")
+	userID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("invalid user id"))
+		return
+	}
+
+	timeRange := c.Query("range")
+	if timeRange == "" {
+		timeRange = "30d"
+	}
+
+	history, err := h.PortfolioService.GetHistory(
+		c.Request.Context(),
+		userID,
+		timeRange,
+	)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch portfolio history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
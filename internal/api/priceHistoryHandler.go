@@ -4,8 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
-	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/service"
 )
 
@@ -14,33 +13,67 @@ type PriceHistoryHandler struct {
 	PriceHistoryService *service.PriceHistoryService
 }
 
+// historyWindow reads ?range=, or ?from= and ?to=. With none of them the
+// window is the last 30 days.
+func historyWindow(c *gin.Context) (service.HistoryWindow, error) {
+	window := service.HistoryWindow{Range: c.Query("range")}
+	var err error
+	if window.From, err = queryTime(c, "from"); err != nil {
+		return window, err
+	}
+	if window.To, err = queryTime(c, "to"); err != nil {
+		return window, err
+	}
+	return window, nil
+}
+
 func (h *PriceHistoryHandler) GetPlayerPriceHistory(c *gin.Context) {
-	// players/:id/price-history?range=7d
+	// players/:id/price-history?range=7d or ?from=2025-01-01&to=2025-02-01
 	idStr := c.Param("id")
 	playerID, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid player id"})
+		abortWithError(c, apperror.InvalidRequest("invalid player id"))
 		return
 	}
 
-	timeRange := c.Query("range")
-	if timeRange == "" {
-		timeRange = "30d"
+	window, err := historyWindow(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
 
 	history, err := h.PriceHistoryService.GetPlayerPriceHistory(
 		c.Request.Context(),
 		playerID,
-		timeRange,
+		window,
 	)
 	if err != nil {
-		logger.Log.Error("Failed to Fetch Price History",
-			zap.Error(err),
-			zap.String("handler", "GetPlayerPriceHistory"),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch price history"})
+		abortWithError(c, apperror.From(err, "failed to fetch price history"))
 		return
 	}
 
 	c.JSON(http.StatusOK, history)
 }
+
+func (h *PriceHistoryHandler) GetPlayerCandles(c *gin.Context) {
+	// players/:id/candles?interval=day&range=90d
+	playerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("invalid player id"))
+		return
+	}
+
+	window, err := historyWindow(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	series, err := h.PriceHistoryService.GetCandles(c.Request.Context(), playerID, c.Query("interval"), window)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch candles"))
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+// PublicHandler serves read-only pages that need no login so users can share
+// their islands. Nothing here should expose ids of private users.
+type PublicHandler struct {
+	PublicService *service.PublicService
+}
+
+func (h *PublicHandler) GetProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	username := c.Param("username")
+
+	profile, err := h.PublicService.GetProfile(ctx, username)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch profile"))
+		return
+	}
+
+	if profile == nil {
+		abortWithError(c, apperror.NotFound("PROFILE_NOT_FOUND", "profile not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *PublicHandler) GetPlayerIsland(c *gin.Context) {
+	ctx := c.Request.Context()
+	slug := c.Param("slug")
+
+	island, err := h.PublicService.GetPlayerIsland(ctx, slug)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch island"))
+		return
+	}
+
+	if island == nil {
+		abortWithError(c, errPlayerNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, island)
+}
+
+func (h *PublicHandler) GetClaimBadge(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	svg, err := h.PublicService.RenderClaimBadge(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to render badge"))
+		return
+	}
+
+	if svg == nil {
+		abortWithError(c, apperror.NotFound("CLAIM_NOT_FOUND", "claim not found"))
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", svg)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/middleware"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+// Handlers is everything the API routes are served by. SessionService and
+// APIKeyService authenticate the /api routes.
+type Handlers struct {
+	SessionService *service.SessionService
+	APIKeyService  *service.APIKeyService
+
+	Auth         *AuthHandler
+	APIKey       *APIKeyHandler
+	OIDC         *OIDCHandler
+	User         *UserHandler
+	Player       *PlayerHandler
+	Transaction  *TransactionHandler
+	Health       *HealthHandler
+	PriceHistory *PriceHistoryHandler
+	Claim        *ClaimHandler
+	Public       *PublicHandler
+	Season       *SeasonHandler
+	Leaderboard  *LeaderboardHandler
+	Portfolio    *PortfolioHandler
+	League       *LeagueHandler
+	Draft        *DraftHandler
+	Admin        *AdminHandler
+	Stream       *StreamHandler
+	Webhook      *WebhookHandler
+	Alert        *AlertHandler
+	Notification *NotificationHandler
+}
+
+// RegisterRoutes mounts the API on r, along with its OpenAPI document at
+// /openapi.json and the docs UI at /docs. Every route needs an entry in
+// routeDocs; one without is left out of the document and logged.
+func RegisterRoutes(r *gin.Engine, h *Handlers) {
+	docs := &DocsHandler{}
+	r.GET("/openapi.json", docs.GetSpec)
+	r.GET("/docs", docs.GetUI)
+
+	r.GET("/health", h.Health.CheckHealth)
+	r.GET("/ready", h.Health.CheckReady)
+
+	r.POST("/auth/register", h.Auth.Register)
+	r.POST("/auth/login", h.Auth.Login)
+	r.POST("/auth/refresh", h.Auth.Refresh)
+	r.POST("/auth/logout", middleware.AuthMiddleware(h.SessionService, nil), h.Auth.Logout)
+	r.POST("/auth/logout-all", middleware.AuthMiddleware(h.SessionService, nil), h.Auth.LogoutAll)
+	r.POST("/auth/email/verify", h.Auth.VerifyEmail)
+	r.POST("/auth/password/forgot", h.Auth.ForgotPassword)
+	r.POST("/auth/password/reset", h.Auth.ResetPassword)
+	r.GET("/auth/oidc/providers", h.OIDC.GetProviders)
+	r.POST("/auth/oidc/:provider/start", h.OIDC.StartLogin)
+	r.POST("/auth/oidc/:provider/callback", h.OIDC.Callback)
+
+	r.GET("/claims/verify", h.Claim.VerifyClaims)
+
+	public := r.Group("/public")
+	{
+		public.GET("/users/:username", h.Public.GetProfile)
+		public.GET("/players/:slug", h.Public.GetPlayerIsland)
+		public.GET("/claims/:id/badge.svg", h.Public.GetClaimBadge)
+	}
+
+	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware(h.SessionService, h.APIKeyService))
+	{
+		api.GET("/users", h.User.GetUsers)
+		api.GET("/users/:id", h.User.GetUserByID)
+		api.GET("/users/username/:username", h.User.GetUserByUsername)
+
+		api.GET("/players", h.Player.GetPlayersByID)
+		api.GET("/players/:id", h.Player.GetPlayerByID)
+		api.GET("/players/name/:slug", h.Player.GetPlayerBySlug)
+		api.GET("/players/:id/price-history", h.PriceHistory.GetPlayerPriceHistory)
+		api.GET("/players/:id/candles", h.PriceHistory.GetPlayerCandles)
+		api.GET("/auth/me", h.Auth.GetCurrentUser)
+		api.POST("/auth/email/resend", middleware.RequireSession(), h.Auth.ResendVerification)
+		api.GET("/auth/identities", h.OIDC.GetIdentities)
+		api.POST("/auth/oidc/:provider/link", middleware.RequireSession(), h.OIDC.StartLink)
+		api.POST("/auth/oidc/:provider/link/callback", middleware.RequireSession(), h.OIDC.LinkCallback)
+
+		api.GET("/api-keys", middleware.RequireSession(), h.APIKey.GetAPIKeys)
+		api.POST("/api-keys", middleware.RequireSession(), h.APIKey.CreateAPIKey)
+		api.DELETE("/api-keys/:id", middleware.RequireSession(), h.APIKey.RevokeAPIKey)
+
+		api.GET("/webhooks", middleware.RequireSession(), h.Webhook.GetWebhooks)
+		api.POST("/webhooks", middleware.RequireSession(), h.Webhook.CreateWebhook)
+		api.DELETE("/webhooks/:id", middleware.RequireSession(), h.Webhook.DeleteWebhook)
+		api.GET("/webhooks/:id/deliveries", middleware.RequireSession(), h.Webhook.GetDeliveries)
+
+		api.GET("/users/:id/privacy", middleware.RequireSelfOrAdmin("id"), h.User.GetPrivacy)
+		api.PUT("/users/:id/privacy", middleware.RequireSession(), middleware.RequireSelfOrAdmin("id"), h.User.UpdatePrivacy)
+
+		api.GET("/transactions", h.Transaction.GetTransactions)
+		api.POST("/transactions/buy", h.Transaction.BuyTransaction)
+		api.POST("/transactions/sell", h.Transaction.SellTransaction)
+		api.GET("/transactions/:id", h.Transaction.GetTransactionByID)
+
+		api.GET("/positions", h.Transaction.GetPositions)
+
+		api.GET("/stream", h.Stream.Stream)
+
+		api.GET("/watchlist", h.Alert.GetWatchlist)
+		api.POST("/watchlist", h.Alert.Watch)
+		api.DELETE("/watchlist/:id", h.Alert.Unwatch)
+		api.GET("/alerts", h.Alert.GetAlerts)
+		api.POST("/alerts", h.Alert.CreateAlert)
+		api.DELETE("/alerts/:id", h.Alert.DeleteAlert)
+
+		api.GET("/notifications", h.Notification.GetNotifications)
+		api.GET("/notifications/unread-count", h.Notification.GetUnreadCount)
+		api.POST("/notifications/read-all", h.Notification.MarkAllRead)
+		api.POST("/notifications/:id/read", h.Notification.MarkRead)
+
+		api.GET("/users/:id/transactions", h.Transaction.GetTransactionsOfUser)
+		api.GET("/users/:id/positions", h.Transaction.GetPositionsOfUser)
+		api.GET("/users/:id/claims", h.Claim.GetClaimsOfUser)
+		api.GET("/users/:id/portfolio-history", h.Portfolio.GetPortfolioHistory)
+		api.GET("/players/:id/transactions", h.Transaction.GetTransactionsOfPlayer)
+		api.GET("/players/:id/positions", h.Transaction.GetPositionsOfPlayer)
+
+		api.GET("/seasons", h.Season.GetSeasons)
+		api.GET("/seasons/current", h.Season.GetCurrentSeason)
+		api.GET("/seasons/:id/standings", h.Season.GetStandings)
+
+		api.GET("/leaderboards/:kind", h.Leaderboard.GetLeaderboard)
+		api.GET("/leaderboards/:kind/history", h.Leaderboard.GetLeaderboardHistory)
+
+		api.GET("/leagues", h.League.GetMyLeagues)
+		api.POST("/leagues", h.League.CreateLeague)
+		api.POST("/leagues/join", h.League.JoinLeague)
+		api.GET("/leagues/:id", h.League.GetLeague)
+		api.GET("/leagues/:id/members", h.League.GetMembers)
+		api.POST("/leagues/:id/leave", h.League.LeaveLeague)
+		api.GET("/leagues/:id/leaderboards/:kind", h.League.GetLeaderboard)
+		api.GET("/leagues/:id/draft", h.Draft.GetDraft)
+		api.POST("/leagues/:id/draft", h.Draft.CreateDraft)
+		api.POST("/leagues/:id/draft/start", h.Draft.StartDraft)
+		api.POST("/leagues/:id/draft/picks", h.Draft.MakePick)
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.POST("/players", h.Player.CreatePlayer)
+		admin.DELETE("/players/:id", h.Player.DeletePlayer)
+		admin.DELETE("/users/:id", h.User.DeleteUser)
+
+		admin.GET("/economy", h.Transaction.GetEconomySummary)
+
+		admin.GET("/failed-logins", h.Admin.GetFailedLogins)
+
+		admin.GET("/jobs", h.Admin.GetJobs)
+		admin.POST("/jobs/:slug/run", h.Admin.RunJob)
+	}
+
+	spec, undocumented := openAPISpec(r.Routes())
+	for _, route := range undocumented {
+		logger.Log.Warn("Route missing from the OpenAPI document", zap.String("route", route))
+	}
+	docs.setSpec(spec)
+}
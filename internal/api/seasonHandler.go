@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type SeasonHandler struct {
+	SeasonService *service.SeasonService
+}
+
+func (h *SeasonHandler) GetSeasons(c *gin.Context) {
+	seasons, err := h.SeasonService.GetAll(c.Request.Context())
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch seasons"))
+		return
+	}
+
+	c.JSON(http.StatusOK, seasons)
+}
+
+func (h *SeasonHandler) GetCurrentSeason(c *gin.Context) {
+	season, err := h.SeasonService.GetCurrent(c.Request.Context())
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch season"))
+		return
+	}
+
+	c.JSON(http.StatusOK, season)
+}
+
+func (h *SeasonHandler) GetStandings(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	standings, err := h.SeasonService.GetStandings(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch standings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+// streamHeartbeat keeps idle streams from being cut by proxies.
+const streamHeartbeat = 25 * time.Second
+
+var streamEventTypes = []string{models.EventPriceChanged, models.EventTradeExecuted, models.EventDividendPaid}
+
+type StreamHandler struct {
+	StreamService *service.StreamService
+}
+
+// Stream sends market events as server-sent events, each named by its type
+// with the models.MarketEvent as JSON data:
+//
+//	/api/stream?types=price.changed,trade.executed&player_ids=3,7&user_ids=12
+//
+// A "ready" event follows the subscription; comments keep it alive. If the
+// client falls behind the stream ends with a "dropped" event and the client
+// should reconnect.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	filter, err := streamFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	sub, err := h.StreamService.Subscribe(c.Request.Context(), claims.UserID, filter)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not open the stream"))
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", gin.H{"user_id": claims.UserID})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case e, open := <-sub.C:
+			if !open {
+				if sub.Dropped() {
+					c.SSEvent("dropped", gin.H{"message": "Too far behind; reconnect"})
+				}
+				return false
+			}
+			c.SSEvent(e.Type, e)
+			return true
+		}
+	})
+}
+
+// streamFilter reads ?types=, ?player_ids= and ?user_ids=, each a
+// comma-separated list.
+func streamFilter(c *gin.Context) (service.StreamFilter, error) {
+	var filter service.StreamFilter
+	for _, t := range splitList(c.Query("types")) {
+		if !slices.Contains(streamEventTypes, t) {
+			return filter, apperror.InvalidRequest("types must be among " + strings.Join(streamEventTypes, ", "))
+		}
+		filter.Types = append(filter.Types, t)
+	}
+	var err error
+	if filter.PlayerIDs, err = queryIDList(c, "player_ids"); err != nil {
+		return filter, err
+	}
+	if filter.UserIDs, err = queryIDList(c, "user_ids"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
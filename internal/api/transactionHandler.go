@@ -1,93 +1,206 @@
 package api
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"net/http"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
 	"github.com/nbaisland/nbaisland/internal/service"
 )
 
+// TransactionRequest is a trade by the authenticated user; the acting user
+// always comes from the token, never the body.
 type TransactionRequest struct {
 	PlayerID int64 `json:"player_id"`
-	UserID   int64 `json:"user_id"`
 	Quantity int   `json:"quantity"`
+	// LeagueID trades on a contained league's islands; omit for global.
+	LeagueID int64 `json:"league_id"`
 }
 
 type TransactionHandler struct {
 	TransactionService *service.TransactionService
 }
 
-func (h *TransactionHandler) GetTransactionsOfUser(c *gin.Context) {
-	ctx := c.Request.Context()
+var (
+	transactionListSpec = listSpec{
+		sorts:       []string{models.TransactionSortTimestamp, models.TransactionSortPrice, models.TransactionSortQuantity},
+		defaultSort: models.TransactionSortTimestamp,
+		defaultDesc: true,
+	}
+	positionListSpec = listSpec{
+		sorts:       []string{models.PositionSortQuantity, models.PositionSortAverageCost},
+		defaultSort: models.PositionSortQuantity,
+		defaultDesc: true,
+	}
+)
 
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// queryLeagueID reads the optional ?league_id= filter, where 0 is the global
+// islands; nil means the parameter was absent.
+func queryLeagueID(c *gin.Context) (*int64, error) {
+	raw := c.Query("league_id")
+	if raw == "" {
+		return nil, nil
+	}
+	leagueID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || leagueID < 0 {
+		return nil, apperror.InvalidRequest("Provide a valid league_id")
+	}
+	return &leagueID, nil
+}
+
+// transactionFilter reads ?type=BUY|SELL, ?user_id=, ?player_id=,
+// ?league_id=, and the ?from= and ?to= time range.
+func transactionFilter(c *gin.Context) (models.TransactionFilter, error) {
+	var filter models.TransactionFilter
+	var err error
+	filter.Type = strings.ToUpper(c.Query("type"))
+	if filter.Type != "" && filter.Type != "BUY" && filter.Type != "SELL" {
+		return filter, apperror.InvalidRequest("type must be BUY or SELL")
+	}
+	if filter.UserID, err = queryID(c, "user_id"); err != nil {
+		return filter, err
+	}
+	if filter.PlayerID, err = queryID(c, "player_id"); err != nil {
+		return filter, err
+	}
+	if filter.LeagueID, err = queryLeagueID(c); err != nil {
+		return filter, err
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// positionFilter reads ?user_id=, ?player_id= and ?league_id=.
+func positionFilter(c *gin.Context) (models.PositionFilter, error) {
+	var filter models.PositionFilter
+	var err error
+	if filter.UserID, err = queryID(c, "user_id"); err != nil {
+		return filter, err
+	}
+	if filter.PlayerID, err = queryID(c, "player_id"); err != nil {
+		return filter, err
+	}
+	if filter.LeagueID, err = queryLeagueID(c); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// leagueVisible checks the caller may read leagueID's trades and positions,
+// aborting with the league's 404 if not.
+func (h *TransactionHandler) leagueVisible(c *gin.Context, leagueID int64) bool {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return false
+	}
+	err := h.TransactionService.EnsureLeagueVisible(c.Request.Context(), leagueID, claims.UserID, claims.Role == models.RoleAdmin)
 	if err != nil {
-		logger.Log.Warn("invalid user id parameter",
-			zap.String("param", idStr),
-			zap.String("route", c.FullPath()),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
+		abortWithError(c, apperror.From(err, "failed to fetch league"))
+		return false
+	}
+	return true
+}
+
+// listTransactions answers with a page of transactions matching the query
+// parameters, after fix has pinned any filter the route decides. Without
+// ?league_id= only admins see league trades; everyone else gets the global
+// islands.
+func (h *TransactionHandler) listTransactions(c *gin.Context, fix func(*models.TransactionFilter)) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	filter, err := transactionFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	fix(&filter)
+	if filter.LeagueID == nil && claims.Role != models.RoleAdmin {
+		filter.LeagueID = new(int64)
+	}
+	if filter.LeagueID != nil && !h.leagueVisible(c, *filter.LeagueID) {
+		return
+	}
+	req, err := parsePage(c, transactionListSpec)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	transactions, err := h.TransactionService.GetByUserID(ctx, id)
+	page, err := h.TransactionService.List(c.Request.Context(), filter, req)
 	if err != nil {
-		logger.Log.Error("failed to fetch transactions for user",
-			zap.Int64("user_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transactions"})
+		abortWithError(c, apperror.From(err, "failed to fetch transactions"))
 		return
 	}
+	c.JSON(http.StatusOK, page)
+}
 
-	if transactions == nil {
-		logger.Log.Debug("no transactions found for user",
-			zap.Int64("user_id", id),
-		)
-		c.JSON(http.StatusOK, []map[string]interface{}{})
+// listPositions answers with a page of positions matching the query
+// parameters, after fix has pinned any filter the route decides.
+func (h *TransactionHandler) listPositions(c *gin.Context, fix func(*models.PositionFilter)) {
+	filter, err := positionFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	fix(&filter)
+	if filter.LeagueID != nil && !h.leagueVisible(c, *filter.LeagueID) {
+		return
+	}
+	req, err := parsePage(c, positionListSpec)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	page, err := h.TransactionService.ListPositions(c.Request.Context(), filter, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch positions"))
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
-func (h *TransactionHandler) GetTransactionsOfPlayer(c *gin.Context) {
-	ctx := c.Request.Context()
-
+func (h *TransactionHandler) GetTransactionsOfUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		logger.Log.Warn("invalid player id parameter",
+		logger.Log.Warn("invalid user id parameter",
 			zap.String("param", idStr),
 			zap.String("route", c.FullPath()),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
-	transactions, err := h.TransactionService.GetByPlayerID(ctx, id)
-	if err != nil {
-		logger.Log.Error("failed to fetch transactions for player",
-			zap.Int64("player_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transactions"})
-		return
-	}
+	h.listTransactions(c, func(f *models.TransactionFilter) { f.UserID = id })
+}
 
-	if transactions == nil {
-		logger.Log.Debug("no transactions found for player",
-			zap.Int64("player_id", id),
+func (h *TransactionHandler) GetTransactionsOfPlayer(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		logger.Log.Warn("invalid player id parameter",
+			zap.String("param", idStr),
+			zap.String("route", c.FullPath()),
 		)
-		c.JSON(http.StatusOK, []map[string]interface{}{})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	h.listTransactions(c, func(f *models.TransactionFilter) { f.PlayerID = id })
 }
 
 func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
@@ -98,152 +211,158 @@ func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
 		logger.Log.Warn("invalid transaction id parameter",
 			zap.String("param", idStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
 	transaction, err := h.TransactionService.GetTransactionByID(ctx, id)
 	if err != nil {
-		logger.Log.Error("failed to fetch transaction by id",
-			zap.Int64("transaction_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transaction"})
+		abortWithError(c, apperror.Internal(err, "failed to fetch transaction"))
 		return
 	}
 
+	if transaction != nil && transaction.LeagueID != 0 {
+		claims, ok := currentClaims(c)
+		if !ok {
+			return
+		}
+		err := h.TransactionService.EnsureLeagueVisible(ctx, transaction.LeagueID, claims.UserID, claims.Role == models.RoleAdmin)
+		if errors.Is(err, service.ErrLeagueNotFound) {
+			transaction = nil
+		} else if err != nil {
+			abortWithError(c, apperror.Internal(err, "failed to fetch transaction"))
+			return
+		}
+	}
+
 	if transaction == nil {
 		logger.Log.Debug("transaction not found",
 			zap.Int64("transaction_id", id),
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find transaction"})
+		abortWithError(c, apperror.NotFound("TRANSACTION_NOT_FOUND", "Could not find transaction"))
 		return
 	}
 
 	c.JSON(http.StatusOK, transaction)
 }
 
+// GetTransactions lists every trade, newest first by default.
 func (h *TransactionHandler) GetTransactions(c *gin.Context) {
-	ctx := c.Request.Context()
-	transactions, err := h.TransactionService.GetAll(ctx)
-	if err != nil {
-		logger.Log.Error("failed to fetch all transactions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transactions"})
-		return
-	}
-
-	c.JSON(http.StatusOK, transactions)
+	h.listTransactions(c, func(*models.TransactionFilter) {})
 }
 
 func (h *TransactionHandler) BuyTransaction(c *gin.Context) {
 	ctx := c.Request.Context()
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
 	var req TransactionRequest
 	if err := c.BindJSON(&req); err != nil {
 		logger.Log.Warn("invalid buy transaction request body",
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		abortWithError(c, errInvalidRequest)
 		return
 	}
 
-	if err := h.TransactionService.Buy(ctx, req.UserID, req.PlayerID, req.Quantity); err != nil {
-		logger.Log.Error("failed to execute buy transaction",
-			zap.Int64("user_id", req.UserID),
-			zap.Int64("player_id", req.PlayerID),
-			zap.Int("quantity", req.Quantity),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not make purchase"})
+	transaction, err := h.TransactionService.Buy(ctx, claims.UserID, req.PlayerID, req.Quantity, req.LeagueID)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not make purchase"))
 		return
 	}
 
-	c.JSON(http.StatusOK, req)
+	c.JSON(http.StatusOK, transaction)
 }
 
 func (h *TransactionHandler) SellTransaction(c *gin.Context) {
 	ctx := c.Request.Context()
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
 	var req TransactionRequest
 	if err := c.BindJSON(&req); err != nil {
 		logger.Log.Warn("invalid sell transaction request body",
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		abortWithError(c, errInvalidRequest)
 		return
 	}
 
-	proceeds, err := h.TransactionService.Sell(ctx, req.UserID, req.PlayerID, req.Quantity)
+	transaction, err := h.TransactionService.Sell(ctx, claims.UserID, req.PlayerID, req.Quantity, req.LeagueID)
 	if err != nil {
-		logger.Log.Error("failed to execute sell transaction",
-			zap.Int64("user_id", req.UserID),
-			zap.Int64("player_id", req.PlayerID),
-			zap.Int("quantity", req.Quantity),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process trade"})
+		abortWithError(c, apperror.From(err, "Could not process trade"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"proceeds": proceeds})
+	proceeds := float64(transaction.Quantity)*transaction.Price - transaction.Fee
+	c.JSON(http.StatusOK, gin.H{
+		"proceeds":    proceeds,
+		"fee":         transaction.Fee,
+		"transaction": transaction,
+	})
 }
 
+// GetPositions lists positions on the global islands unless ?league_id=
+// says otherwise, like the per-player and per-user routes.
 func (h *TransactionHandler) GetPositions(c *gin.Context) {
-	ctx := c.Request.Context()
-	positions, err := h.TransactionService.GetPositions(ctx)
-	if err != nil {
-		logger.Log.Error("failed to fetch positions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch positions"})
-		return
-	}
-
-	c.JSON(http.StatusOK, positions)
+	h.listPositions(c, func(f *models.PositionFilter) {
+		if f.LeagueID == nil {
+			f.LeagueID = new(int64)
+		}
+	})
 }
 
+// GetPositionsOfPlayer lists who holds a player, on the global islands
+// unless ?league_id= says otherwise.
 func (h *TransactionHandler) GetPositionsOfPlayer(c *gin.Context) {
-	ctx := c.Request.Context()
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		logger.Log.Warn("invalid player id parameter",
 			zap.String("param", idStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
-		return
-	}
-
-	positions, err := h.TransactionService.GetPositionsByPlayerID(ctx, id)
-	if err != nil {
-		logger.Log.Error("failed to fetch positions for player",
-			zap.Int64("player_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch positions"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
-	c.JSON(http.StatusOK, positions)
+	h.listPositions(c, func(f *models.PositionFilter) {
+		f.PlayerID = id
+		if f.LeagueID == nil {
+			f.LeagueID = new(int64)
+		}
+	})
 }
 
+// GetPositionsOfUser lists a user's islands, on the global islands unless
+// ?league_id= says otherwise.
 func (h *TransactionHandler) GetPositionsOfUser(c *gin.Context) {
-	ctx := c.Request.Context()
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		logger.Log.Warn("invalid user id parameter",
 			zap.String("param", idStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
-	positions, err := h.TransactionService.GetPositionsByUserID(ctx, id)
+	h.listPositions(c, func(f *models.PositionFilter) {
+		f.UserID = id
+		if f.LeagueID == nil {
+			f.LeagueID = new(int64)
+		}
+	})
+}
+
+func (h *TransactionHandler) GetEconomySummary(c *gin.Context) {
+	ctx := c.Request.Context()
+	summary, err := h.TransactionService.GetEconomySummary(ctx)
 	if err != nil {
-		logger.Log.Error("failed to fetch positions for user",
-			zap.Int64("user_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch positions"})
+		abortWithError(c, apperror.Internal(err, "failed to fetch economy summary"))
 		return
 	}
 
-	c.JSON(http.StatusOK, positions)
+	c.JSON(http.StatusOK, summary)
 }
@@ -3,12 +3,14 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
-	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
 	"github.com/nbaisland/nbaisland/internal/service"
 )
 
@@ -16,17 +18,27 @@ type UserHandler struct {
 	UserService *service.UserService
 }
 
-func (h *UserHandler) GetUsers(c *gin.Context) {
-	ctx := c.Request.Context()
+var userListSpec = listSpec{
+	sorts:       []string{models.UserSortID, models.UserSortUsername, models.UserSortCurrency},
+	defaultSort: models.UserSortID,
+}
 
-	users, err := h.UserService.GetAll(ctx)
+// GetUsers lists users a page at a time, optionally matching ?q= against
+// usernames and names.
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	filter := models.UserFilter{Search: strings.TrimSpace(c.Query("q"))}
+	req, err := parsePage(c, userListSpec)
 	if err != nil {
-		logger.Log.Error("failed to fetch users", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	page, err := h.UserService.List(c.Request.Context(), filter, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "failed to fetch users"))
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *UserHandler) GetUserByID(c *gin.Context) {
@@ -39,17 +51,13 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 			zap.String("param", idStr),
 			zap.String("route", c.FullPath()),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
 	user, err := h.UserService.GetByID(ctx, id)
 	if err != nil {
-		logger.Log.Error("failed to fetch user by id",
-			zap.Int64("user_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		abortWithError(c, apperror.Internal(err, "failed to fetch user"))
 		return
 	}
 
@@ -57,7 +65,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		logger.Log.Info("user not found",
 			zap.Int64("user_id", id),
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		abortWithError(c, service.ErrUserNotFound)
 		return
 	}
 
@@ -72,17 +80,13 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 		logger.Log.Warn("missing username parameter",
 			zap.String("route", c.FullPath()),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a username"})
+		abortWithError(c, apperror.InvalidRequest("Provide a username"))
 		return
 	}
 
 	user, err := h.UserService.GetByUsername(ctx, username)
 	if err != nil {
-		logger.Log.Error("failed to fetch user by username",
-			zap.String("username", username),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		abortWithError(c, apperror.Internal(err, "failed to fetch user"))
 		return
 	}
 
@@ -90,7 +94,7 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 		logger.Log.Info("user not found by username",
 			zap.String("username", username),
 		)
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		abortWithError(c, service.ErrUserNotFound)
 		return
 	}
 
@@ -98,17 +102,11 @@ func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	claimsAny, ok := c.Get("user")
+	claims, ok := currentClaims(c)
 	if !ok {
-		logger.Log.Warn("unauthorized delete user attempt: missing claims",
-			zap.String("route", c.FullPath()),
-		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	claims := claimsAny.(*auth.Claims)
-
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
@@ -116,31 +114,66 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 			zap.String("param", idStr),
 			zap.Int64("auth_user_id", claims.UserID),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a valid id"})
-		return
-	}
-
-	if claims.UserID != id {
-		logger.Log.Warn("forbidden delete user attempt",
-			zap.Int64("auth_user_id", claims.UserID),
-			zap.Int64("target_user_id", id),
-		)
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own account"})
+		abortWithError(c, errInvalidID)
 		return
 	}
 
 	if err := h.UserService.DeleteUser(c.Request.Context(), id); err != nil {
-		logger.Log.Error("failed to delete user",
-			zap.Int64("user_id", id),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete user"})
+		abortWithError(c, apperror.Internal(err, "Could not delete user"))
 		return
 	}
 
 	logger.Log.Info("user deleted",
 		zap.Int64("user_id", id),
+		zap.Int64("admin_user_id", claims.UserID),
 	)
 
 	c.JSON(http.StatusOK, gin.H{"deleted_user_id": id})
 }
+
+// UpdatePrivacy is mounted behind middleware.RequireSelfOrAdmin.
+func (h *UserHandler) UpdatePrivacy(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	var req models.PrivacySettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+
+	if err := h.UserService.UpdatePrivacy(c.Request.Context(), id, &req); err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not update privacy settings"))
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+func (h *UserHandler) GetPrivacy(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	settings, err := h.UserService.GetPrivacy(ctx, id)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "failed to fetch privacy settings"))
+		return
+	}
+
+	if settings == nil {
+		abortWithError(c, service.ErrUserNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/service"
+)
+
+type WebhookHandler struct {
+	WebhookService *service.WebhookService
+}
+
+// CreatedWebhook is returned once, at creation; Secret is the only time the
+// signing secret is shown.
+type CreatedWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+var (
+	deliveryListSpec = listSpec{
+		sorts:       []string{models.DeliverySortCreatedAt},
+		defaultSort: models.DeliverySortCreatedAt,
+		defaultDesc: true,
+	}
+	deliveryStatuses = []string{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed}
+)
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	webhooks, err := h.WebhookService.GetByUserID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, apperror.Internal(err, "Could not load webhooks"))
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	var req service.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest)
+		return
+	}
+
+	isAdmin := claims.Role == models.RoleAdmin
+	webhook, secret, err := h.WebhookService.Create(c.Request.Context(), claims.UserID, isAdmin, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not create webhook"))
+		return
+	}
+
+	logger.Log.Info("Webhook created",
+		zap.Int64("user_id", claims.UserID),
+		zap.Int64("webhook_id", webhook.ID),
+		zap.Strings("event_types", webhook.EventTypes),
+	)
+	c.JSON(http.StatusCreated, CreatedWebhook{Webhook: webhook, Secret: secret})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid webhook id"))
+		return
+	}
+
+	if err := h.WebhookService.Delete(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithError(c, apperror.From(err, "Could not delete webhook"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetDeliveries answers with a page of the webhook's delivery log, narrowed
+// by ?status= when given.
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, apperror.InvalidRequest("Provide a valid webhook id"))
+		return
+	}
+	status := c.Query("status")
+	if status != "" && !slices.Contains(deliveryStatuses, status) {
+		abortWithError(c, apperror.InvalidRequest("status must be pending, delivered or failed"))
+		return
+	}
+	req, err := parsePage(c, deliveryListSpec)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	page, err := h.WebhookService.Deliveries(c.Request.Context(), claims.UserID, id, status, req)
+	if err != nil {
+		abortWithError(c, apperror.From(err, "Could not load webhook deliveries"))
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
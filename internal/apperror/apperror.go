@@ -0,0 +1,153 @@
+// Package apperror is the error type handlers and services return to API
+// clients. Each error has a stable UPPER_SNAKE code for programs, a message
+// for people, and a Kind that decides the HTTP status. The cause in Err is
+// logged but never sent to the client.
+package apperror
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindValidation
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindRateLimited
+	KindUpstream
+	KindUnavailable
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal:        http.StatusInternalServerError,
+	KindInvalid:         http.StatusBadRequest,
+	KindValidation:      http.StatusUnprocessableEntity,
+	KindUnauthenticated: http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindRateLimited:     http.StatusTooManyRequests,
+	KindUpstream:        http.StatusBadGateway,
+	KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+func (k Kind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Codes shared across handlers. Domain codes live next to the errors that
+// use them.
+const (
+	CodeInternal         = "INTERNAL"
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+)
+
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// Details are extra fields rendered alongside the code and message.
+	Details map[string]interface{}
+	Err     error
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches any *Error with the same code, so a sentinel still matches the
+// copies WithDetail and Wrap make of it.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// WithDetail returns a copy of e carrying an extra response field.
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	cp := *e
+	cp.Details = maps.Clone(e.Details)
+	if cp.Details == nil {
+		cp.Details = make(map[string]interface{})
+	}
+	cp.Details[key] = value
+	return &cp
+}
+
+// Wrap returns a copy of e recording err as the cause.
+func (e *Error) Wrap(err error) *Error {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+func Invalid(code string, message string) *Error {
+	return New(KindInvalid, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func Unauthenticated(code string, message string) *Error {
+	return New(KindUnauthenticated, code, message)
+}
+
+// InvalidRequest is the error for a body or parameter that cannot be parsed.
+func InvalidRequest(message string) *Error {
+	return New(KindInvalid, CodeInvalidRequest, message)
+}
+
+// Validation reports per-field problems with the request.
+func Validation(fields map[string]string) *Error {
+	return New(KindValidation, CodeValidationFailed, "Validation failed").WithDetail("fields", fields)
+}
+
+// Internal hides err behind message; err is only logged.
+func Internal(err error, message string) *Error {
+	return &Error{Kind: KindInternal, Code: CodeInternal, Message: message, Err: err}
+}
+
+// From returns err as an *Error when it is one. Any other error is treated
+// as internal and shown to the client as message.
+func From(err error, message string) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err, message)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+)
+
+var (
+    ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+    ErrRateLimited   = errors.New("API key rate limit exceeded")
+)
+
+// RateLimitError is ErrRateLimited with when the key's next window opens.
+type RateLimitError struct {
+    Limit      int
+    RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+    return fmt.Sprintf("%s: %d requests per minute", ErrRateLimited, e.Limit)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// IsAPIKey reports whether the claims came from an API key.
+func (c *Claims) IsAPIKey() bool {
+    return c.APIKeyID != 0
+}
+
+func (c *Claims) HasScope(scope string) bool {
+    return slices.Contains(c.Scopes, scope)
+}
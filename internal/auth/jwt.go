@@ -2,54 +2,73 @@ package auth
 
 import (
     "errors"
+    "sync"
     "time"
     "github.com/golang-jwt/jwt/v5"
 )
 
+var (
+    keysMu sync.RWMutex
+    keys   *KeySet
+)
 
-var jwtSecret = []byte("QAZWSXEDC")
+var ErrKeysNotConfigured = errors.New("JWT keys not configured")
 
 type Claims struct {
     UserID   int64    `json:"user_id"`
     Username string `json:"username"`
+    Role     string `json:"role"`
+    // SessionID is the refresh-token family the token was issued from, so
+    // logging out can revoke it before it expires.
+    SessionID string `json:"sid,omitempty"`
+    // APIKeyID and Scopes are set when the request authenticated with an
+    // API key rather than a token; they are never part of a signed token.
+    APIKeyID int64    `json:"-"`
+    Scopes   []string `json:"-"`
     jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int64, username string) (string, error) {
-    expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
-    
-    claims := &Claims{
-        UserID:   userID,
-        Username: username,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(expirationTime),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
+// Configure sets the keys GenerateToken and ValidateToken use. It is called
+// once at startup.
+func Configure(ks *KeySet) {
+    keysMu.Lock()
+    defer keysMu.Unlock()
+    keys = ks
+}
+
+func currentKeys() (*KeySet, error) {
+    keysMu.RLock()
+    defer keysMu.RUnlock()
+    if keys == nil {
+        return nil, ErrKeysNotConfigured
     }
+    return keys, nil
+}
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    tokenString, err := token.SignedString(jwtSecret)
+// GenerateToken signs an access token for the session and returns it with
+// its expiry.
+func GenerateToken(userID int64, username string, role string, sessionID string) (string, time.Time, error) {
+    ks, err := currentKeys()
     if err != nil {
-        return "", err
+        return "", time.Time{}, err
     }
-
-    return tokenString, nil
+    claims := &Claims{
+        UserID:    userID,
+        Username:  username,
+        Role:      role,
+        SessionID: sessionID,
+    }
+    token, err := ks.Sign(claims)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return token, claims.ExpiresAt.Time, nil
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
-    claims := &Claims{}
-
-    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-        return jwtSecret, nil
-    })
-
+    ks, err := currentKeys()
     if err != nil {
         return nil, err
     }
-
-    if !token.Valid {
-        return nil, errors.New("invalid token")
-    }
-
-    return claims, nil
-}
\ No newline at end of file
+    return ks.Validate(tokenString)
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/x509"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one JWT key identified by its kid. SignKey is nil for keys that are
+// only kept to validate tokens issued before a rotation.
+type Key struct {
+    ID        string
+    Method    jwt.SigningMethod
+    SignKey   interface{}
+    VerifyKey interface{}
+}
+
+// KeySet signs with its active key and validates with any of its keys, so
+// tokens signed by a retired key stay valid until they expire.
+type KeySet struct {
+    Active   string
+    Issuer   string
+    Audience string
+    TTL      time.Duration
+    keys     map[string]*Key
+}
+
+func NewKeySet(keys []*Key, active string, issuer string, audience string, ttl time.Duration) (*KeySet, error) {
+    if len(keys) == 0 {
+        return nil, errors.New("no JWT keys configured")
+    }
+    ks := &KeySet{
+        Active:   active,
+        Issuer:   issuer,
+        Audience: audience,
+        TTL:      ttl,
+        keys:     make(map[string]*Key, len(keys)),
+    }
+    for _, k := range keys {
+        if _, dup := ks.keys[k.ID]; dup {
+            return nil, fmt.Errorf("duplicate JWT kid %q", k.ID)
+        }
+        ks.keys[k.ID] = k
+    }
+    if ks.Active == "" {
+        ks.Active = keys[0].ID
+    }
+    signer, ok := ks.keys[ks.Active]
+    if !ok {
+        return nil, fmt.Errorf("active JWT kid %q is not configured", ks.Active)
+    }
+    if signer.SignKey == nil {
+        return nil, fmt.Errorf("active JWT kid %q has no private key", ks.Active)
+    }
+    return ks, nil
+}
+
+// EphemeralKeySet is a random HS256 key for development. Tokens do not
+// survive a restart.
+func EphemeralKeySet(issuer string, audience string, ttl time.Duration) (*KeySet, error) {
+    secret := make([]byte, 32)
+    if _, err := rand.Read(secret); err != nil {
+        return nil, err
+    }
+    key := &Key{ID: "dev", Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+    return NewKeySet([]*Key{key}, "dev", issuer, audience, ttl)
+}
+
+// ParseKeySpecs reads the JWT_KEYS format: comma-separated kid=alg:material
+// entries, where alg is hs256 with a secret, or rs256/eddsa with the path of
+// a PEM file. A PEM holding only a public key makes a validate-only key.
+//
+//    2025-01=hs256:<32+ byte secret>,2025-06=rs256:/etc/nbaisland/jwt-2025-06.pem
+func ParseKeySpecs(specs string) ([]*Key, error) {
+    var keys []*Key
+    for _, spec := range strings.Split(specs, ",") {
+        spec = strings.TrimSpace(spec)
+        if spec == "" {
+            continue
+        }
+        kid, rest, ok := strings.Cut(spec, "=")
+        alg, material, ok2 := strings.Cut(rest, ":")
+        if !ok || !ok2 || kid == "" || material == "" {
+            return nil, fmt.Errorf("invalid JWT key spec %q, want kid=alg:material", spec)
+        }
+        key, err := parseKey(kid, strings.ToLower(alg), material)
+        if err != nil {
+            return nil, fmt.Errorf("JWT key %q: %w", kid, err)
+        }
+        keys = append(keys, key)
+    }
+    return keys, nil
+}
+
+func parseKey(kid string, alg string, material string) (*Key, error) {
+    if alg == "hs256" {
+        secret := []byte(material)
+        if len(secret) < 32 {
+            return nil, errors.New("hs256 secrets must be at least 32 bytes")
+        }
+        return &Key{ID: kid, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}, nil
+    }
+
+    data, err := os.ReadFile(material)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, errors.New("no PEM block found")
+    }
+
+    switch alg {
+    case "rs256":
+        key := &Key{ID: kid, Method: jwt.SigningMethodRS256}
+        if block.Type == "PUBLIC KEY" {
+            pub, err := jwt.ParseRSAPublicKeyFromPEM(data)
+            if err != nil {
+                return nil, err
+            }
+            key.VerifyKey = pub
+            return key, nil
+        }
+        priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+        if err != nil {
+            return nil, err
+        }
+        key.SignKey, key.VerifyKey = priv, &priv.PublicKey
+        return key, nil
+
+    case "eddsa":
+        key := &Key{ID: kid, Method: jwt.SigningMethodEdDSA}
+        if block.Type == "PUBLIC KEY" {
+            pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+            if err != nil {
+                return nil, err
+            }
+            edPub, ok := pub.(ed25519.PublicKey)
+            if !ok {
+                return nil, errors.New("not an Ed25519 public key")
+            }
+            key.VerifyKey = edPub
+            return key, nil
+        }
+        priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, err
+        }
+        edPriv, ok := priv.(ed25519.PrivateKey)
+        if !ok {
+            return nil, errors.New("not an Ed25519 private key")
+        }
+        key.SignKey, key.VerifyKey = edPriv, edPriv.Public()
+        return key, nil
+    }
+    return nil, fmt.Errorf("unsupported algorithm %q, want hs256, rs256 or eddsa", alg)
+}
+
+func (ks *KeySet) Sign(claims *Claims) (string, error) {
+    key := ks.keys[ks.Active]
+    now := time.Now()
+    claims.Issuer = ks.Issuer
+    claims.Audience = jwt.ClaimStrings{ks.Audience}
+    claims.IssuedAt = jwt.NewNumericDate(now)
+    claims.ExpiresAt = jwt.NewNumericDate(now.Add(ks.TTL))
+
+    token := jwt.NewWithClaims(key.Method, claims)
+    token.Header["kid"] = key.ID
+    return token.SignedString(key.SignKey)
+}
+
+// Validate checks the signature against the key named by the token's kid,
+// and the token's expiry, issuer and audience.
+func (ks *KeySet) Validate(tokenString string) (*Claims, error) {
+    claims := &Claims{}
+    token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+        kid, _ := token.Header["kid"].(string)
+        key, ok := ks.keys[kid]
+        if !ok {
+            return nil, fmt.Errorf("unknown kid %q", kid)
+        }
+        // The key, not the token, decides the algorithm.
+        if token.Method.Alg() != key.Method.Alg() {
+            return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+        }
+        return key.VerifyKey, nil
+    },
+        jwt.WithIssuer(ks.Issuer),
+        jwt.WithAudience(ks.Audience),
+        jwt.WithExpirationRequired(),
+    )
+    if err != nil {
+        return nil, err
+    }
+    if !token.Valid {
+        return nil, errors.New("invalid token")
+    }
+    return claims, nil
+}
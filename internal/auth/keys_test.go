@@ -0,0 +1,182 @@
+package auth
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/x509"
+    "encoding/pem"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+func hsKey(kid string) *Key {
+    secret := []byte(strings.Repeat(kid, 32))
+    return &Key{ID: kid, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+}
+
+func edKey(t *testing.T, kid string) *Key {
+    t.Helper()
+    pub, priv, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, SignKey: priv, VerifyKey: pub}
+}
+
+// verifyOnly is k as kept after rotating away from it.
+func verifyOnly(k *Key) *Key {
+    return &Key{ID: k.ID, Method: k.Method, VerifyKey: k.VerifyKey}
+}
+
+func newKeySet(t *testing.T, keys []*Key, active string) *KeySet {
+    t.Helper()
+    ks, err := NewKeySet(keys, active, "nbaisland", "nbaisland-api", time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return ks
+}
+
+func sign(t *testing.T, ks *KeySet) string {
+    t.Helper()
+    token, err := ks.Sign(&Claims{UserID: 7, Username: "alice", Role: "user"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return token
+}
+
+func TestNewKeySetPicksActiveKey(t *testing.T) {
+    old, current := hsKey("a"), edKey(t, "b")
+    cases := []struct {
+        name    string
+        keys    []*Key
+        active  string
+        want    string
+        wantErr string
+    }{
+        {name: "named", keys: []*Key{old, current}, active: "b", want: "b"},
+        {name: "first by default", keys: []*Key{old, current}, want: "a"},
+        {name: "none", wantErr: "no JWT keys"},
+        {name: "unknown", keys: []*Key{old}, active: "z", wantErr: "not configured"},
+        {name: "duplicate", keys: []*Key{old, hsKey("a")}, wantErr: "duplicate"},
+        {name: "validate-only", keys: []*Key{verifyOnly(current), old}, active: "b", wantErr: "no private key"},
+    }
+    for _, tc := range cases {
+        ks, err := NewKeySet(tc.keys, tc.active, "nbaisland", "nbaisland-api", time.Hour)
+        if tc.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+                t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        token, _, err := jwt.NewParser().ParseUnverified(sign(t, ks), &Claims{})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if kid := token.Header["kid"]; kid != tc.want {
+            t.Errorf("%s: signed with kid %v, want %s", tc.name, kid, tc.want)
+        }
+    }
+}
+
+func TestValidateSelectsKeyByKid(t *testing.T) {
+    a, b := hsKey("a"), edKey(t, "b")
+    beforeRotation := newKeySet(t, []*Key{a}, "a")
+    afterRotation := newKeySet(t, []*Key{b, verifyOnly(a)}, "b")
+    unrelated := newKeySet(t, []*Key{hsKey("c")}, "c")
+    otherSecret := newKeySet(t, []*Key{{ID: "b", Method: jwt.SigningMethodHS256, SignKey: a.SignKey, VerifyKey: a.VerifyKey}}, "b")
+    otherIssuer, _ := NewKeySet([]*Key{b}, "b", "elsewhere", "nbaisland-api", time.Hour)
+    otherAudience, _ := NewKeySet([]*Key{b}, "b", "nbaisland", "elsewhere", time.Hour)
+    expired, _ := NewKeySet([]*Key{b}, "b", "nbaisland", "nbaisland-api", -time.Minute)
+
+    cases := []struct {
+        name   string
+        signer *KeySet
+        valid  bool
+    }{
+        {"active key", afterRotation, true},
+        {"retired key", beforeRotation, true},
+        {"unknown kid", unrelated, false},
+        {"algorithm not the kid's", otherSecret, false},
+        {"wrong issuer", otherIssuer, false},
+        {"wrong audience", otherAudience, false},
+        {"expired", expired, false},
+    }
+    for _, tc := range cases {
+        claims, err := afterRotation.Validate(sign(t, tc.signer))
+        if tc.valid && (err != nil || claims.UserID != 7) {
+            t.Errorf("%s: claims = %+v, err = %v, want valid", tc.name, claims, err)
+        }
+        if !tc.valid && err == nil {
+            t.Errorf("%s: token accepted", tc.name)
+        }
+    }
+}
+
+func TestParseKeySpecs(t *testing.T) {
+    dir := t.TempDir()
+    pub, priv, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    writePEM := func(name string, typ string, der []byte) string {
+        path := filepath.Join(dir, name)
+        if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+            t.Fatal(err)
+        }
+        return path
+    }
+    privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+    pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+    privPath := writePEM("priv.pem", "PRIVATE KEY", privDER)
+    pubPath := writePEM("pub.pem", "PUBLIC KEY", pubDER)
+    secret := strings.Repeat("s", 32)
+
+    cases := []struct {
+        name    string
+        spec    string
+        kids    []string
+        canSign []bool
+        wantErr bool
+    }{
+        {name: "hs256", spec: "2025-01=hs256:" + secret, kids: []string{"2025-01"}, canSign: []bool{true}},
+        {name: "eddsa pair and retired public key", spec: "new=eddsa:" + privPath + ", old=EdDSA:" + pubPath, kids: []string{"new", "old"}, canSign: []bool{true, false}},
+        {name: "blank entries skipped", spec: " ,a=hs256:" + secret + ",", kids: []string{"a"}, canSign: []bool{true}},
+        {name: "short secret", spec: "a=hs256:short", wantErr: true},
+        {name: "missing algorithm", spec: "a=" + secret, wantErr: true},
+        {name: "missing kid", spec: "=hs256:" + secret, wantErr: true},
+        {name: "unknown algorithm", spec: "a=es256:" + privPath, wantErr: true},
+        {name: "missing file", spec: "a=rs256:" + filepath.Join(dir, "nope.pem"), wantErr: true},
+    }
+    for _, tc := range cases {
+        keys, err := ParseKeySpecs(tc.spec)
+        if tc.wantErr {
+            if err == nil {
+                t.Errorf("%s: parsed %+v, want an error", tc.name, keys)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tc.name, err)
+            continue
+        }
+        if len(keys) != len(tc.kids) {
+            t.Errorf("%s: got %d keys, want %d", tc.name, len(keys), len(tc.kids))
+            continue
+        }
+        for i, k := range keys {
+            if k.ID != tc.kids[i] || (k.SignKey != nil) != tc.canSign[i] || k.VerifyKey == nil {
+                t.Errorf("%s: key %d = %s (signs %v), want %s (signs %v)", tc.name, i, k.ID, k.SignKey != nil, tc.kids[i], tc.canSign[i])
+            }
+        }
+    }
+}
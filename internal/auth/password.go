@@ -1,6 +1,11 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+    "strings"
+    "sync"
+
+    "golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
     bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -10,4 +15,24 @@ func HashPassword(password string) (string, error) {
 func CheckPassword(hashedPassword, password string) bool {
     err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
     return err == nil
-}
\ No newline at end of file
+}
+// dummyHash is compared against when a login names an unknown user, so the
+// response takes as long as a wrong password would.
+var dummyHash = sync.OnceValue(func() string {
+    hash, _ := HashPassword("not-a-real-password")
+    return hash
+})
+
+// UnusablePassword is stored for accounts that sign in only through an
+// external identity provider. No password matches it.
+const UnusablePassword = "!"
+
+// CheckPasswordOrDummy is CheckPassword that still spends the bcrypt time
+// when there is no usable stored hash, and then fails.
+func CheckPasswordOrDummy(hashedPassword, password string) bool {
+    if !strings.HasPrefix(hashedPassword, "$2") {
+        CheckPassword(dummyHash(), password)
+        return false
+    }
+    return CheckPassword(hashedPassword, password)
+}
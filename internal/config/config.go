@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"github.com/joho/godotenv"
 )
 
@@ -17,6 +19,71 @@ type Config struct {
 	CORSOrigin  string
 
 	ServerPort  string
+
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For is
+	// believed when working out a client's IP. Empty trusts no proxy, so the
+	// login throttle keys on the connection's address.
+	TrustedProxies []string
+
+	FeeBuyPct        float64
+	FeeSellPct       float64
+	FeeMinimum       float64
+	EarlyFlipPenalty float64
+	EarlyFlipDays    int
+
+	MinHoldHours int
+	LotTracking  string
+
+	// DividendPerStat is paid weekly per share for each point, rebound,
+	// assist, steal and block the player recorded that week.
+	DividendPerStat float64
+
+	// JWTKeys is a comma-separated list of kid=alg:material entries; see
+	// auth.ParseKeySpecs. JWTActiveKID picks the signing key, default the first.
+	JWTKeys      string
+	JWTActiveKID string
+	JWTIssuer    string
+	JWTAudience  string
+
+	// Access tokens are short-lived; clients renew them with a refresh token,
+	// which rotates on every use.
+	AccessTokenMinutes int
+	RefreshTokenDays   int
+
+	// Failed logins allowed before a username or an IP is locked out.
+	LoginMaxUserFailures int
+	LoginMaxIPFailures   int
+
+	// Mailer is "smtp" or "log"; the log mailer also writes .eml files to
+	// MailDir. AppURL is the web app that emailed links open.
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	MailFrom     string
+	MailDir      string
+	AppURL       string
+
+	RequireVerifiedEmail bool
+
+	// WebhookAllowPrivate lets webhooks deliver to loopback and private
+	// network addresses, for local development only.
+	WebhookAllowPrivate bool
+
+	// OIDCProviders come from OIDC_PROVIDERS, a comma-separated list of
+	// names, each configured by OIDC_<NAME>_ISSUER, _CLIENT_ID,
+	// _CLIENT_SECRET, _REDIRECT_URL and _SCOPES.
+	OIDCProviders []OIDCProvider
+}
+
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 func Load() *Config {
@@ -37,8 +104,47 @@ func Load() *Config {
 
 
         ServerPort: getEnv("SERVER_PORT", "8080"),
+
+        TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+        FeeBuyPct:        getEnvFloat("FEE_BUY_PCT", 0.01),
+        FeeSellPct:       getEnvFloat("FEE_SELL_PCT", 0.01),
+        FeeMinimum:       getEnvFloat("FEE_MINIMUM", 1.0),
+        EarlyFlipPenalty: getEnvFloat("EARLY_FLIP_PENALTY_PCT", 0.10),
+        EarlyFlipDays:    getEnvInt("EARLY_FLIP_DAYS", 7),
+
+        MinHoldHours: getEnvInt("MIN_HOLD_HOURS", 24),
+        LotTracking:  getEnv("LOT_TRACKING", "fifo"),
+
+        DividendPerStat: getEnvFloat("DIVIDEND_PER_STAT", 0.05),
+
+        JWTKeys:      getEnv("JWT_KEYS", ""),
+        JWTActiveKID: getEnv("JWT_ACTIVE_KID", ""),
+        JWTIssuer:    getEnv("JWT_ISSUER", "nbaisland"),
+        JWTAudience:  getEnv("JWT_AUDIENCE", "nbaisland-api"),
+
+        AccessTokenMinutes: getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15),
+        RefreshTokenDays:   getEnvInt("REFRESH_TOKEN_TTL_DAYS", 30),
+
+        LoginMaxUserFailures: getEnvInt("LOGIN_MAX_USER_FAILURES", 5),
+        LoginMaxIPFailures:   getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+
+        Mailer:       getEnv("MAILER", "log"),
+        SMTPHost:     getEnv("SMTP_HOST", ""),
+        SMTPPort:     getEnv("SMTP_PORT", "587"),
+        SMTPUser:     getEnv("SMTP_USER", ""),
+        SMTPPassword: getEnv("SMTP_PASS", ""),
+        MailFrom:     getEnv("MAIL_FROM", "NBA Island <no-reply@nbaisland.local>"),
+        MailDir:      getEnv("MAIL_DIR", "logs/mail"),
+        AppURL:       getEnv("APP_URL", "http://127.0.0.1:5173"),
+
+        RequireVerifiedEmail: getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+
+        WebhookAllowPrivate: getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
     }
 
+	c.OIDCProviders = loadOIDCProviders(c.AppURL)
+
 	if c.DBHost == "" || c.DBUser == "" || c.DBPassword == "" || c.DBName == "" {
 		log.Fatal("Missing db ENV Variables!!!")
 	}
@@ -51,4 +157,78 @@ func getEnv(key, defaultstr string) string {
 		return val
 	}
 	return defaultstr
-}
\ No newline at end of file
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %v", key, val, defaultVal)
+		return defaultVal
+	}
+	return f
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %v", key, val, defaultVal)
+		return defaultVal
+	}
+	return i
+}
+func getEnvBool(key string, defaultVal bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %v", key, val, defaultVal)
+		return defaultVal
+	}
+	return b
+}
+
+func loadOIDCProviders(appURL string) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := OIDCProvider{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", strings.TrimRight(appURL, "/")+"/auth/callback/"+name),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Printf("OIDC provider %s needs %sISSUER and %sCLIENT_ID, skipping", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
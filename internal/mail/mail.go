@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+)
+
+var ErrInvalidHeader = errors.New("mail header contains a line break")
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends plain-text email. SMTPMailer is used in production; LogMailer
+// stands in during development so links can be followed from the logs.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// render builds the RFC 5322 message, refusing header values that could
+// smuggle in extra headers.
+func render(from string, msg Message) ([]byte, error) {
+	for _, v := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// From is the sender as it appears in the From header, e.g.
+	// "NBA Island <no-reply@example.com>"; the envelope uses its address.
+	From string
+	// Timeout bounds a whole send when ctx has no earlier deadline. Zero
+	// means 30 seconds.
+	Timeout time.Duration
+}
+
+// Send delivers msg over one SMTP connection, upgrading it with STARTTLS
+// when the server offers it. The connection is closed when ctx is done.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("mail: invalid sender %q: %w", m.From, err)
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mail: invalid recipient: %w", err)
+	}
+	body, err := render(from.String(), msg)
+	if err != nil {
+		return err
+	}
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, m.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// LogMailer logs each message and, when Dir is set, also writes it there as
+// an .eml file.
+type LogMailer struct {
+	From string
+	Dir  string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	body, err := render(m.From, msg)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Email not sent, logged instead",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	if m.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), strings.ReplaceAll(msg.To, "/", "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0644)
+}
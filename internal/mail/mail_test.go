@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer answers one SMTP session on a loopback port and sends the
+// commands and message data it received on the returned channel.
+func smtpServer(t *testing.T) (string, string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var lines []string
+		reply("220 test ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				got <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 test")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				got <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return host, port, got
+}
+
+func TestSMTPMailerUsesBareEnvelopeAddresses(t *testing.T) {
+	host, port, got := smtpServer(t)
+	m := &SMTPMailer{Host: host, Port: port, From: "NBA Island <no-reply@nbaisland.local>"}
+
+	err := m.Send(context.Background(), Message{To: "Player <player@example.com>", Subject: "Hi", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	lines := strings.Join(<-got, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@nbaisland.local>",
+		"RCPT TO:<player@example.com>",
+		`From: "NBA Island" <no-reply@nbaisland.local>`,
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("session lacks %q:\n%s", want, lines)
+		}
+	}
+}
+
+func TestSMTPMailerRejectsInvalidSender(t *testing.T) {
+	m := &SMTPMailer{Host: "127.0.0.1", Port: "25", From: "not an address"}
+	if err := m.Send(context.Background(), Message{To: "player@example.com"}); err == nil {
+		t.Error("Send succeeded with an invalid sender")
+	}
+}
+
+func TestSMTPMailerGivesUpOnSilentServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept and never greet.
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	m := &SMTPMailer{Host: host, Port: port, From: "no-reply@nbaisland.local", Timeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	if err := m.Send(context.Background(), Message{To: "player@example.com"}); err == nil {
+		t.Fatal("Send succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestRenderRejectsHeaderInjection(t *testing.T) {
+	if _, err := render("a@example.com", Message{To: "b@example.com", Subject: "Hi\r\nBcc: c@example.com"}); err != ErrInvalidHeader {
+		t.Errorf("err = %v, want ErrInvalidHeader", err)
+	}
+}
@@ -1,57 +1,156 @@
 package middleware
 
 import (
+    "context"
+    "errors"
     "net/http"
+    "strconv"
     "strings"
 
     "github.com/gin-gonic/gin"
+    "github.com/nbaisland/nbaisland/internal/apperror"
     "github.com/nbaisland/nbaisland/internal/auth"
     "github.com/nbaisland/nbaisland/internal/logger"
+    "github.com/nbaisland/nbaisland/internal/models"
     "go.uber.org/zap"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// SessionChecker reports whether a session family is still live. Logging out
+// revokes the family, which cuts off its access tokens before they expire.
+type SessionChecker interface {
+    IsActive(ctx context.Context, familyID string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves an X-API-Key header to claims for the key's
+// owner, returning auth.ErrInvalidAPIKey or an *auth.RateLimitError when the
+// key may not be used.
+type APIKeyAuthenticator interface {
+    AuthenticateAPIKey(ctx context.Context, key string) (*auth.Claims, error)
+}
+
+var errInvalidToken = apperror.Unauthenticated("INVALID_TOKEN", "Invalid or expired token")
+
+// AuthMiddleware accepts a bearer access token or, when apiKeys is set, an
+// X-API-Key header. API keys are limited by scope: read allows safe methods,
+// anything else needs trade.
+func AuthMiddleware(sessions SessionChecker, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
     return func(c *gin.Context) {
-        authHeader := c.GetHeader("Authorization")
-        if authHeader == "" {
-            logger.Log.Warn("Missing authorization header",
-                zap.String("path", c.Request.URL.Path),
-                zap.String("ip", c.ClientIP()),
-            )
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-            c.Abort()
-            return
+        var claims *auth.Claims
+        var ok bool
+        if key := c.GetHeader("X-API-Key"); key != "" && apiKeys != nil {
+            claims, ok = authenticateAPIKey(c, apiKeys, key)
+        } else {
+            claims, ok = authenticateBearer(c, sessions)
         }
-
-        parts := strings.Split(authHeader, " ")
-        if len(parts) != 2 || parts[0] != "Bearer" {
-            logger.Log.Warn("Invalid authorization header format",
-                zap.String("path", c.Request.URL.Path),
-                zap.String("ip", c.ClientIP()),
-            )
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-            c.Abort()
+        if !ok {
             return
         }
 
-        tokenString := parts[1]
-
-        claims, err := auth.ValidateToken(tokenString)
-        if err != nil {
-            logger.Log.Warn("Invalid or expired token",
-                zap.Error(err),
-                zap.String("path", c.Request.URL.Path),
-                zap.String("ip", c.ClientIP()),
-            )
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-            c.Abort()
-            return
-        }
         c.Set("user", claims)
         c.Set("user_id", claims.UserID)
         c.Set("username", claims.Username)
-        // c.Set("is_admin", claims.IsAdmin)
+        c.Set("role", claims.Role)
+
+        c.Next()
+    }
+}
+
+func authenticateBearer(c *gin.Context, sessions SessionChecker) (*auth.Claims, bool) {
+    authHeader := c.GetHeader("Authorization")
+    if authHeader == "" {
+        logger.Log.Warn("Missing authorization header",
+            zap.String("path", c.Request.URL.Path),
+            zap.String("ip", c.ClientIP()),
+        )
+        abortWith(c, apperror.Unauthenticated(apperror.CodeUnauthorized, "Authorization header required"))
+        return nil, false
+    }
+
+    parts := strings.Split(authHeader, " ")
+    if len(parts) != 2 || parts[0] != "Bearer" {
+        logger.Log.Warn("Invalid authorization header format",
+            zap.String("path", c.Request.URL.Path),
+            zap.String("ip", c.ClientIP()),
+        )
+        abortWith(c, apperror.Unauthenticated(apperror.CodeUnauthorized, "Invalid authorization header format"))
+        return nil, false
+    }
+
+    tokenString := parts[1]
 
+    claims, err := auth.ValidateToken(tokenString)
+    if err != nil {
+        logger.Log.Warn("Invalid or expired token",
+            zap.Error(err),
+            zap.String("path", c.Request.URL.Path),
+            zap.String("ip", c.ClientIP()),
+        )
+        abortWith(c, errInvalidToken)
+        return nil, false
+    }
+
+    if claims.SessionID == "" {
+        abortWith(c, errInvalidToken)
+        return nil, false
+    }
+    active, err := sessions.IsActive(c.Request.Context(), claims.SessionID)
+    if err != nil {
+        abortWith(c, apperror.Internal(err, "Could not verify session"))
+        return nil, false
+    }
+    if !active {
+        logger.Log.Warn("Token from a revoked session",
+            zap.Int64("user_id", claims.UserID),
+            zap.String("path", c.Request.URL.Path),
+            zap.String("ip", c.ClientIP()),
+        )
+        abortWith(c, apperror.Unauthenticated("SESSION_REVOKED", "Session has been revoked"))
+        return nil, false
+    }
+    return claims, true
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string) (*auth.Claims, bool) {
+    claims, err := apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+    var limited *auth.RateLimitError
+    switch {
+    case errors.As(err, &limited):
+        c.Header("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())+1))
+        abortWith(c, apperror.New(apperror.KindRateLimited, "RATE_LIMITED", limited.Error()).WithDetail("retry_after", int(limited.RetryAfter.Seconds())+1))
+        return nil, false
+    case errors.Is(err, auth.ErrInvalidAPIKey):
+        logger.Log.Warn("Invalid API key",
+            zap.String("path", c.Request.URL.Path),
+            zap.String("ip", c.ClientIP()),
+        )
+        abortWith(c, apperror.Unauthenticated("INVALID_API_KEY", err.Error()))
+        return nil, false
+    case err != nil:
+        abortWith(c, apperror.Internal(err, "Could not verify API key"))
+        return nil, false
+    }
+
+    needed := models.ScopeTrade
+    switch c.Request.Method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        needed = models.ScopeRead
+    }
+    if !claims.HasScope(needed) {
+        abortWith(c, apperror.Forbidden("INSUFFICIENT_SCOPE", "API key lacks the "+needed+" scope"))
+        return nil, false
+    }
+    return claims, true
+}
+
+// RequireSession rejects API keys on routes that manage the account itself,
+// such as sessions and API keys. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        claims, _ := c.Get("user")
+        if authClaims, ok := claims.(*auth.Claims); ok && authClaims.IsAPIKey() {
+            abortWith(c, apperror.Forbidden("SESSION_REQUIRED", "This endpoint needs a signed-in session, not an API key"))
+            return
+        }
         c.Next()
     }
-}
\ No newline at end of file
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/nbaisland/nbaisland/internal/apperror"
+    "github.com/nbaisland/nbaisland/internal/logger"
+    "go.uber.org/zap"
+)
+
+// ErrorHandler renders the last error a handler or middleware recorded with
+// c.Error, as
+//
+//    {"error": message, "code": CODE, "request_id": id, ...details}
+//
+// Errors that are not *apperror.Error become a 500 without their text.
+func ErrorHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Next()
+
+        if len(c.Errors) == 0 || c.Writer.Written() {
+            return
+        }
+        appErr := apperror.From(c.Errors.Last().Err, "Internal server error")
+        requestID := c.GetString("request_id")
+
+        fields := []zap.Field{
+            zap.String("code", appErr.Code),
+            zap.String("method", c.Request.Method),
+            zap.String("path", c.Request.URL.Path),
+            zap.Int64("user_id", c.GetInt64("user_id")),
+            zap.String("request_id", requestID),
+        }
+        if appErr.Status() >= 500 {
+            logger.Log.Error("Request failed", append(fields, zap.Error(appErr))...)
+        } else {
+            logger.Log.Debug("Request rejected", append(fields, zap.String("message", appErr.Message))...)
+        }
+
+        body := gin.H{"error": appErr.Message, "code": appErr.Code}
+        if requestID != "" {
+            body["request_id"] = requestID
+        }
+        for key, value := range appErr.Details {
+            body[key] = value
+        }
+        c.AbortWithStatusJSON(appErr.Status(), body)
+    }
+}
+
+// abortWith records err for ErrorHandler and stops the chain.
+func abortWith(c *gin.Context, err *apperror.Error) {
+    c.Error(err)
+    c.Abort()
+}
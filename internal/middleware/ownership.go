@@ -0,0 +1,36 @@
+package middleware
+
+import (
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/nbaisland/nbaisland/internal/apperror"
+    "github.com/nbaisland/nbaisland/internal/logger"
+    "github.com/nbaisland/nbaisland/internal/models"
+    "go.uber.org/zap"
+)
+
+// RequireSelfOrAdmin guards routes acting on a user resource: the user ID in
+// the param route parameter must be the authenticated user's own, unless
+// they are an admin. It must run after AuthMiddleware.
+func RequireSelfOrAdmin(param string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        target, err := strconv.ParseInt(c.Param(param), 10, 64)
+        if err != nil {
+            abortWith(c, apperror.InvalidRequest("Provide a valid id"))
+            return
+        }
+
+        if c.GetInt64("user_id") == target || c.GetString("role") == models.RoleAdmin {
+            c.Next()
+            return
+        }
+
+        logger.Log.Warn("Forbidden: acting on another user",
+            zap.String("path", c.Request.URL.Path),
+            zap.Int64("auth_user_id", c.GetInt64("user_id")),
+            zap.Int64("target_user_id", target),
+        )
+        abortWith(c, apperror.Forbidden(apperror.CodeForbidden, "You can only act on your own account"))
+    }
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/nbaisland/nbaisland/internal/apperror"
+    "github.com/nbaisland/nbaisland/internal/logger"
+    "go.uber.org/zap"
+)
+
+// RequireRole lets a request through only if AuthMiddleware authenticated a
+// user holding one of roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        role := c.GetString("role")
+        for _, allowed := range roles {
+            if role == allowed {
+                c.Next()
+                return
+            }
+        }
+
+        logger.Log.Warn("Forbidden: missing role",
+            zap.String("path", c.Request.URL.Path),
+            zap.Any("user_id", c.Value("user_id")),
+            zap.String("role", role),
+            zap.Strings("required", roles),
+        )
+        abortWith(c, apperror.Forbidden(apperror.CodeForbidden, "Insufficient permissions"))
+    }
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+    TokenVerifyEmail   = "verify_email"
+    TokenResetPassword = "reset_password"
+)
+
+// AccountToken is a single-use token mailed to a user to prove they control
+// their email address.
+type AccountToken struct {
+    ID        int64
+    UserID    int64
+    Purpose   string
+    TokenHash string
+    CreatedAt time.Time
+    ExpiresAt time.Time
+    UsedAt    *time.Time
+}
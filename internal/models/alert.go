@@ -0,0 +1,94 @@
+package models
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+)
+
+// Price alert kinds. Above and below compare a player's value to Threshold;
+// change compares the percent move over the last WindowHours to Threshold,
+// in either direction.
+const (
+    AlertAbove  = "above"
+    AlertBelow  = "below"
+    AlertChange = "change"
+)
+
+// WatchlistItem is a player on a user's watchlist, with the player's current
+// value.
+type WatchlistItem struct {
+    PlayerID int64     `json:"player_id"`
+    Name     string    `json:"name"`
+    Slug     string    `json:"slug"`
+    Value    float64   `json:"value"`
+    AddedAt  time.Time `json:"added_at"`
+}
+
+// PriceAlert is a rule that notifies its user when a player's value meets it.
+// It is disarmed after firing and rearmed once the condition no longer
+// holds, so each crossing fires once.
+type PriceAlert struct {
+    ID              int64      `json:"id"`
+    UserID          int64      `json:"user_id"`
+    PlayerID        int64      `json:"player_id"`
+    Kind            string     `json:"kind"`
+    Threshold       float64    `json:"threshold"`
+    WindowHours     *int       `json:"window_hours,omitempty"`
+    Email           bool       `json:"email"`
+    Armed           bool       `json:"armed"`
+    LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+    CreatedAt       time.Time  `json:"created_at"`
+}
+
+// FiredAlert is an alert that has just fired, with what it fired on and where
+// to email its user. ReferenceValue is the value WindowHours ago for change
+// alerts. EmailTo is empty unless the alert asks for email and the user's
+// address is verified. Notification is the in-app notification stored when
+// the alert fired.
+type FiredAlert struct {
+    *PriceAlert
+    PlayerName     string
+    Value          float64
+    ReferenceValue *float64
+    EmailTo        string
+    Notification   *Notification
+}
+
+// NewNotification describes the firing to the alert's user.
+func (f *FiredAlert) NewNotification() *Notification {
+    var body string
+    switch f.Kind {
+    case AlertAbove:
+        body = fmt.Sprintf("%s is now worth %.2f, at or above your alert at %.2f.", f.PlayerName, f.Value, f.Threshold)
+    case AlertBelow:
+        body = fmt.Sprintf("%s is now worth %.2f, at or below your alert at %.2f.", f.PlayerName, f.Value, f.Threshold)
+    case AlertChange:
+        var from float64
+        if f.ReferenceValue != nil {
+            from = *f.ReferenceValue
+        }
+        hours := 0
+        if f.WindowHours != nil {
+            hours = *f.WindowHours
+        }
+        body = fmt.Sprintf("%s moved from %.2f to %.2f in the last %d hours, past your %.1f%% alert.", f.PlayerName, from, f.Value, hours, f.Threshold)
+    }
+
+    data, _ := json.Marshal(map[string]interface{}{
+        "alert_id":        f.ID,
+        "kind":            f.Kind,
+        "threshold":       f.Threshold,
+        "value":           f.Value,
+        "reference_value": f.ReferenceValue,
+    })
+    playerID := f.PlayerID
+    return &Notification{
+        UserID:   f.UserID,
+        Type:     NotificationPriceAlert,
+        Title:    "Price alert: " + f.PlayerName,
+        Body:     body,
+        PlayerID: &playerID,
+        Data:     data,
+    }
+}
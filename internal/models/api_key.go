@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// API key scopes. Read allows GET requests; trade also allows requests that
+// change state, such as buying and selling. Keys never carry admin rights or
+// manage the account or other keys.
+const (
+    ScopeRead  = "read"
+    ScopeTrade = "trade"
+)
+
+type APIKey struct {
+    ID                 int64      `json:"id"`
+    UserID             int64      `json:"user_id"`
+    Name               string     `json:"name"`
+    Prefix             string     `json:"prefix"`
+    KeyHash            string     `json:"-"`
+    Scopes             []string   `json:"scopes"`
+    RateLimitPerMinute int        `json:"rate_limit_per_minute"`
+    CreatedAt          time.Time  `json:"created_at"`
+    ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyUse is an authenticated key with its owner and its request count in
+// the current one-minute window.
+type APIKeyUse struct {
+    Key         *APIKey
+    Username    string
+    WindowStart time.Time
+    WindowCount int
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Claim records a user's first buy of a player. Each claim's Hash covers its
+// own fields and PrevHash, chaining every claim to the one before it.
+type Claim struct {
+    ID            int64     `json:"id"`
+    UserID        int64     `json:"user_id"`
+    PlayerID      int64     `json:"player_id"`
+    TransactionID int64     `json:"transaction_id"`
+    Rank          int       `json:"rank"`
+    EntryValue    float64   `json:"entry_value"`
+    ClaimedAt     time.Time `json:"claimed_at"`
+    PrevHash      string    `json:"prev_hash"`
+    Hash          string    `json:"hash"`
+}
+
+type ClaimVerification struct {
+    Valid         bool   `json:"valid"`
+    ClaimsChecked int    `json:"claims_checked"`
+    BrokenAtID    *int64 `json:"broken_at_id,omitempty"`
+    Receipt       *Claim `json:"receipt,omitempty"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+const (
+    DraftPending   = "pending"
+    DraftActive    = "active"
+    DraftCompleted = "completed"
+)
+
+type Draft struct {
+    ID            int64       `json:"id"`
+    LeagueID      int64       `json:"league_id"`
+    SeasonID      int64       `json:"season_id"`
+    Status        string      `json:"status"`
+    Rounds        int         `json:"rounds"`
+    PickSeconds   int         `json:"pick_seconds"`
+    SharesPerPick int         `json:"shares_per_pick"`
+    MaxIslands    int         `json:"max_islands"`
+    PricePct      float64     `json:"price_pct"`
+    CurrentPick   int         `json:"current_pick"`
+    PickDeadline  *time.Time  `json:"pick_deadline"`
+    CreatedAt     time.Time   `json:"created_at"`
+    StartedAt     *time.Time  `json:"started_at"`
+    CompletedAt   *time.Time  `json:"completed_at"`
+    OnTheClock    *int64      `json:"on_the_clock,omitempty"`
+    Order         []DraftSlot `json:"order"`
+    Picks         []DraftPick `json:"picks"`
+}
+
+// TotalPicks is how many picks the draft runs for once its order is set.
+func (d *Draft) TotalPicks() int {
+    return d.Rounds * len(d.Order)
+}
+
+type DraftSlot struct {
+    Slot     int    `json:"slot"`
+    UserID   int64  `json:"user_id"`
+    Username string `json:"username"`
+}
+
+type DraftPick struct {
+    PickNumber    int       `json:"pick_number"`
+    Round         int       `json:"round"`
+    UserID        int64     `json:"user_id"`
+    PlayerID      *int64    `json:"player_id"`
+    Quantity      int       `json:"quantity"`
+    Price         float64   `json:"price"`
+    Auto          bool      `json:"auto"`
+    TransactionID *int64    `json:"transaction_id,omitempty"`
+    PickedAt      time.Time `json:"picked_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type EconomySummary struct {
+    Users                 int     `json:"users"`
+    CurrencyInCirculation float64 `json:"currency_in_circulation"`
+    PositionMarketValue   float64 `json:"position_market_value"`
+    TradeCount            int     `json:"trade_count"`
+    TradeVolume           float64 `json:"trade_volume"`
+    BuyFeesCollected      float64 `json:"buy_fees_collected"`
+    SellFeesCollected     float64 `json:"sell_fees_collected"`
+    TotalFeesCollected    float64 `json:"total_fees_collected"`
+}
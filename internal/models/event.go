@@ -0,0 +1,28 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// Market event types. They are also the SSE event names on the stream.
+const (
+    EventPriceChanged  = "price.changed"
+    EventTradeExecuted = "trade.executed"
+    EventDividendPaid  = "dividend.paid"
+    // EventIngestionFailed reports a failed NBA stats import; only admins'
+    // webhooks receive it.
+    EventIngestionFailed = "ingestion.failed"
+)
+
+// MarketEvent is something that happened on the islands, as sent over
+// Postgres NOTIFY to stream subscribers and queued for webhooks. PlayerID, UserID and
+// LeagueID say who it concerns, for filtering; Data is the type's payload.
+type MarketEvent struct {
+    Type     string          `json:"type"`
+    PlayerID int64           `json:"player_id,omitempty"`
+    UserID   int64           `json:"user_id,omitempty"`
+    LeagueID int64           `json:"league_id,omitempty"`
+    At       time.Time       `json:"at"`
+    Data     json.RawMessage `json:"data,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// UserIdentity links an account at an OIDC provider, by its subject, to a
+// local user.
+type UserIdentity struct {
+    ID          int64      `json:"id"`
+    UserID      int64      `json:"user_id"`
+    Provider    string     `json:"provider"`
+    Subject     string     `json:"-"`
+    Email       string     `json:"email"`
+    CreatedAt   time.Time  `json:"created_at"`
+    LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCState is an OIDC sign-in waiting for the provider's callback. UserID is
+// set when a signed-in user is linking a new identity.
+type OIDCState struct {
+    State        string
+    Provider     string
+    Nonce        string
+    CodeVerifier string
+    UserID       *int64
+    ExpiresAt    time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+    LeaderboardNetWorth      = "net_worth"
+    LeaderboardReturn        = "return"
+    LeaderboardEarlyBeliever = "early_believer"
+)
+
+type LeaderboardEntry struct {
+    Rank     int     `json:"rank"`
+    UserID   int64   `json:"user_id"`
+    Username string  `json:"username"`
+    Score    float64 `json:"score"`
+}
+
+type Leaderboard struct {
+    Kind    string              `json:"kind"`
+    Window  string              `json:"window"`
+    Page    int                 `json:"page"`
+    Limit   int                 `json:"limit"`
+    Total   int                 `json:"total"`
+    Entries []*LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardSnapshot struct {
+    Date  time.Time `json:"date"`
+    Rank  int       `json:"rank"`
+    Score float64   `json:"score"`
+}
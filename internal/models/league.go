@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type League struct {
+    ID               int64     `json:"id"`
+    Name             string    `json:"name"`
+    InviteCode       string    `json:"invite_code,omitempty"`
+    OwnerID          int64     `json:"owner_id"`
+    StartingCurrency *float64  `json:"starting_currency,omitempty"`
+    IslandCapacity   *int      `json:"island_capacity,omitempty"`
+    CreatedAt        time.Time `json:"created_at"`
+}
+
+// Contained leagues run their own game: members get a league wallet and
+// trade on league islands instead of the global ones.
+func (l *League) Contained() bool {
+    return l.StartingCurrency != nil && l.IslandCapacity != nil
+}
+
+type LeagueMember struct {
+    LeagueID int64     `json:"league_id"`
+    UserID   int64     `json:"user_id"`
+    Username string    `json:"username"`
+    Currency *float64  `json:"currency,omitempty"`
+    JoinedAt time.Time `json:"joined_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+    LoginFailureUnknownUser = "unknown_user"
+    LoginFailureBadPassword = "bad_password"
+    LoginFailureLockedOut   = "locked_out"
+)
+
+// FailedLogin is the audit record of one rejected login attempt.
+type FailedLogin struct {
+    ID        int64     `json:"id"`
+    Username  string    `json:"username"`
+    UserID    *int64    `json:"user_id,omitempty"`
+    IP        string    `json:"ip"`
+    UserAgent string    `json:"user_agent"`
+    Reason    string    `json:"reason"`
+    CreatedAt time.Time `json:"created_at"`
+}
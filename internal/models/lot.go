@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Lot is the remaining quantity of a single BUY after earlier sells have
+// been matched against it first-in, first-out.
+type Lot struct {
+    TransactionID int64     `json:"transaction_id"`
+    Quantity      int       `json:"quantity"`
+    Price         float64   `json:"price"`
+    AcquiredAt    time.Time `json:"acquired_at"`
+    UnlocksAt     time.Time `json:"unlocks_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// Notification types.
+const (
+    NotificationPriceAlert = "price_alert"
+    NotificationDividend   = "dividend"
+)
+
+// Sorts accepted by the notification list.
+const (
+    NotificationSortCreatedAt = "created_at"
+)
+
+// Notification is an in-app message for a user. It is unread until ReadAt
+// is set.
+type Notification struct {
+    ID        int64           `json:"id"`
+    UserID    int64           `json:"user_id"`
+    Type      string          `json:"type"`
+    Title     string          `json:"title"`
+    Body      string          `json:"body"`
+    PlayerID  *int64          `json:"player_id,omitempty"`
+    Data      json.RawMessage `json:"data,omitempty"`
+    ReadAt    *time.Time      `json:"read_at,omitempty"`
+    CreatedAt time.Time       `json:"created_at"`
+}
+
+type NotificationFilter struct {
+    UserID     int64
+    UnreadOnly bool
+}
@@ -0,0 +1,17 @@
+package models
+
+// PageRequest asks a list endpoint for up to Limit rows ordered by Sort,
+// starting after the row Cursor points at. An empty Cursor starts at the
+// first row.
+type PageRequest struct {
+    Limit  int
+    Sort   string
+    Desc   bool
+    Cursor string
+}
+
+// Page is one page of a list. NextCursor is empty on the last page.
+type Page[T any] struct {
+    Items      []T    `json:"items"`
+    NextCursor string `json:"next_cursor,omitempty"`
+}
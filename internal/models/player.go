@@ -1,9 +1,28 @@
 package models
 
+// Sorts accepted by the player list.
+const (
+    PlayerSortID       = "id"
+    PlayerSortName     = "name"
+    PlayerSortValue    = "value"
+    PlayerSortCapacity = "capacity"
+)
+
+// PlayerFilter narrows the player list. Nil bounds and an empty Search are
+// not applied.
+type PlayerFilter struct {
+    MinValue    *float64
+    MaxValue    *float64
+    // Search matches part of the name, ignoring case.
+    Search      string
+    // MinCapacity is the least capacity still available to buy.
+    MinCapacity *int
+}
+
 type Player struct {
 	ID int64 `json:"id"`
     Name  string `json:"name" binding:"required"`
     Value float64    `json:"value" binding:"required"`
     Capacity int    `json:"capacity" binding:"required"`
     Slug string    `json:"slug" binding:"required"`
-}
\ No newline at end of file
+}
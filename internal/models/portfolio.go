@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type PortfolioPoint struct {
+    Cash        float64   `json:"cash"`
+    MarketValue float64   `json:"market_value"`
+    CostBasis   float64   `json:"cost_basis"`
+    TotalValue  float64   `json:"total_value"`
+    Timestamp   time.Time `json:"timestamp"`
+}
@@ -1,8 +1,24 @@
 package models
 
+// Sorts accepted by the position list.
+const (
+    PositionSortQuantity    = "quantity"
+    PositionSortAverageCost = "average_cost"
+)
+
+// PositionFilter narrows the position list. Zero IDs and a nil LeagueID are
+// not applied; league 0 is the global islands.
+type PositionFilter struct {
+    UserID   int64
+    PlayerID int64
+    LeagueID *int64
+}
+
 type Position struct {
     UserID   int64 `json:"user_id" binding:"required"`
     AssetID int64 `json:"player_id" binding:"required"`
+    LeagueID int64 `json:"league_id,omitempty"`
     Quantity int `json:"quantity" binding:"required"`
     AverageCost float64 `json:"average_cost" binding:"required"`
-}
\ No newline at end of file
+    Lots []Lot `json:"lots,omitempty"`
+}
@@ -5,4 +5,33 @@ import "time"
 type PricePoint struct {
     Price     float64   `json:"price"`
     Timestamp time.Time `json:"timestamp"`
-}
\ No newline at end of file
+}
+
+// Candle intervals.
+const (
+    CandleHour = "hour"
+    CandleDay  = "day"
+    CandleWeek = "week"
+)
+
+// Candle summarises a player's value over one interval. Open is the value in
+// effect as the interval began and Close the value as it ended, so intervals
+// without a value change are flat rather than missing. Volume is the shares
+// traded on the player's global island during the interval.
+type Candle struct {
+    Start  time.Time `json:"start"`
+    Open   float64   `json:"open"`
+    High   float64   `json:"high"`
+    Low    float64   `json:"low"`
+    Close  float64   `json:"close"`
+    Volume float64   `json:"volume"`
+    Trades int       `json:"trades"`
+}
+
+type CandleSeries struct {
+    PlayerID int64     `json:"player_id"`
+    Interval string    `json:"interval"`
+    From     time.Time `json:"from"`
+    To       time.Time `json:"to"`
+    Candles  []Candle  `json:"candles"`
+}
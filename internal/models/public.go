@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// PrivacySettings control what unauthenticated visitors can see. A private
+// profile hides the user's page and badges; ListedOnIslands controls whether
+// they appear among a player's holders and claimers.
+type PrivacySettings struct {
+    ProfilePublic   bool `json:"profile_public"`
+    ListedOnIslands bool `json:"listed_on_islands"`
+}
+
+type PublicIsland struct {
+    PlayerID     int64      `json:"player_id"`
+    PlayerName   string     `json:"player_name"`
+    Slug         string     `json:"slug"`
+    Quantity     int        `json:"quantity"`
+    AverageCost  float64    `json:"average_cost"`
+    CurrentValue float64    `json:"current_value"`
+    ClaimID      *int64     `json:"claim_id,omitempty"`
+    ClaimRank    *int       `json:"claim_rank,omitempty"`
+    EntryValue   *float64   `json:"entry_value,omitempty"`
+    ClaimedAt    *time.Time `json:"claimed_at,omitempty"`
+}
+
+type PublicProfile struct {
+    Username string          `json:"username"`
+    Islands  []*PublicIsland `json:"islands"`
+}
+
+type IslandHolder struct {
+    Username string `json:"username"`
+    Quantity int    `json:"quantity"`
+}
+
+type IslandClaimer struct {
+    Username   string    `json:"username"`
+    Rank       int       `json:"rank"`
+    EntryValue float64   `json:"entry_value"`
+    ClaimedAt  time.Time `json:"claimed_at"`
+}
+
+type PlayerIsland struct {
+    Player           *Player          `json:"player"`
+    TopHolders       []*IslandHolder  `json:"top_holders"`
+    EarliestClaimers []*IslandClaimer `json:"earliest_claimers"`
+}
+
+type ClaimBadge struct {
+    ClaimID    int64
+    Username   string
+    PlayerName string
+    Rank       int
+    EntryValue float64
+    Hash       string
+}
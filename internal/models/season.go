@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+    SeasonUpcoming = "upcoming"
+    SeasonActive   = "active"
+    SeasonEnded    = "ended"
+)
+
+type Season struct {
+    ID               int64      `json:"id"`
+    NBASeason        string     `json:"nba_season"`
+    Status           string     `json:"status"`
+    StartingCurrency float64    `json:"starting_currency"`
+    IslandCapacity   int        `json:"island_capacity"`
+    StartedAt        *time.Time `json:"started_at"`
+    EndedAt          *time.Time `json:"ended_at"`
+}
+
+type SeasonStanding struct {
+    SeasonID      int64   `json:"season_id"`
+    UserID        int64   `json:"user_id"`
+    Username      string  `json:"username"`
+    Rank          int     `json:"rank"`
+    Cash          float64 `json:"cash"`
+    PositionValue float64 `json:"position_value"`
+    NetWorth      float64 `json:"net_worth"`
+}
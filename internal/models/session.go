@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AuthSession is one refresh token. Tokens rotated from the same login share
+// a FamilyID, which access tokens carry as their sid claim.
+type AuthSession struct {
+    ID        int64      `json:"id"`
+    FamilyID  string     `json:"family_id"`
+    UserID    int64      `json:"user_id"`
+    TokenHash string     `json:"-"`
+    UserAgent string     `json:"user_agent"`
+    IP        string     `json:"ip"`
+    CreatedAt time.Time  `json:"created_at"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    UsedAt    *time.Time `json:"used_at,omitempty"`
+    RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
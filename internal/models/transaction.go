@@ -2,13 +2,34 @@ package models
 
 import "time"
 
+// Sorts accepted by the transaction list.
+const (
+    TransactionSortTimestamp = "timestamp"
+    TransactionSortPrice     = "price"
+    TransactionSortQuantity  = "quantity"
+)
+
+// TransactionFilter narrows the transaction list. Zero IDs, an empty Type
+// and nil times are not applied. From is inclusive and To exclusive.
+type TransactionFilter struct {
+    Type     string
+    UserID   int64
+    PlayerID int64
+    LeagueID *int64
+    From     *time.Time
+    To       *time.Time
+}
+
 type Transaction struct {
     ID   int64 `json:"id"`
     UserID   int64 `json:"user_id" binding:"required"`
     AssetID int64 `json:"player_id" binding:"required"`
-	Type string `json:"type" binding:"required`
+	Type string `json:"type" binding:"required"`
     Quantity int `json:"quantity" binding:"required"`
 	Price float64  `json:"price" binding:"required"`
+	Fee float64  `json:"fee"`
+	SeasonID int64 `json:"season_id"`
+	LeagueID int64 `json:"league_id,omitempty"`
 	Timestamp time.Time `json:"timestamp" binding:"required"`
 
-}
\ No newline at end of file
+}
@@ -1,5 +1,23 @@
 package models
 
+const (
+    RoleUser  = "user"
+    RoleAdmin = "admin"
+)
+
+// Sorts accepted by the user list.
+const (
+    UserSortID       = "id"
+    UserSortUsername = "username"
+    UserSortCurrency = "currency"
+)
+
+// UserFilter narrows the user list. An empty Search is not applied.
+type UserFilter struct {
+    // Search matches part of the username or name, ignoring case.
+    Search string
+}
+
 type User struct {
 	ID int64 `json:"id"`
     Username string `json:"username" binding:"required"`
@@ -7,4 +25,6 @@ type User struct {
     Email string `json:"email" binding:"required,email"`
     Password string `json:"password"`
     Currency float64	`json:"currency"`
-}
\ No newline at end of file
+    Role string `json:"role"`
+    EmailVerified bool `json:"email_verified"`
+}
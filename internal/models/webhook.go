@@ -0,0 +1,60 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// Webhook delivery states. A pending delivery is retried with backoff until
+// it succeeds or runs out of attempts and fails.
+const (
+    DeliveryPending   = "pending"
+    DeliveryDelivered = "delivered"
+    DeliveryFailed    = "failed"
+)
+
+// Sorts accepted by the webhook delivery log.
+const (
+    DeliverySortCreatedAt = "created_at"
+)
+
+type Webhook struct {
+    ID            int64     `json:"id"`
+    UserID        int64     `json:"user_id"`
+    URL           string    `json:"url"`
+    Secret        string    `json:"-"`
+    EventTypes    []string  `json:"event_types"`
+    MinTradeValue *float64  `json:"min_trade_value,omitempty"`
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook, with the outcome of
+// its latest attempt.
+type WebhookDelivery struct {
+    ID             int64           `json:"id"`
+    WebhookID      int64           `json:"webhook_id"`
+    EventType      string          `json:"event_type"`
+    Payload        json.RawMessage `json:"payload"`
+    Status         string          `json:"status"`
+    Attempts       int             `json:"attempts"`
+    NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+    LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+    LastStatusCode *int            `json:"last_status_code,omitempty"`
+    LastError      *string         `json:"last_error,omitempty"`
+    DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+    CreatedAt      time.Time       `json:"created_at"`
+}
+
+// DeliveryAttempt is the outcome of sending a delivery once. StatusCode is
+// zero when no response came back.
+type DeliveryAttempt struct {
+    StatusCode int
+    Error      string
+}
+
+// DueDelivery is a delivery claimed for sending, with where to send it.
+type DueDelivery struct {
+    *WebhookDelivery
+    URL    string
+    Secret string
+}
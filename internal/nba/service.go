@@ -251,8 +251,8 @@ func (s *NBAService) SeedTopPlayers(ctx context.Context, season string, minGames
         
         var appPlayerID int
         err = s.pool.QueryRow(ctx, `
-            INSERT INTO players (name, value, capacity, slug)
-            VALUES ($1, $2, $3, $4)
+            INSERT INTO players (name, value, capacity, base_capacity, slug)
+            VALUES ($1, $2, $3, $3, $4)
             RETURNING id
         `, stats.PlayerName, value, capacity, slug).Scan(&appPlayerID)
         
@@ -0,0 +1,127 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/nbaisland/nbaisland/internal/oidc"
+	"github.com/nbaisland/nbaisland/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://app.test/auth/callback/mock"
+
+func newProvider(t *testing.T) (*oidctest.Issuer, *oidc.Provider) {
+	t.Helper()
+	issuer, err := oidctest.NewIssuer("nbaisland", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(issuer.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		IssuerURL:    issuer.URL(),
+		ClientID:     "nbaisland",
+		ClientSecret: "s3cret",
+		RedirectURL:  redirectURL,
+	}, issuer.Server.Client())
+	return issuer, provider
+}
+
+// signIn runs the browser half of the flow and returns the code.
+func signIn(t *testing.T, issuer *oidctest.Issuer, provider *oidc.Provider, verifier string, nonce string) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := issuer.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+	return code
+}
+
+func TestAuthCodeURLUsesPKCE(t *testing.T) {
+	_, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "st", "n", oidc.S256Challenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge") != oidc.S256Challenge("verifier") || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("missing PKCE parameters: %s", authURL)
+	}
+	if q.Get("redirect_uri") != redirectURL || q.Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected parameters: %s", authURL)
+	}
+}
+
+func TestExchangeReturnsVerifiedClaims(t *testing.T) {
+	issuer, provider := newProvider(t)
+	verifier, _ := oidc.NewVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "player@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	issuer, provider := newProvider(t)
+	verifier, _ := oidc.NewVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+
+	other, _ := oidc.NewVerifier()
+	if _, err := provider.Exchange(context.Background(), code, other, "nonce-1"); err == nil {
+		t.Fatal("exchange succeeded with the wrong code verifier")
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	issuer, provider := newProvider(t)
+	verifier, _ := oidc.NewVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce-2")
+	if !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Fatalf("err = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestExchangeRejectsTokenForAnotherClient(t *testing.T) {
+	issuer, provider := newProvider(t)
+	issuer.Audience = "someone-else"
+	verifier, _ := oidc.NewVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce-1"); err == nil {
+		t.Fatal("accepted an ID token minted for another client")
+	}
+}
+
+func TestCodeCannotBeReused(t *testing.T) {
+	issuer, provider := newProvider(t)
+	verifier, _ := oidc.NewVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce-1"); err == nil {
+		t.Fatal("authorization code was accepted twice")
+	}
+}
@@ -0,0 +1,180 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schemas derives schemas from Go values. Named structs become components
+// referenced by name; anonymous structs are inlined.
+type Schemas struct {
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{defs: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// Components returns every named schema referenced so far.
+func (s *Schemas) Components() map[string]*Schema {
+	return s.defs
+}
+
+// Define adds a hand-written component, for shapes no Go type describes.
+func (s *Schemas) Define(name string, schema *Schema) *Schema {
+	s.defs[name] = schema
+	return Ref(name)
+}
+
+// For returns the schema of v's type.
+func (s *Schemas) For(v interface{}) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *Schemas) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := s.name(t)
+		if _, ok := s.defs[name]; !ok {
+			// Reserve the name first so self-referencing types terminate.
+			s.defs[name] = &Schema{}
+			*s.defs[name] = *s.object(t)
+		}
+		return Ref(name)
+	}
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
+
+// name is the component name of t: the Go type name, capitalised, with
+// generic arguments folded in (Page[*models.Player] is PagePlayer) and the
+// package prepended when two packages use the same name.
+func (s *Schemas) name(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if i := strings.Index(name, "["); i >= 0 {
+		base := name[:i]
+		for _, arg := range strings.Split(name[i+1:len(name)-1], ",") {
+			arg = strings.TrimLeft(arg, "*[]")
+			base += arg[strings.LastIndex(arg, ".")+1:]
+		}
+		name = base
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	for other, taken := range s.names {
+		if taken == name && other != t {
+			pkg := t.PkgPath()
+			pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+			break
+		}
+	}
+	s.names[t] = name
+	return name
+}
+
+func (s *Schemas) object(t reflect.Type) *Schema {
+	obj := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(obj, t)
+	return obj
+}
+
+// fields adds t's JSON fields to obj, flattening embedded structs the way
+// encoding/json does.
+func (s *Schemas) fields(obj *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(obj, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.schema(f.Type)
+		if f.Type.Kind() == reflect.Ptr && prop.Ref == "" {
+			prop.Nullable = true
+		}
+		obj.Properties[name] = prop
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type AccountTokenRepository interface {
+	Create(ctx context.Context, t *models.AccountToken) error
+	Consume(ctx context.Context, purpose string, hash string) (*models.AccountToken, error)
+	InvalidateForUser(ctx context.Context, userID int64, purpose string) error
+	IssuedSince(ctx context.Context, userID int64, purpose string, since time.Time) (bool, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type PSQLAccountTokenRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLAccountTokenRepo) Create(ctx context.Context, t *models.AccountToken) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO account_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+}
+
+// Consume marks an unused, unexpired token spent and returns it, or nil when
+// there is no such token. Two concurrent uses cannot both succeed.
+func (r *PSQLAccountTokenRepo) Consume(ctx context.Context, purpose string, hash string) (*models.AccountToken, error) {
+	var t models.AccountToken
+	err := r.Pool.QueryRow(ctx, `
+		UPDATE account_tokens SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING id, user_id, purpose, token_hash, created_at, expires_at, used_at`,
+		hash, purpose,
+	).Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// InvalidateForUser spends every outstanding token of purpose for the user.
+func (r *PSQLAccountTokenRepo) InvalidateForUser(ctx context.Context, userID int64, purpose string) error {
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE account_tokens SET used_at = now()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	return err
+}
+
+// IssuedSince reports whether the user was issued a token of purpose after
+// since, spent or not.
+func (r *PSQLAccountTokenRepo) IssuedSince(ctx context.Context, userID int64, purpose string, since time.Time) (bool, error) {
+	var issued bool
+	err := r.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM account_tokens WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+		)`, userID, purpose, since).Scan(&issued)
+	return issued, err
+}
+
+func (r *PSQLAccountTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, "DELETE FROM account_tokens WHERE expires_at < now() OR used_at IS NOT NULL")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type AlertRepository interface {
+	GetWatchlist(ctx context.Context, userID int64) ([]*models.WatchlistItem, error)
+	CountWatchlist(ctx context.Context, userID int64) (int, error)
+	AddToWatchlist(ctx context.Context, userID int64, playerID int64) error
+	RemoveFromWatchlist(ctx context.Context, userID int64, playerID int64) (bool, error)
+
+	Create(ctx context.Context, a *models.PriceAlert) error
+	GetByUserID(ctx context.Context, userID int64) ([]*models.PriceAlert, error)
+	CountByUserID(ctx context.Context, userID int64) (int, error)
+	Delete(ctx context.Context, id int64, userID int64) (bool, error)
+	// Evaluate checks the alerts on playerIDs against their current values,
+	// disarming the ones that fire and rearming the ones whose condition no
+	// longer holds, and returns those that fired. Each fired alert's
+	// notification is stored in the same transaction as its disarming.
+	Evaluate(ctx context.Context, playerIDs []int64) ([]*models.FiredAlert, error)
+}
+
+type PSQLAlertRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLAlertRepo) GetWatchlist(ctx context.Context, userID int64) ([]*models.WatchlistItem, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT p.id, p.name, p.slug, p.value::float8, w.created_at
+		FROM watchlist_items w
+		JOIN players p ON p.id = w.player_id
+		WHERE w.user_id = $1
+		ORDER BY w.created_at DESC, p.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*models.WatchlistItem, 0)
+	for rows.Next() {
+		i := &models.WatchlistItem{}
+		if err := rows.Scan(&i.PlayerID, &i.Name, &i.Slug, &i.Value, &i.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func (r *PSQLAlertRepo) CountWatchlist(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM watchlist_items WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// AddToWatchlist does nothing if the player is already on the watchlist.
+func (r *PSQLAlertRepo) AddToWatchlist(ctx context.Context, userID int64, playerID int64) error {
+	_, err := r.Pool.Exec(ctx, `
+		INSERT INTO watchlist_items (user_id, player_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, userID, playerID)
+	return err
+}
+
+func (r *PSQLAlertRepo) RemoveFromWatchlist(ctx context.Context, userID int64, playerID int64) (bool, error) {
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM watchlist_items WHERE user_id = $1 AND player_id = $2`, userID, playerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+const alertColumns = "a.id, a.user_id, a.player_id, a.kind, a.threshold::float8, a.window_hours, a.email, a.armed, a.last_triggered_at, a.created_at"
+
+func scanAlert(row pgx.Row, extra ...interface{}) (*models.PriceAlert, error) {
+	var a models.PriceAlert
+	dest := []interface{}{
+		&a.ID,
+		&a.UserID,
+		&a.PlayerID,
+		&a.Kind,
+		&a.Threshold,
+		&a.WindowHours,
+		&a.Email,
+		&a.Armed,
+		&a.LastTriggeredAt,
+		&a.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *PSQLAlertRepo) Create(ctx context.Context, a *models.PriceAlert) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO price_alerts (user_id, player_id, kind, threshold, window_hours, email)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, armed, created_at`,
+		a.UserID, a.PlayerID, a.Kind, a.Threshold, a.WindowHours, a.Email,
+	).Scan(&a.ID, &a.Armed, &a.CreatedAt)
+}
+
+func (r *PSQLAlertRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.PriceAlert, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+alertColumns+`
+		FROM price_alerts a
+		WHERE a.user_id = $1
+		ORDER BY a.created_at DESC, a.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]*models.PriceAlert, 0)
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *PSQLAlertRepo) CountByUserID(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM price_alerts WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *PSQLAlertRepo) Delete(ctx context.Context, id int64, userID int64) (bool, error) {
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM price_alerts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Evaluate only updates alerts whose armed state flips, and the update
+// re-checks armed, so when evaluations overlap each alert still fires once.
+func (r *PSQLAlertRepo) Evaluate(ctx context.Context, playerIDs []int64) ([]*models.FiredAlert, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		WITH checked AS (
+			SELECT a.id, p.name, p.value, ref.price AS ref_price,
+				COALESCE(CASE a.kind
+					WHEN 'above' THEN p.value >= a.threshold
+					WHEN 'below' THEN p.value <= a.threshold
+					WHEN 'change' THEN ref.price > 0 AND abs(p.value - ref.price) / ref.price * 100 >= a.threshold
+				END, false) AS met
+			FROM price_alerts a
+			JOIN players p ON p.id = a.player_id
+			LEFT JOIN LATERAL (
+				SELECT h.price FROM player_price_history h
+				WHERE h.player_id = a.player_id
+					AND h."timestamp" <= now() - make_interval(hours => a.window_hours)
+				ORDER BY h."timestamp" DESC
+				LIMIT 1
+			) ref ON a.kind = 'change'
+			WHERE a.player_id = ANY($1)
+		)
+		UPDATE price_alerts a SET
+			armed = NOT c.met,
+			last_triggered_at = CASE WHEN c.met THEN now() ELSE a.last_triggered_at END
+		FROM checked c, users u
+		WHERE a.id = c.id AND u.id = a.user_id AND a.armed = c.met
+		RETURNING `+alertColumns+`, c.met, c.name, c.value::float8, c.ref_price::float8,
+			CASE WHEN a.email AND u.email_verified_at IS NOT NULL THEN u.email ELSE '' END`, playerIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fired []*models.FiredAlert
+	for rows.Next() {
+		f := &models.FiredAlert{}
+		var met bool
+		f.PriceAlert, err = scanAlert(rows, &met, &f.PlayerName, &f.Value, &f.ReferenceValue, &f.EmailTo)
+		if err != nil {
+			return nil, err
+		}
+		if met {
+			fired = append(fired, f)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, f := range fired {
+		f.Notification = f.NewNotification()
+		if err := createNotification(ctx, tx, f.Notification); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return fired, nil
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+func createAlert(t *testing.T, repo *PSQLAlertRepo, a *models.PriceAlert) *models.PriceAlert {
+	t.Helper()
+	if err := repo.Create(context.Background(), a); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	return a
+}
+
+func firedIDs(t *testing.T, fired []*models.FiredAlert) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, len(fired))
+	for _, f := range fired {
+		ids = append(ids, f.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func alertNotifications(t *testing.T, pool *pgxpool.Pool, alertID int64) int {
+	t.Helper()
+	var n int
+	err := pool.QueryRow(context.Background(), `
+		SELECT count(*) FROM notifications
+		WHERE type = 'price_alert' AND (data->>'alert_id')::bigint = $1`, alertID).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEvaluateFiresOncePerCrossing(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	repo := &PSQLAlertRepo{Pool: pool}
+	user := insertUser(t, pool, "alice", true)
+	player := insertPlayer(t, pool, "jalen_brunson", 100)
+	above := createAlert(t, repo, &models.PriceAlert{UserID: user, PlayerID: player, Kind: models.AlertAbove, Threshold: 110, Email: true})
+	below := createAlert(t, repo, &models.PriceAlert{UserID: user, PlayerID: player, Kind: models.AlertBelow, Threshold: 90})
+
+	steps := []struct {
+		name  string
+		value float64
+		fired []int64
+	}{
+		{"between thresholds", 100, []int64{}},
+		{"crosses above", 115, []int64{above.ID}},
+		{"stays above", 120, []int64{}},
+		{"falls back, rearming", 105, []int64{}},
+		{"crosses above again", 112, []int64{above.ID}},
+		{"drops through both", 85, []int64{below.ID}},
+		{"unchanged", 85, []int64{}},
+	}
+	sent := map[int64]int{}
+	for _, step := range steps {
+		if _, err := pool.Exec(ctx, "UPDATE players SET value = $2 WHERE id = $1", player, step.value); err != nil {
+			t.Fatal(err)
+		}
+		fired, err := repo.Evaluate(ctx, []int64{player})
+		if err != nil {
+			t.Fatalf("%s: Evaluate: %v", step.name, err)
+		}
+		if got := firedIDs(t, fired); !slices.Equal(got, step.fired) {
+			t.Errorf("%s: fired %v, want %v", step.name, got, step.fired)
+		}
+		for _, f := range fired {
+			sent[f.ID]++
+			if f.Value != step.value || f.Notification == nil || f.Notification.ID == 0 {
+				t.Errorf("%s: fired %+v, want value %v and a stored notification", step.name, f, step.value)
+			}
+			wantEmail := ""
+			if f.ID == above.ID {
+				wantEmail = "alice@example.com"
+			}
+			if f.EmailTo != wantEmail {
+				t.Errorf("%s: alert %d emails %q, want %q", step.name, f.ID, f.EmailTo, wantEmail)
+			}
+		}
+	}
+
+	for _, a := range []*models.PriceAlert{above, below} {
+		if got := alertNotifications(t, pool, a.ID); got != sent[a.ID] {
+			t.Errorf("alert %d has %d notifications, want one per firing (%d)", a.ID, got, sent[a.ID])
+		}
+	}
+	alerts, err := repo.GetByUserID(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range alerts {
+		if wantArmed := a.ID == above.ID; a.Armed != wantArmed {
+			t.Errorf("alert %d armed = %v, want %v", a.ID, a.Armed, wantArmed)
+		}
+	}
+}
+
+func TestEvaluateChangeAlertsAgainstWindowStart(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	repo := &PSQLAlertRepo{Pool: pool}
+	verified := insertUser(t, pool, "alice", true)
+	unverified := insertUser(t, pool, "bob", false)
+	player := insertPlayer(t, pool, "jalen_brunson", 150)
+
+	now := time.Now()
+	for _, h := range []struct {
+		price float64
+		at    time.Time
+	}{
+		{200, now.Add(-30 * time.Hour)},
+		{190, now.Add(-10 * time.Hour)},
+	} {
+		_, err := pool.Exec(ctx, `INSERT INTO player_price_history (player_id, price, "timestamp") VALUES ($1, $2, $3)`, player, h.price, h.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hours := func(h int) *int { return &h }
+	cases := []struct {
+		name      string
+		user      int64
+		threshold float64
+		window    *int
+		fires     bool
+		reference float64
+	}{
+		// 200 -> 150 is a 25% drop.
+		{"past threshold over the window", verified, 22, hours(24), true, 200},
+		{"short of threshold over the window", verified, 30, hours(24), false, 0},
+		// 190 -> 150 is a 21% drop.
+		{"reference is the last price before the window", unverified, 22, hours(6), false, 0},
+		{"shorter window past threshold", unverified, 20, hours(6), true, 190},
+		{"no history that old", verified, 1, hours(48), false, 0},
+	}
+	want := map[int64]float64{}
+	for _, tc := range cases {
+		a := createAlert(t, repo, &models.PriceAlert{UserID: tc.user, PlayerID: player, Kind: models.AlertChange, Threshold: tc.threshold, WindowHours: tc.window, Email: true})
+		if tc.fires {
+			want[a.ID] = tc.reference
+		}
+	}
+
+	fired, err := repo.Evaluate(ctx, []int64{player})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(fired) != len(want) {
+		t.Errorf("fired %v, want alerts %v", firedIDs(t, fired), want)
+	}
+	for _, f := range fired {
+		reference, ok := want[f.ID]
+		if !ok {
+			t.Errorf("alert %d fired", f.ID)
+			continue
+		}
+		if f.ReferenceValue == nil || *f.ReferenceValue != reference {
+			t.Errorf("alert %d compared against %v, want %v", f.ID, f.ReferenceValue, reference)
+		}
+		if wantEmail := map[int64]string{verified: "alice@example.com"}[f.UserID]; f.EmailTo != wantEmail {
+			t.Errorf("alert %d emails %q, want %q", f.ID, f.EmailTo, wantEmail)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *models.APIKey) error
+	GetByUserID(ctx context.Context, userID int64) ([]*models.APIKey, error)
+	CountActive(ctx context.Context, userID int64) (int, error)
+	Revoke(ctx context.Context, id int64, userID int64) (bool, error)
+	Use(ctx context.Context, hash string) (*models.APIKeyUse, error)
+}
+
+type PSQLAPIKeyRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const apiKeyColumns = "k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.rate_limit_per_minute, k.created_at, k.expires_at, k.last_used_at, k.revoked_at"
+
+func scanAPIKey(row pgx.Row, extra ...interface{}) (*models.APIKey, error) {
+	var k models.APIKey
+	dest := []interface{}{
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		&k.Scopes,
+		&k.RateLimitPerMinute,
+		&k.CreatedAt,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *PSQLAPIKeyRepo) Create(ctx context.Context, k *models.APIKey) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, rate_limit_per_minute, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		k.UserID, k.Name, k.Prefix, k.KeyHash, k.Scopes, k.RateLimitPerMinute, k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt)
+}
+
+func (r *PSQLAPIKeyRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys k
+		WHERE k.user_id = $1
+		ORDER BY k.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *PSQLAPIKeyRepo) CountActive(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())`,
+		userID).Scan(&count)
+	return count, err
+}
+
+// Revoke reports false when the user has no such unrevoked key.
+func (r *PSQLAPIKeyRepo) Revoke(ctx context.Context, id int64, userID int64) (bool, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = now()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Use looks up a live key by hash and, in the same statement, stamps
+// last_used_at and counts the request against the key's current minute.
+func (r *PSQLAPIKeyRepo) Use(ctx context.Context, hash string) (*models.APIKeyUse, error) {
+	use := &models.APIKeyUse{}
+	key, err := scanAPIKey(r.Pool.QueryRow(ctx, `
+		UPDATE api_keys k SET
+			last_used_at = now(),
+			window_count = CASE WHEN k.window_start < date_trunc('minute', now()) THEN 1 ELSE k.window_count + 1 END,
+			window_start = CASE WHEN k.window_start < date_trunc('minute', now()) THEN date_trunc('minute', now()) ELSE k.window_start END
+		FROM users u
+		WHERE u.id = k.user_id
+			AND k.key_hash = $1
+			AND k.revoked_at IS NULL
+			AND (k.expires_at IS NULL OR k.expires_at > now())
+		RETURNING `+apiKeyColumns+`, u.username, k.window_start, k.window_count`, hash),
+		&use.Username, &use.WindowStart, &use.WindowCount)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	use.Key = key
+	return use, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type ClaimRepository interface {
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Claim, error)
+	GetByHash(ctx context.Context, hash string) (*models.Claim, error)
+	GetChain(ctx context.Context) ([]*models.Claim, error)
+	GetUnclaimedFirstBuys(ctx context.Context) ([]*models.Transaction, error)
+	CreateChained(ctx context.Context, c *models.Claim, seal func(c *models.Claim)) (bool, error)
+}
+
+type PSQLClaimRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const claimColumns = "id, user_id, player_id, transaction_id, rank, entry_value, claimed_at, prev_hash, hash"
+
+func scanClaimRows(rows pgx.Rows) ([]*models.Claim, error) {
+	var claims []*models.Claim
+	for rows.Next() {
+		var c models.Claim
+		err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.PlayerID,
+			&c.TransactionID,
+			&c.Rank,
+			&c.EntryValue,
+			&c.ClaimedAt,
+			&c.PrevHash,
+			&c.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, &c)
+	}
+	return claims, rows.Err()
+}
+
+func (r *PSQLClaimRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.Claim, error) {
+	rows, err := r.Pool.Query(ctx, "SELECT "+claimColumns+" FROM claims WHERE user_id=$1 ORDER BY claimed_at ASC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanClaimRows(rows)
+}
+
+func (r *PSQLClaimRepo) GetByHash(ctx context.Context, hash string) (*models.Claim, error) {
+	rows, err := r.Pool.Query(ctx, "SELECT "+claimColumns+" FROM claims WHERE hash=$1", hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims, err := scanClaimRows(rows)
+	if err != nil || len(claims) == 0 {
+		return nil, err
+	}
+	return claims[0], nil
+}
+
+func (r *PSQLClaimRepo) GetChain(ctx context.Context) ([]*models.Claim, error) {
+	rows, err := r.Pool.Query(ctx, "SELECT "+claimColumns+" FROM claims ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanClaimRows(rows)
+}
+
+// GetUnclaimedFirstBuys returns each user's first global BUY of a player that
+// has no claim yet, oldest first. League trades never earn claims.
+func (r *PSQLClaimRepo) GetUnclaimedFirstBuys(ctx context.Context) ([]*models.Transaction, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT * FROM (
+			SELECT DISTINCT ON (t.user_id, t.asset_id)
+				t.id, t.user_id, t.asset_id, t.type, t.quantity, t.price, t.fee, t.season_id, COALESCE(t.league_id, 0), t.timestamp
+			FROM transactions t
+			WHERE t.type = 'BUY'
+			AND t.league_id IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM claims c WHERE c.user_id = t.user_id AND c.player_id = t.asset_id
+			)
+			ORDER BY t.user_id, t.asset_id, t.timestamp ASC, t.id ASC
+		) first_buys
+		ORDER BY timestamp ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactionRows(rows)
+}
+
+// lockClaimsChain serializes appends to the claims chain, so each claim
+// reads the hash before it and no two claims follow the same one.
+const lockClaimsChain = "SELECT pg_advisory_xact_lock(hashtext('claims_chain'))"
+
+func hasClaim(ctx context.Context, db rowQuerier, userID int64, playerID int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM claims WHERE user_id=$1 AND player_id=$2)", userID, playerID).Scan(&exists)
+	return exists, err
+}
+
+// appendClaim chains c, the claim earned by transaction c.TransactionID,
+// inside tx, which must hold lockClaimsChain. Rank counts the other users
+// whose global buys of the player came before that trade by (timestamp, id),
+// so it follows trade order however late or out of order claims are
+// recorded. seal is called with PrevHash and Rank filled in and must set Hash.
+func appendClaim(ctx context.Context, tx pgx.Tx, c *models.Claim, seal func(c *models.Claim)) error {
+	err := tx.QueryRow(ctx, "SELECT hash FROM claims ORDER BY id DESC LIMIT 1").Scan(&c.PrevHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.PrevHash = ""
+	} else if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT t.user_id) + 1
+		FROM transactions t
+		JOIN transactions own ON own.id = $1
+		WHERE t.asset_id = own.asset_id AND t.type = 'BUY' AND t.league_id IS NULL
+		AND t.user_id <> own.user_id
+		AND (t."timestamp", t.id) < (own."timestamp", own.id)`, c.TransactionID).Scan(&c.Rank)
+	if err != nil {
+		return err
+	}
+
+	seal(c)
+
+	return tx.QueryRow(ctx, `
+		INSERT INTO claims (user_id, player_id, transaction_id, rank, entry_value, claimed_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		c.UserID, c.PlayerID, c.TransactionID, c.Rank, c.EntryValue, c.ClaimedAt, c.PrevHash, c.Hash,
+	).Scan(&c.ID)
+}
+
+// CreateChained appends a claim for a trade that is already booked, as
+// Backfill does. It reports false when the user already holds a claim on
+// the player.
+func (r *PSQLClaimRepo) CreateChained(ctx context.Context, c *models.Claim, seal func(c *models.Claim)) (bool, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockClaimsChain); err != nil {
+		return false, err
+	}
+	exists, err := hasClaim(ctx, tx, c.UserID, c.PlayerID)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if err := appendClaim(ctx, tx, c, seal); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+// testSeal stands in for the service's hash; it only needs to be unique.
+func testSeal(c *models.Claim) {
+	c.Hash = fmt.Sprintf("%064d", c.TransactionID)
+}
+
+func TestClaimRanksFollowTradeOrder(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	claims := &PSQLClaimRepo{Pool: pool}
+	trades := &PSQLTransactionRepo{Pool: pool}
+	alice := insertUser(t, pool, "alice", true)
+	carol := insertUser(t, pool, "carol", true)
+	dave := insertUser(t, pool, "dave", true)
+	player := insertPlayer(t, pool, "jalen_brunson", 10)
+
+	// carol and dave bought before claims were recorded.
+	now := time.Now()
+	legacy := map[int64]*models.Transaction{}
+	for i, user := range []int64{carol, dave} {
+		b := &models.Transaction{UserID: user, AssetID: player, Type: "BUY", Quantity: 1, Price: 10, Timestamp: now.Add(time.Duration(i-2) * time.Hour)}
+		err := pool.QueryRow(ctx, `
+			INSERT INTO transactions (user_id, asset_id, type, quantity, price, "timestamp")
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			b.UserID, b.AssetID, b.Type, b.Quantity, b.Price, b.Timestamp).Scan(&b.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy[user] = b
+	}
+
+	buy := func() *models.Claim {
+		c, err := trades.ExecuteTrade(ctx, &models.Transaction{UserID: alice, AssetID: player, Type: "BUY", Quantity: 1, Price: 10, Timestamp: time.Now()}, testSeal)
+		if err != nil {
+			t.Fatalf("ExecuteTrade: %v", err)
+		}
+		return c
+	}
+	if c := buy(); c == nil || c.Rank != 3 || c.ID == 0 {
+		t.Errorf("alice's first buy claimed %+v, want rank 3 behind the earlier buyers", c)
+	}
+	if c := buy(); c != nil {
+		t.Errorf("alice's second buy claimed %+v", c)
+	}
+
+	// Backfilled out of order, the earlier buys still rank by when they traded.
+	for _, tc := range []struct {
+		user int64
+		rank int
+	}{{dave, 2}, {carol, 1}} {
+		b := legacy[tc.user]
+		c := &models.Claim{UserID: b.UserID, PlayerID: b.AssetID, TransactionID: b.ID, EntryValue: b.Price, ClaimedAt: b.Timestamp}
+		created, err := claims.CreateChained(ctx, c, testSeal)
+		if err != nil || !created {
+			t.Fatalf("CreateChained: created = %v, err = %v", created, err)
+		}
+		if c.Rank != tc.rank {
+			t.Errorf("user %d backfilled at rank %d, want %d", tc.user, c.Rank, tc.rank)
+		}
+	}
+
+	chain, err := claims.GetChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(chain); i++ {
+		if chain[i].PrevHash != chain[i-1].Hash {
+			t.Errorf("claim %d follows %q, want %q", chain[i].ID, chain[i].PrevHash, chain[i-1].Hash)
+		}
+	}
+}
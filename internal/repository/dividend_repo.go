@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DividendRepository interface {
+	// PayWeekly pays every holder of a global island perStat for each point,
+	// rebound, assist, steal and block the player recorded in the latest NBA
+	// week, per share held. It returns how many payments it made.
+	PayWeekly(ctx context.Context, perStat float64) (int64, error)
+}
+
+type PSQLDividendRepo struct {
+	Pool *pgxpool.Pool
+}
+
+// PayWeekly records each payment and credits it in one statement. Only an
+// active season pays, and a week already paid to a holder is skipped, so
+// rerunning the job is safe. Each recorded payment publishes dividend.paid.
+func (r *PSQLDividendRepo) PayWeekly(ctx context.Context, perStat float64) (int64, error) {
+	var count int64
+	err := r.Pool.QueryRow(ctx, `
+		WITH week AS (
+			SELECT m.player_id, w.week_end,
+				w.total_points + w.total_rebounds + w.total_assists + w.total_steals + w.total_blocks AS stats
+			FROM nba_weekly_stats w
+			JOIN player_nba_mapping m ON m.nba_player_id = w.player_id
+			WHERE w.week_end = (SELECT max(week_end) FROM nba_weekly_stats)
+		),
+		held AS (
+			SELECT t.user_id, t.asset_id, SUM(CASE WHEN t.type = 'BUY' THEN t.quantity ELSE -t.quantity END) AS quantity
+			FROM transactions t
+			JOIN seasons s ON s.id = t.season_id AND s.status = 'active'
+			WHERE t.league_id IS NULL AND t.season_id = current_season_id()
+			GROUP BY t.user_id, t.asset_id
+			HAVING SUM(CASE WHEN t.type = 'BUY' THEN t.quantity ELSE -t.quantity END) > 0
+		),
+		paid AS (
+			INSERT INTO dividend_payments (user_id, player_id, season_id, week_end, quantity, per_share, amount)
+			SELECT h.user_id, h.asset_id, current_season_id(), w.week_end, h.quantity, w.stats * $1, h.quantity * w.stats * $1
+			FROM held h
+			JOIN week w ON w.player_id = h.asset_id
+			WHERE w.stats > 0
+			ON CONFLICT (user_id, player_id, week_end) DO NOTHING
+			RETURNING user_id, amount
+		),
+		-- Postgres runs a data-modifying WITH even though nothing reads it.
+		credited AS (
+			UPDATE users u SET currency = u.currency + p.amount
+			FROM (SELECT user_id, SUM(amount) AS amount FROM paid GROUP BY user_id) p
+			WHERE u.id = p.user_id
+		)
+		SELECT count(*) FROM paid`, perStat).Scan(&count)
+	return count, err
+}
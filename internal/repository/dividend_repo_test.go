@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPayWeeklyPaysGlobalHoldersOnce(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	repo := &PSQLDividendRepo{Pool: pool}
+	alice := insertUser(t, pool, "alice", true)
+	carol := insertUser(t, pool, "carol", true)
+	player := insertPlayer(t, pool, "jalen_brunson", 10)
+
+	setup := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"INSERT INTO nba_players (id, full_name) VALUES (1628973, 'Jalen Brunson')", nil},
+		{"INSERT INTO player_nba_mapping (player_id, nba_player_id) VALUES ($1, 1628973)", []interface{}{player}},
+		{`INSERT INTO nba_weekly_stats (player_id, season, week_start, week_end, total_points, total_rebounds, total_assists, total_steals, total_blocks)
+			VALUES (1628973, '2025-26', '2025-01-06', '2025-01-12', 200, 0, 0, 0, 0),
+				(1628973, '2025-26', '2025-01-13', '2025-01-19', 12, 3, 4, 1, 0)`, nil},
+		// alice holds 4 shares; carol sold out.
+		{`INSERT INTO transactions (user_id, asset_id, type, quantity, price)
+			VALUES ($2, $1, 'BUY', 5, 10), ($2, $1, 'SELL', 1, 10), ($3, $1, 'BUY', 2, 10), ($3, $1, 'SELL', 2, 10)`,
+			[]interface{}{player, alice, carol}},
+	}
+	for _, step := range setup {
+		if _, err := pool.Exec(ctx, step.sql, step.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The latest week has 20 stats, so each share earns 20 * 0.05.
+	for i, want := range []int64{1, 0} {
+		count, err := repo.PayWeekly(ctx, 0.05)
+		if err != nil {
+			t.Fatalf("run %d: PayWeekly: %v", i+1, err)
+		}
+		if count != want {
+			t.Errorf("run %d: paid %d holders, want %d", i+1, count, want)
+		}
+	}
+
+	var currency float64
+	var notifications int
+	err := pool.QueryRow(ctx, `
+		SELECT u.currency::float8, (SELECT count(*) FROM notifications n WHERE n.user_id = u.id AND n.type = 'dividend')
+		FROM users u WHERE u.id = $1`, alice).Scan(&currency, &notifications)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if currency != 1004 || notifications != 1 {
+		t.Errorf("alice has %v and %d dividend notifications, want 1004 and 1", currency, notifications)
+	}
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type DraftRepository interface {
+	GetByID(ctx context.Context, id int64) (*models.Draft, error)
+	GetByLeagueID(ctx context.Context, leagueID int64) (*models.Draft, error)
+	GetExpired(ctx context.Context, now time.Time) ([]int64, error)
+	Create(ctx context.Context, d *models.Draft) error
+	Start(ctx context.Context, id int64, order []int64, deadline time.Time) error
+	RecordPick(ctx context.Context, draftID int64, pick *models.DraftPick, nextDeadline *time.Time) (bool, error)
+}
+
+type PSQLDraftRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const draftColumns = "id, league_id, season_id, status, rounds, pick_seconds, shares_per_pick, max_islands, price_pct, current_pick, pick_deadline, created_at, started_at, completed_at"
+
+func (r *PSQLDraftRepo) scanDraft(ctx context.Context, row pgx.Row) (*models.Draft, error) {
+	var d models.Draft
+	err := row.Scan(
+		&d.ID,
+		&d.LeagueID,
+		&d.SeasonID,
+		&d.Status,
+		&d.Rounds,
+		&d.PickSeconds,
+		&d.SharesPerPick,
+		&d.MaxIslands,
+		&d.PricePct,
+		&d.CurrentPick,
+		&d.PickDeadline,
+		&d.CreatedAt,
+		&d.StartedAt,
+		&d.CompletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadOrderAndPicks(ctx, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *PSQLDraftRepo) loadOrderAndPicks(ctx context.Context, d *models.Draft) error {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT o.slot, o.user_id, u.username
+		FROM draft_order o
+		JOIN users u ON u.id = o.user_id
+		WHERE o.draft_id = $1
+		ORDER BY o.slot ASC`, d.ID)
+	if err != nil {
+		return err
+	}
+	d.Order = make([]models.DraftSlot, 0)
+	for rows.Next() {
+		var s models.DraftSlot
+		if err := rows.Scan(&s.Slot, &s.UserID, &s.Username); err != nil {
+			rows.Close()
+			return err
+		}
+		d.Order = append(d.Order, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = r.Pool.Query(ctx, `
+		SELECT pick_number, round, user_id, player_id, quantity, price, auto, transaction_id, picked_at
+		FROM draft_picks
+		WHERE draft_id = $1
+		ORDER BY pick_number ASC`, d.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	d.Picks = make([]models.DraftPick, 0)
+	for rows.Next() {
+		var p models.DraftPick
+		err := rows.Scan(
+			&p.PickNumber,
+			&p.Round,
+			&p.UserID,
+			&p.PlayerID,
+			&p.Quantity,
+			&p.Price,
+			&p.Auto,
+			&p.TransactionID,
+			&p.PickedAt,
+		)
+		if err != nil {
+			return err
+		}
+		d.Picks = append(d.Picks, p)
+	}
+	return rows.Err()
+}
+
+func (r *PSQLDraftRepo) GetByID(ctx context.Context, id int64) (*models.Draft, error) {
+	return r.scanDraft(ctx, r.Pool.QueryRow(ctx, "SELECT "+draftColumns+" FROM drafts WHERE id=$1", id))
+}
+
+// GetByLeagueID returns the league's draft for the current season.
+func (r *PSQLDraftRepo) GetByLeagueID(ctx context.Context, leagueID int64) (*models.Draft, error) {
+	return r.scanDraft(ctx, r.Pool.QueryRow(ctx, "SELECT "+draftColumns+" FROM drafts WHERE league_id=$1 AND season_id=current_season_id()", leagueID))
+}
+
+// GetExpired returns active drafts whose current pick is past its deadline.
+func (r *PSQLDraftRepo) GetExpired(ctx context.Context, now time.Time) ([]int64, error) {
+	rows, err := r.Pool.Query(ctx, "SELECT id FROM drafts WHERE status = 'active' AND pick_deadline <= $1 ORDER BY pick_deadline ASC", now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *PSQLDraftRepo) Create(ctx context.Context, d *models.Draft) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO drafts (league_id, rounds, pick_seconds, shares_per_pick, max_islands, price_pct)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, season_id, status, current_pick, created_at`,
+		d.LeagueID, d.Rounds, d.PickSeconds, d.SharesPerPick, d.MaxIslands, d.PricePct,
+	).Scan(&d.ID, &d.SeasonID, &d.Status, &d.CurrentPick, &d.CreatedAt)
+}
+
+// Start fixes the draft order and puts the first pick on the clock. It
+// returns pgx.ErrNoRows if the draft is no longer pending.
+func (r *PSQLDraftRepo) Start(ctx context.Context, id int64, order []int64, deadline time.Time) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE drafts SET status = 'active', started_at = now(), pick_deadline = $2
+		WHERE id = $1 AND status = 'pending'`, id, deadline)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	for i, userID := range order {
+		if _, err := tx.Exec(ctx, "INSERT INTO draft_order (draft_id, slot, user_id) VALUES ($1, $2, $3)", id, i+1, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RecordPick stores a pick and advances the clock. A nil nextDeadline marks
+// the last pick, and the draft is completed in the same transaction. It
+// reports false when the pick was no longer on the clock, e.g. the timer
+// auto-picked first.
+func (r *PSQLDraftRepo) RecordPick(ctx context.Context, draftID int64, pick *models.DraftPick, nextDeadline *time.Time) (bool, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE drafts SET current_pick = current_pick + 1, pick_deadline = $3
+		WHERE id = $1 AND current_pick = $2 AND status = 'active'`,
+		draftID, pick.PickNumber, nextDeadline)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO draft_picks (draft_id, pick_number, round, user_id, player_id, quantity, price, auto)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING picked_at`,
+		draftID, pick.PickNumber, pick.Round, pick.UserID, pick.PlayerID, pick.Quantity, pick.Price, pick.Auto,
+	).Scan(&pick.PickedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if nextDeadline == nil {
+		if err := completeDraft(ctx, tx, draftID); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// completeDraft books every pick as a fee-free BUY on the league's islands at
+// its draft price, charges the members' league wallets and closes the draft.
+func completeDraft(ctx context.Context, tx pgx.Tx, id int64) error {
+	var leagueID int64
+	err := tx.QueryRow(ctx, `
+		UPDATE drafts SET status = 'completed', completed_at = now(), pick_deadline = NULL
+		WHERE id = $1 AND status = 'active'
+		RETURNING league_id`, id).Scan(&leagueID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT pick_number, user_id, player_id, quantity, price
+		FROM draft_picks
+		WHERE draft_id = $1 AND player_id IS NOT NULL
+		ORDER BY pick_number ASC`, id)
+	if err != nil {
+		return err
+	}
+	var picks []models.DraftPick
+	for rows.Next() {
+		var p models.DraftPick
+		if err := rows.Scan(&p.PickNumber, &p.UserID, &p.PlayerID, &p.Quantity, &p.Price); err != nil {
+			rows.Close()
+			return err
+		}
+		picks = append(picks, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range picks {
+		var transactionID int64
+		err := tx.QueryRow(ctx, `
+			INSERT INTO transactions (user_id, asset_id, type, quantity, price, fee, timestamp, league_id)
+			VALUES ($1, $2, 'BUY', $3, $4, 0, now(), $5)
+			RETURNING id`, p.UserID, *p.PlayerID, p.Quantity, p.Price, leagueID).Scan(&transactionID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE draft_picks SET transaction_id = $1 WHERE draft_id = $2 AND pick_number = $3", transactionID, id, p.PickNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE league_members m SET currency = m.currency - spent.cost
+		FROM (
+			SELECT user_id, SUM(quantity * price) AS cost
+			FROM draft_picks
+			WHERE draft_id = $1
+			GROUP BY user_id
+		) spent
+		WHERE m.league_id = $2 AND m.user_id = spent.user_id`, id, leagueID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "REFRESH MATERIALIZED VIEW positions_mv")
+	return err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+// MarketEventsChannel is the NOTIFY channel market events travel on. The
+// publish_market_event SQL function, used by Publish and by the triggers on
+// players, transactions and dividend_payments, notifies it.
+const MarketEventsChannel = "market_events"
+
+type EventRepository interface {
+	// Listen calls handle with every market event until ctx ends or the
+	// connection fails. ready, if set, is called once LISTEN is in place.
+	Listen(ctx context.Context, ready func(), handle func(*models.MarketEvent)) error
+	Publish(ctx context.Context, e *models.MarketEvent) error
+}
+
+type PSQLEventRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLEventRepo) Listen(ctx context.Context, ready func(), handle func(*models.MarketEvent)) error {
+	conn, err := r.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in LISTEN mode, so it must not go back to the pool.
+	defer conn.Hijack().Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+MarketEventsChannel); err != nil {
+		return err
+	}
+	if ready != nil {
+		ready()
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e models.MarketEvent
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			logger.Log.Warn("Dropping malformed market event",
+				zap.String("payload", n.Payload),
+				zap.Error(err),
+			)
+			continue
+		}
+		handle(&e)
+	}
+}
+
+func (r *PSQLEventRepo) Publish(ctx context.Context, e *models.MarketEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// NOTIFY payloads are capped at 8000 bytes.
+	if len(payload) >= 8000 {
+		return fmt.Errorf("market event %s too large to notify: %d bytes", e.Type, len(payload))
+	}
+	// publish_market_event also queues the event for subscribed webhooks.
+	_, err = r.Pool.Exec(ctx, "SELECT publish_market_event($1::jsonb)", string(payload))
+	return err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+var ErrIdentityLinked = errors.New("identity is already linked")
+
+type IdentityRepository interface {
+	Get(ctx context.Context, provider string, subject string) (*models.UserIdentity, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.UserIdentity, error)
+	Create(ctx context.Context, i *models.UserIdentity) error
+	TouchLogin(ctx context.Context, id int64) error
+	CreateState(ctx context.Context, s *models.OIDCState) error
+	ConsumeState(ctx context.Context, state string) (*models.OIDCState, error)
+	DeleteExpiredStates(ctx context.Context) (int64, error)
+}
+
+type PSQLIdentityRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const identityColumns = "id, user_id, provider, subject, email, created_at, last_login_at"
+
+func scanIdentity(row pgx.Row) (*models.UserIdentity, error) {
+	var i models.UserIdentity
+	err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *PSQLIdentityRepo) Get(ctx context.Context, provider string, subject string) (*models.UserIdentity, error) {
+	return scanIdentity(r.Pool.QueryRow(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE provider=$1 AND subject=$2", provider, subject))
+}
+
+func (r *PSQLIdentityRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.UserIdentity, error) {
+	rows, err := r.Pool.Query(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE user_id=$1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]*models.UserIdentity, 0)
+	for rows.Next() {
+		i, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// Create returns ErrIdentityLinked when the subject is already linked, or the
+// user already has an identity at the provider.
+func (r *PSQLIdentityRepo) Create(ctx context.Context, i *models.UserIdentity) error {
+	err := r.Pool.QueryRow(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING id, created_at, last_login_at`,
+		i.UserID, i.Provider, i.Subject, i.Email,
+	).Scan(&i.ID, &i.CreatedAt, &i.LastLoginAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrIdentityLinked
+	}
+	return err
+}
+
+func (r *PSQLIdentityRepo) TouchLogin(ctx context.Context, id int64) error {
+	_, err := r.Pool.Exec(ctx, "UPDATE user_identities SET last_login_at=now() WHERE id=$1", id)
+	return err
+}
+
+func (r *PSQLIdentityRepo) CreateState(ctx context.Context, s *models.OIDCState) error {
+	_, err := r.Pool.Exec(ctx, `
+		INSERT INTO oidc_states (state, provider, nonce, code_verifier, user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		s.State, s.Provider, s.Nonce, s.CodeVerifier, s.UserID, s.ExpiresAt)
+	return err
+}
+
+// ConsumeState deletes and returns an unexpired state, so each can complete
+// one sign-in.
+func (r *PSQLIdentityRepo) ConsumeState(ctx context.Context, state string) (*models.OIDCState, error) {
+	var s models.OIDCState
+	err := r.Pool.QueryRow(ctx, `
+		DELETE FROM oidc_states
+		WHERE state = $1 AND expires_at > now()
+		RETURNING state, provider, nonce, code_verifier, user_id, expires_at`, state,
+	).Scan(&s.State, &s.Provider, &s.Nonce, &s.CodeVerifier, &s.UserID, &s.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PSQLIdentityRepo) DeleteExpiredStates(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, "DELETE FROM oidc_states WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
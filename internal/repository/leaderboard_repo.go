@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type LeaderboardRepository interface {
+	GetRanked(ctx context.Context, kind string, windowDays int, limit int, offset int) ([]*models.LeaderboardEntry, int, error)
+	Snapshot(ctx context.Context, kind string) error
+	GetHistory(ctx context.Context, kind string, userID int64, since *time.Time) ([]models.LeaderboardSnapshot, error)
+	GetLeagueRanked(ctx context.Context, kind string, leagueID int64, contained bool, limit int, offset int) ([]*models.LeaderboardEntry, int, error)
+}
+
+type PSQLLeaderboardRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const netWorthScores = `
+	SELECT u.id AS user_id,
+		COALESCE(u.currency, 0) + COALESCE(SUM(pos.quantity * p.value), 0) AS score
+	FROM users u
+	LEFT JOIN positions_mv pos ON pos.user_id = u.id AND pos.league_id = 0
+	LEFT JOIN players p ON p.id = pos.asset_id
+	GROUP BY u.id`
+
+// leaderboardScores holds one query per kind producing (user_id, score).
+var leaderboardScores = map[string]string{
+	models.LeaderboardNetWorth: netWorthScores,
+
+	// Percentage return on the season's starting grant.
+	models.LeaderboardReturn: `
+	SELECT nw.user_id,
+		(nw.score - s.starting_currency) / NULLIF(s.starting_currency, 0) * 100 AS score
+	FROM (` + netWorthScores + `) nw
+	CROSS JOIN seasons s
+	WHERE s.id = current_season_id()`,
+
+	// Growth since entry on every claim, weighted by 1/rank so the first
+	// believer in a player earns the most from the same growth.
+	models.LeaderboardEarlyBeliever: `
+	SELECT u.id AS user_id,
+		COALESCE(SUM((p.value - c.entry_value) / NULLIF(c.entry_value, 0) / c.rank), 0) * 100 AS score
+	FROM users u
+	LEFT JOIN claims c ON c.user_id = u.id
+	LEFT JOIN players p ON p.id = c.player_id
+	GROUP BY u.id`,
+}
+
+const leagueNetWorthScores = `
+	SELECT m.user_id,
+		COALESCE(m.currency, 0) + COALESCE(SUM(pos.quantity * p.value), 0) AS score
+	FROM league_members m
+	LEFT JOIN positions_mv pos ON pos.user_id = m.user_id AND pos.league_id = m.league_id
+	LEFT JOIN players p ON p.id = pos.asset_id
+	WHERE m.league_id = $1
+	GROUP BY m.user_id, m.currency`
+
+// containedLeagueScores replaces the global scores for leagues that run their
+// own wallets and islands. Kinds missing here (claims are only earned on the
+// global islands) fall back to the members' global scores. $1 is the league.
+var containedLeagueScores = map[string]string{
+	models.LeaderboardNetWorth: leagueNetWorthScores,
+
+	models.LeaderboardReturn: `
+	SELECT nw.user_id,
+		(nw.score - l.starting_currency) / NULLIF(l.starting_currency, 0) * 100 AS score
+	FROM (` + leagueNetWorthScores + `) nw
+	JOIN leagues l ON l.id = $1`,
+}
+
+func scoresQuery(kind string) (string, error) {
+	q, ok := leaderboardScores[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown leaderboard %q", kind)
+	}
+	return q, nil
+}
+
+// GetRanked ranks users by their live score. With windowDays > 0 the score
+// is the change since the latest snapshot at least that many days old.
+func (r *PSQLLeaderboardRepo) GetRanked(ctx context.Context, kind string, windowDays int, limit int, offset int) ([]*models.LeaderboardEntry, int, error) {
+	scores, err := scoresQuery(kind)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		WITH scores AS (%s),
+		baseline AS (
+			SELECT user_id, score FROM leaderboard_snapshots
+			WHERE kind = $1 AND snapshot_date = (
+				SELECT MAX(snapshot_date) FROM leaderboard_snapshots
+				WHERE kind = $1 AND snapshot_date <= CURRENT_DATE - $2::int
+			)
+		),
+		windowed AS (
+			SELECT s.user_id,
+				CASE WHEN $2::int = 0 THEN s.score ELSE s.score - COALESCE(b.score, s.score) END AS score
+			FROM scores s
+			LEFT JOIN baseline b ON b.user_id = s.user_id
+		)
+		SELECT RANK() OVER (ORDER BY w.score DESC) AS rank, w.user_id, u.username, w.score, COUNT(*) OVER ()
+		FROM windowed w
+		JOIN users u ON u.id = w.user_id
+		ORDER BY rank ASC, u.username ASC
+		LIMIT $3 OFFSET $4`, scores)
+
+	rows, err := r.Pool.Query(ctx, query, kind, windowDays, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	entries := make([]*models.LeaderboardEntry, 0)
+	for rows.Next() {
+		e := &models.LeaderboardEntry{}
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Username, &e.Score, &total); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// GetLeagueRanked ranks the members of one league by their live score.
+func (r *PSQLLeaderboardRepo) GetLeagueRanked(ctx context.Context, kind string, leagueID int64, contained bool, limit int, offset int) ([]*models.LeaderboardEntry, int, error) {
+	scores, ok := containedLeagueScores[kind]
+	if !contained || !ok {
+		global, err := scoresQuery(kind)
+		if err != nil {
+			return nil, 0, err
+		}
+		scores = `
+		SELECT s.user_id, s.score
+		FROM (` + global + `) s
+		JOIN league_members m ON m.user_id = s.user_id
+		WHERE m.league_id = $1`
+	}
+
+	query := fmt.Sprintf(`
+		WITH scores AS (%s)
+		SELECT RANK() OVER (ORDER BY s.score DESC) AS rank, s.user_id, u.username, s.score, COUNT(*) OVER ()
+		FROM scores s
+		JOIN users u ON u.id = s.user_id
+		ORDER BY rank ASC, u.username ASC
+		LIMIT $2 OFFSET $3`, scores)
+
+	rows, err := r.Pool.Query(ctx, query, leagueID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	entries := make([]*models.LeaderboardEntry, 0)
+	for rows.Next() {
+		e := &models.LeaderboardEntry{}
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Username, &e.Score, &total); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+func (r *PSQLLeaderboardRepo) Snapshot(ctx context.Context, kind string) error {
+	scores, err := scoresQuery(kind)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO leaderboard_snapshots (snapshot_date, kind, user_id, rank, score)
+		SELECT CURRENT_DATE, $1, user_id, RANK() OVER (ORDER BY score DESC), score
+		FROM (%s) scores
+		ON CONFLICT (snapshot_date, kind, user_id)
+		DO UPDATE SET rank = EXCLUDED.rank, score = EXCLUDED.score`, scores), kind)
+	return err
+}
+
+func (r *PSQLLeaderboardRepo) GetHistory(ctx context.Context, kind string, userID int64, since *time.Time) ([]models.LeaderboardSnapshot, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT snapshot_date, rank, score FROM leaderboard_snapshots
+		WHERE kind = $1 AND user_id = $2
+		AND ($3::timestamptz IS NULL OR snapshot_date >= $3::timestamptz::date)
+		ORDER BY snapshot_date ASC`, kind, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.LeaderboardSnapshot, 0)
+	for rows.Next() {
+		var s models.LeaderboardSnapshot
+		if err := rows.Scan(&s.Date, &s.Rank, &s.Score); err != nil {
+			return nil, err
+		}
+		history = append(history, s)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type LeagueRepository interface {
+	GetByID(ctx context.Context, id int64) (*models.League, error)
+	GetByInviteCode(ctx context.Context, code string) (*models.League, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.League, error)
+	Create(ctx context.Context, l *models.League) error
+	GetMember(ctx context.Context, leagueID int64, userID int64) (*models.LeagueMember, error)
+	GetMembers(ctx context.Context, leagueID int64) ([]*models.LeagueMember, error)
+	AddMember(ctx context.Context, m *models.LeagueMember) (bool, error)
+	RemoveMember(ctx context.Context, leagueID int64, userID int64) error
+	GetHeldQuantity(ctx context.Context, leagueID int64, playerID int64) (int, error)
+	GetHeldQuantities(ctx context.Context, leagueID int64) (map[int64]int, error)
+	HasOpenPositions(ctx context.Context, leagueID int64, userID int64) (bool, error)
+}
+
+type PSQLLeagueRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const leagueColumns = "l.id, l.name, l.invite_code, l.owner_id, l.starting_currency, l.island_capacity, l.created_at"
+
+func scanLeague(row pgx.Row) (*models.League, error) {
+	var l models.League
+	err := row.Scan(
+		&l.ID,
+		&l.Name,
+		&l.InviteCode,
+		&l.OwnerID,
+		&l.StartingCurrency,
+		&l.IslandCapacity,
+		&l.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *PSQLLeagueRepo) GetByID(ctx context.Context, id int64) (*models.League, error) {
+	return scanLeague(r.Pool.QueryRow(ctx, "SELECT "+leagueColumns+" FROM leagues l WHERE l.id=$1", id))
+}
+
+func (r *PSQLLeagueRepo) GetByInviteCode(ctx context.Context, code string) (*models.League, error) {
+	return scanLeague(r.Pool.QueryRow(ctx, "SELECT "+leagueColumns+" FROM leagues l WHERE l.invite_code=$1", code))
+}
+
+func (r *PSQLLeagueRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.League, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+leagueColumns+`
+		FROM leagues l
+		JOIN league_members m ON m.league_id = l.id
+		WHERE m.user_id = $1
+		ORDER BY l.created_at ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leagues := make([]*models.League, 0)
+	for rows.Next() {
+		l, err := scanLeague(rows)
+		if err != nil {
+			return nil, err
+		}
+		leagues = append(leagues, l)
+	}
+	return leagues, rows.Err()
+}
+
+// Create inserts the league and enrolls its owner as the first member, with
+// the league's starting currency when it is contained.
+func (r *PSQLLeagueRepo) Create(ctx context.Context, l *models.League) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO leagues (name, invite_code, owner_id, starting_currency, island_capacity)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		l.Name, l.InviteCode, l.OwnerID, l.StartingCurrency, l.IslandCapacity,
+	).Scan(&l.ID, &l.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO league_members (league_id, user_id, currency)
+		VALUES ($1, $2, $3)`, l.ID, l.OwnerID, l.StartingCurrency)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+const leagueMemberColumns = "m.league_id, m.user_id, u.username, m.currency, m.joined_at"
+
+func scanLeagueMember(row pgx.Row) (*models.LeagueMember, error) {
+	var m models.LeagueMember
+	err := row.Scan(
+		&m.LeagueID,
+		&m.UserID,
+		&m.Username,
+		&m.Currency,
+		&m.JoinedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *PSQLLeagueRepo) GetMember(ctx context.Context, leagueID int64, userID int64) (*models.LeagueMember, error) {
+	return scanLeagueMember(r.Pool.QueryRow(ctx, `
+		SELECT `+leagueMemberColumns+`
+		FROM league_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.league_id = $1 AND m.user_id = $2`, leagueID, userID))
+}
+
+func (r *PSQLLeagueRepo) GetMembers(ctx context.Context, leagueID int64) ([]*models.LeagueMember, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+leagueMemberColumns+`
+		FROM league_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.league_id = $1
+		ORDER BY m.joined_at ASC`, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]*models.LeagueMember, 0)
+	for rows.Next() {
+		m, err := scanLeagueMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddMember reports false when the user is already in the league.
+func (r *PSQLLeagueRepo) AddMember(ctx context.Context, m *models.LeagueMember) (bool, error) {
+	err := r.Pool.QueryRow(ctx, `
+		INSERT INTO league_members (league_id, user_id, currency)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (league_id, user_id) DO NOTHING
+		RETURNING joined_at`, m.LeagueID, m.UserID, m.Currency).Scan(&m.JoinedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *PSQLLeagueRepo) RemoveMember(ctx context.Context, leagueID int64, userID int64) error {
+	_, err := r.Pool.Exec(ctx, "DELETE FROM league_members WHERE league_id=$1 AND user_id=$2", leagueID, userID)
+	return err
+}
+
+// GetHeldQuantity returns how many shares of a player are held across the
+// league's islands this season.
+func (r *PSQLLeagueRepo) GetHeldQuantity(ctx context.Context, leagueID int64, playerID int64) (int, error) {
+	var held int
+	err := r.Pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM positions_mv
+		WHERE league_id = $1 AND asset_id = $2`, leagueID, playerID).Scan(&held)
+	return held, err
+}
+
+// GetHeldQuantities is GetHeldQuantity for every player held in the league.
+func (r *PSQLLeagueRepo) GetHeldQuantities(ctx context.Context, leagueID int64) (map[int64]int, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT asset_id, SUM(quantity) FROM positions_mv
+		WHERE league_id = $1
+		GROUP BY asset_id`, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := make(map[int64]int)
+	for rows.Next() {
+		var playerID int64
+		var quantity int
+		if err := rows.Scan(&playerID, &quantity); err != nil {
+			return nil, err
+		}
+		held[playerID] = quantity
+	}
+	return held, rows.Err()
+}
+
+func (r *PSQLLeagueRepo) HasOpenPositions(ctx context.Context, leagueID int64, userID int64) (bool, error) {
+	var open bool
+	err := r.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM positions_mv WHERE league_id = $1 AND user_id = $2)`,
+		leagueID, userID).Scan(&open)
+	return open, err
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+// ErrInvalidCursor is returned by list queries for a cursor that was not
+// issued for the requested sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type keyKind int
+
+const (
+	keyInt keyKind = iota
+	keyFloat
+	keyText
+	keyTime
+)
+
+// listKey is a column a list of T is ordered by. value reads the column
+// from a row for its cursor.
+type listKey[T any] struct {
+	expr  string
+	kind  keyKind
+	value func(T) interface{}
+}
+
+// column is the expression rows are ordered and compared on. NUMERIC keys
+// are read into float64, which cannot hold every NUMERIC exactly, so they are
+// ordered as float8: that is the value the row was scanned into, and the
+// cursor carries it exactly. Comparing the NUMERIC itself with the rounded
+// cursor value would repeat or skip rows at page boundaries.
+func (k listKey[T]) column() string {
+	if k.kind == keyFloat {
+		return "(" + k.expr + ")::float8"
+	}
+	return k.expr
+}
+
+// parse turns a cursor value back into a query argument of the column's type.
+func (k listKey[T]) parse(v string) (interface{}, error) {
+	switch k.kind {
+	case keyInt:
+		return strconv.ParseInt(v, 10, 64)
+	case keyFloat:
+		return strconv.ParseFloat(v, 64)
+	case keyTime:
+		return time.Parse(time.RFC3339Nano, v)
+	}
+	return v, nil
+}
+
+func formatKey(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	}
+	panic(fmt.Sprintf("unsupported cursor key %T", v))
+}
+
+// keyset orders a list by a sort column followed by tie-break columns that
+// together identify a row, all in one direction. A cursor holds the last
+// row's values for those columns, so the next page starts right after it
+// whatever was inserted meanwhile.
+type keyset[T any] struct {
+	name string
+	keys []listKey[T]
+	desc bool
+}
+
+func newKeyset[T any](sort string, desc bool, sorts map[string]listKey[T], ties ...listKey[T]) (keyset[T], error) {
+	key, ok := sorts[sort]
+	if !ok {
+		return keyset[T]{}, fmt.Errorf("unknown sort %q", sort)
+	}
+	keys := []listKey[T]{key}
+	for _, tie := range ties {
+		if tie.expr != key.expr {
+			keys = append(keys, tie)
+		}
+	}
+	return keyset[T]{name: sort, keys: keys, desc: desc}, nil
+}
+
+func (k keyset[T]) orderBy() string {
+	dir := " ASC"
+	if k.desc {
+		dir = " DESC"
+	}
+	cols := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		cols[i] = key.column() + dir
+	}
+	return strings.Join(cols, ", ")
+}
+
+type cursorData struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func (k keyset[T]) sortName() string {
+	if k.desc {
+		return "-" + k.name
+	}
+	return k.name
+}
+
+// cursor builds the cursor that resumes after row.
+func (k keyset[T]) cursor(row T) string {
+	data := cursorData{Sort: k.sortName(), Values: make([]string, len(k.keys))}
+	for i, key := range k.keys {
+		data.Values[i] = formatKey(key.value(row))
+	}
+	raw, _ := json.Marshal(data)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// after limits q to rows past cursor. An empty cursor changes nothing.
+func (k keyset[T]) after(q *listQuery, cursor string) error {
+	if cursor == "" {
+		return nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var data cursorData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrInvalidCursor
+	}
+	if data.Sort != k.sortName() || len(data.Values) != len(k.keys) {
+		return ErrInvalidCursor
+	}
+
+	cols := make([]string, len(k.keys))
+	params := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		v, err := key.parse(data.Values[i])
+		if err != nil {
+			return ErrInvalidCursor
+		}
+		cols[i] = key.column()
+		params[i] = q.arg(v)
+	}
+	op := ">"
+	if k.desc {
+		op = "<"
+	}
+	q.where(fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), op, strings.Join(params, ", ")))
+	return nil
+}
+
+// listQuery collects the WHERE conditions and arguments of a list query.
+type listQuery struct {
+	conds []string
+	args  []interface{}
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *listQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *listQuery) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+// sql completes base, a SELECT without WHERE, with the conditions, the
+// keyset order and a limit one past the page size so the caller can tell
+// whether another page follows.
+func (q *listQuery) sql(base string, orderBy string, limit int) string {
+	var b strings.Builder
+	b.WriteString(base)
+	if len(q.conds) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(q.conds, " AND "))
+	}
+	b.WriteString(" ORDER BY ")
+	b.WriteString(orderBy)
+	b.WriteString(" LIMIT ")
+	b.WriteString(q.arg(limit + 1))
+	return b.String()
+}
+
+// likePattern escapes s for a substring match with ILIKE.
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
+
+// newPage trims rows fetched with listQuery.sql to limit and sets the cursor
+// for the next page when there is one.
+func newPage[T any](rows []T, limit int, k keyset[T]) *models.Page[T] {
+	p := &models.Page[T]{Items: rows}
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	if len(rows) > limit {
+		p.Items = rows[:limit]
+		p.NextCursor = k.cursor(rows[limit-1])
+	}
+	return p
+}
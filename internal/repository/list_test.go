@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type listRow struct {
+	ID    int64
+	Price float64
+	Name  string
+	At    time.Time
+}
+
+var listRowSorts = map[string]listKey[listRow]{
+	"price": {"price", keyFloat, func(r listRow) interface{} { return r.Price }},
+	"name":  {"name", keyText, func(r listRow) interface{} { return r.Name }},
+	"at":    {"at", keyTime, func(r listRow) interface{} { return r.At }},
+	"id":    {"id", keyInt, func(r listRow) interface{} { return r.ID }},
+}
+
+// resume builds the cursor after row and returns the condition and
+// arguments it adds to the next page's query.
+func resume(t *testing.T, k keyset[listRow], row listRow) (string, []interface{}) {
+	t.Helper()
+	q := &listQuery{}
+	if err := k.after(q, k.cursor(row)); err != nil {
+		t.Fatalf("after: %v", err)
+	}
+	if len(q.conds) != 1 {
+		t.Fatalf("conditions = %v, want one", q.conds)
+	}
+	return q.conds[0], q.args
+}
+
+func TestCursorRoundTripsKeysExactly(t *testing.T) {
+	at := time.Date(2025, 3, 9, 14, 30, 15, 123456000, time.FixedZone("EST", -5*3600))
+	cases := []struct {
+		sort string
+		row  listRow
+		want interface{}
+	}{
+		// NUMERIC averages like 100/3 are not exact in float64; the cursor
+		// must give back the very float64 the row was read into.
+		{"price", listRow{ID: 1, Price: 100.0 / 3}, 100.0 / 3},
+		{"price", listRow{ID: 1, Price: 0.1 + 0.2}, 0.1 + 0.2},
+		{"price", listRow{ID: 1, Price: 123456789012.123456}, 123456789012.123456},
+		{"price", listRow{ID: 1, Price: 1e-7}, 1e-7},
+		{"price", listRow{ID: 1, Price: math.Nextafter(2, 3)}, math.Nextafter(2, 3)},
+		{"name", listRow{ID: 1, Name: `comma, "quote" and ünïcode`}, `comma, "quote" and ünïcode`},
+		{"at", listRow{ID: 1, At: at}, at},
+	}
+	for _, tc := range cases {
+		k, err := newKeyset(tc.sort, false, listRowSorts, listRowSorts["id"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, args := resume(t, k, tc.row)
+		if len(args) != 2 {
+			t.Fatalf("%s: args = %v, want the key and the id", tc.sort, args)
+		}
+		switch want := tc.want.(type) {
+		case time.Time:
+			if got, ok := args[0].(time.Time); !ok || !got.Equal(want) {
+				t.Errorf("%s: cursor gave %v, want %v", tc.sort, args[0], want)
+			}
+		default:
+			if args[0] != want {
+				t.Errorf("%s: cursor gave %v, want %v", tc.sort, args[0], want)
+			}
+		}
+		if args[1] != int64(1) {
+			t.Errorf("%s: tie-break gave %v, want 1", tc.sort, args[1])
+		}
+	}
+}
+
+func TestKeysetComparesFloatKeysAsScanned(t *testing.T) {
+	k, err := newKeyset("price", true, listRowSorts, listRowSorts["id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, _ := resume(t, k, listRow{ID: 9, Price: 100.0 / 3})
+
+	if want := "((price)::float8, id) < ($1, $2)"; cond != want {
+		t.Errorf("condition = %q, want %q", cond, want)
+	}
+	if want := "(price)::float8 DESC, id DESC"; k.orderBy() != want {
+		t.Errorf("order = %q, want %q", k.orderBy(), want)
+	}
+}
+
+func TestKeysetSkipsTieBreakOnSortColumn(t *testing.T) {
+	k, err := newKeyset("id", false, listRowSorts, listRowSorts["id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id ASC"; k.orderBy() != want {
+		t.Errorf("order = %q, want %q", k.orderBy(), want)
+	}
+}
+
+func TestCursorRejectedForOtherSort(t *testing.T) {
+	byPrice, _ := newKeyset("price", false, listRowSorts, listRowSorts["id"])
+	byPriceDesc, _ := newKeyset("price", true, listRowSorts, listRowSorts["id"])
+	byName, _ := newKeyset("name", false, listRowSorts, listRowSorts["id"])
+	cursor := byPrice.cursor(listRow{ID: 1, Price: 2})
+
+	cases := []struct {
+		name   string
+		k      keyset[listRow]
+		cursor string
+	}{
+		{"other column", byName, cursor},
+		{"other direction", byPriceDesc, cursor},
+		{"not base64", byPrice, "%%%"},
+		{"not json", byPrice, "bm90IGpzb24"},
+		{"bad value", byPrice, base64.RawURLEncoding.EncodeToString([]byte(`{"s":"price","v":["x","1"]}`))},
+		{"missing value", byPrice, base64.RawURLEncoding.EncodeToString([]byte(`{"s":"price","v":["2"]}`))},
+	}
+	for _, tc := range cases {
+		if err := tc.k.after(&listQuery{}, tc.cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", tc.name, err)
+		}
+	}
+}
+
+func TestUnknownSortRejected(t *testing.T) {
+	if _, err := newKeyset("secret", false, listRowSorts); err == nil {
+		t.Error("newKeyset accepted an unknown sort")
+	}
+}
+
+func TestNewPageSetsCursorOnlyWhenMoreFollow(t *testing.T) {
+	k, _ := newKeyset("id", false, listRowSorts)
+	rows := []listRow{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	page := newPage(rows, 2, k)
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("page = %+v, want two items and a cursor", page)
+	}
+	_, args := resume(t, k, page.Items[1])
+	if args[0] != int64(2) {
+		t.Errorf("next page resumes after %v, want 2", args[0])
+	}
+
+	if last := newPage(rows, 3, k); last.NextCursor != "" {
+		t.Errorf("last page has cursor %q", last.NextCursor)
+	}
+	if empty := newPage[listRow](nil, 3, k); empty.Items == nil {
+		t.Error("empty page has nil items, which encode as null")
+	}
+}
+
+func TestLikePatternEscapesWildcards(t *testing.T) {
+	if got, want := likePattern(`50%_off\`), `%50\%\_off\\%`; got != want {
+		t.Errorf("likePattern = %q, want %q", got, want)
+	}
+}
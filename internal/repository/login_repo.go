@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type LoginRepository interface {
+	LockedUntil(ctx context.Context, keys []string) (*time.Time, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, window time.Duration) (int64, error)
+	CreateFailedLogin(ctx context.Context, f *models.FailedLogin) error
+	GetFailedLogins(ctx context.Context, username string, limit int) ([]*models.FailedLogin, error)
+}
+
+type PSQLLoginRepo struct {
+	Pool *pgxpool.Pool
+}
+
+// LockedUntil returns the latest lockout still in force across keys, or nil.
+func (r *PSQLLoginRepo) LockedUntil(ctx context.Context, keys []string) (*time.Time, error) {
+	var until *time.Time
+	err := r.Pool.QueryRow(ctx, `
+		SELECT MAX(locked_until) FROM login_throttles
+		WHERE key = ANY($1) AND locked_until > now()`, keys).Scan(&until)
+	return until, err
+}
+
+// RecordFailure counts a failed attempt against key and returns the number of
+// consecutive failures. The count starts over once the previous failure is
+// older than window.
+func (r *PSQLLoginRepo) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.Pool.QueryRow(ctx, `
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < now() - $2::interval THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = now()
+		RETURNING failures`, key, window).Scan(&failures)
+	return failures, err
+}
+
+func (r *PSQLLoginRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.Pool.Exec(ctx, "UPDATE login_throttles SET locked_until=$1 WHERE key=$2", until, key)
+	return err
+}
+
+func (r *PSQLLoginRepo) Reset(ctx context.Context, key string) error {
+	_, err := r.Pool.Exec(ctx, "DELETE FROM login_throttles WHERE key=$1", key)
+	return err
+}
+
+// DeleteStale drops counters that have neither a live lockout nor a failure
+// inside window.
+func (r *PSQLLoginRepo) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < now() - $1::interval
+			AND (locked_until IS NULL OR locked_until < now())`, window)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *PSQLLoginRepo) CreateFailedLogin(ctx context.Context, f *models.FailedLogin) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO failed_logins (username, user_id, ip, user_agent, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		f.Username, f.UserID, f.IP, f.UserAgent, f.Reason,
+	).Scan(&f.ID, &f.CreatedAt)
+}
+
+// GetFailedLogins returns the most recent failures, for one username when it
+// is not empty.
+func (r *PSQLLoginRepo) GetFailedLogins(ctx context.Context, username string, limit int) ([]*models.FailedLogin, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT id, username, user_id, ip, user_agent, reason, created_at
+		FROM failed_logins
+		WHERE $1 = '' OR username = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := make([]*models.FailedLogin, 0)
+	for rows.Next() {
+		var f models.FailedLogin
+		if err := rows.Scan(&f.ID, &f.Username, &f.UserID, &f.IP, &f.UserAgent, &f.Reason, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, &f)
+	}
+	return failures, rows.Err()
+}
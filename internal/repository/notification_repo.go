@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, n *models.Notification) error
+	List(ctx context.Context, filter models.NotificationFilter, req models.PageRequest) (*models.Page[*models.Notification], error)
+	CountUnread(ctx context.Context, userID int64) (int, error)
+	// MarkRead reports false when the user has no such notification.
+	MarkRead(ctx context.Context, id int64, userID int64) (bool, error)
+	MarkAllRead(ctx context.Context, userID int64) (int64, error)
+	PruneRead(ctx context.Context, before time.Time) (int64, error)
+}
+
+type PSQLNotificationRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLNotificationRepo) Create(ctx context.Context, n *models.Notification) error {
+	return createNotification(ctx, r.Pool, n)
+}
+
+// rowQuerier is a pool or a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// createNotification inserts n through db, which may be a transaction.
+func createNotification(ctx context.Context, db rowQuerier, n *models.Notification) error {
+	return db.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, type, title, body, player_id, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		n.UserID, n.Type, n.Title, n.Body, n.PlayerID, n.Data,
+	).Scan(&n.ID, &n.CreatedAt)
+}
+
+var notificationSorts = map[string]listKey[*models.Notification]{
+	models.NotificationSortCreatedAt: {"created_at", keyTime, func(n *models.Notification) interface{} { return n.CreatedAt }},
+}
+
+var notificationID = listKey[*models.Notification]{"id", keyInt, func(n *models.Notification) interface{} { return n.ID }}
+
+func (r *PSQLNotificationRepo) List(ctx context.Context, filter models.NotificationFilter, req models.PageRequest) (*models.Page[*models.Notification], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, notificationSorts, notificationID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &listQuery{}
+	q.where("user_id = " + q.arg(filter.UserID))
+	if filter.UnreadOnly {
+		q.where("read_at IS NULL")
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT id, user_id, type, title, body, player_id, data, read_at, created_at FROM notifications", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		n := &models.Notification{}
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body, &n.PlayerID, &n.Data, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(notifications, req.Limit, keys), nil
+}
+
+func (r *PSQLNotificationRepo) CountUnread(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *PSQLNotificationRepo) MarkRead(ctx context.Context, id int64, userID int64) (bool, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE notifications SET read_at = COALESCE(read_at, now())
+		WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PSQLNotificationRepo) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE notifications SET read_at = now()
+		WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneRead deletes notifications read before before.
+func (r *PSQLNotificationRepo) PruneRead(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM notifications WHERE read_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -18,6 +18,7 @@ type PlayerRepository interface {
 	UpdateCapacity(ctx context.Context, id int64, c int) error
 	GetAllIDs(ctx context.Context) ([]int64, error)
     GetAll(ctx context.Context) ([]*models.Player, error)
+	List(ctx context.Context, filter models.PlayerFilter, req models.PageRequest) (*models.Page[*models.Player], error)
 	GetByIDs(ctx context.Context, ids []int64) ([]*models.Player, error)
     Create(ctx context.Context, u *models.Player) error
     Update(ctx context.Context, u *models.Player) error
@@ -149,6 +150,56 @@ func (r *PSQLPlayerRepo) GetAll(ctx context.Context) ([]*models.Player, error){
 	return players, nil
 }
 
+var playerSorts = map[string]listKey[*models.Player]{
+	models.PlayerSortID:       {"id", keyInt, func(p *models.Player) interface{} { return p.ID }},
+	models.PlayerSortName:     {"name", keyText, func(p *models.Player) interface{} { return p.Name }},
+	models.PlayerSortValue:    {"value", keyFloat, func(p *models.Player) interface{} { return p.Value }},
+	models.PlayerSortCapacity: {"capacity", keyInt, func(p *models.Player) interface{} { return p.Capacity }},
+}
+
+func (r *PSQLPlayerRepo) List(ctx context.Context, filter models.PlayerFilter, req models.PageRequest) (*models.Page[*models.Player], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, playerSorts, playerSorts[models.PlayerSortID])
+	if err != nil {
+		return nil, err
+	}
+
+	q := &listQuery{}
+	if filter.MinValue != nil {
+		q.where("value >= " + q.arg(*filter.MinValue))
+	}
+	if filter.MaxValue != nil {
+		q.where("value <= " + q.arg(*filter.MaxValue))
+	}
+	if filter.Search != "" {
+		q.where("name ILIKE " + q.arg(likePattern(filter.Search)))
+	}
+	if filter.MinCapacity != nil {
+		q.where("capacity >= " + q.arg(*filter.MinCapacity))
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT id, name, value, capacity, slug FROM players", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []*models.Player
+	for rows.Next() {
+		p := &models.Player{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Value, &p.Capacity, &p.Slug); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(players, req.Limit, keys), nil
+}
+
 func (r *PSQLPlayerRepo) GetAllIDs(ctx context.Context) ([]int64, error) {
 	rows, err := r.Pool.Query(ctx, `SELECT id FROM players`)
 	if err != nil {
@@ -175,12 +226,12 @@ func (r *PSQLPlayerRepo) GetAllIDs(ctx context.Context) ([]int64, error) {
 
 
 func (r *PSQLPlayerRepo) Create(ctx context.Context, p *models.Player) error {
-	_, err := r.Pool.Exec(ctx, "INSERT INTO players (name, value, capacity) VALUES ($1, $2, $3)", p.Name, p.Value, p.Capacity)
+	_, err := r.Pool.Exec(ctx, "INSERT INTO players (name, value, capacity, base_capacity) VALUES ($1, $2, $3, $3)", p.Name, p.Value, p.Capacity)
 	return err
 }
 
 func (r *PSQLPlayerRepo) Update(ctx context.Context, p *models.Player) error {
-	_, err := r.Pool.Exec(ctx, "UPDATE players SET name=$2, value=$3, capacity=$4 where id = $1", p.ID, p.Name, p.Value, p.Capacity)
+	_, err := r.Pool.Exec(ctx, "UPDATE players SET name=$2, value=$3, capacity=$4, base_capacity=base_capacity+($4-capacity) where id = $1", p.ID, p.Name, p.Value, p.Capacity)
 	return err
 }
 
@@ -210,8 +261,10 @@ func (r *PSQLPlayerRepo) UpdateAllValues(ctx context.Context, updates map[int64]
 	return tx.Commit(ctx)
 }
 
+// UpdateCapacity sets what is left to buy; the base capacity the player
+// reopens at moves by the same amount.
 func (r *PSQLPlayerRepo) UpdateCapacity(ctx context.Context, id int64, c int) error {
-	_, err := r.Pool.Exec(ctx, "UPDATE players SET capacity=$1 WHERE id=$2", c, id)
+	_, err := r.Pool.Exec(ctx, "UPDATE players SET capacity=$1, base_capacity=base_capacity+($1-capacity) WHERE id=$2", c, id)
 	return err
 }
 
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type PortfolioRepository interface {
+	SnapshotAll(ctx context.Context) (int64, error)
+	GetHistory(ctx context.Context, userID int64, since *time.Time) ([]models.PortfolioPoint, error)
+}
+
+type PSQLPortfolioRepo struct {
+	Pool *pgxpool.Pool
+}
+
+// SnapshotAll records today's cash, market value and cost basis for every
+// user. Rerunning on the same day overwrites that day's snapshot.
+func (r *PSQLPortfolioRepo) SnapshotAll(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		INSERT INTO portfolio_snapshots (user_id, snapshot_date, cash, market_value, cost_basis, "timestamp")
+		SELECT u.id, CURRENT_DATE,
+			COALESCE(u.currency, 0),
+			COALESCE(SUM(pos.quantity * p.value), 0),
+			COALESCE(SUM(pos.quantity * pos.average_cost), 0),
+			NOW()
+		FROM users u
+		LEFT JOIN positions_mv pos ON pos.user_id = u.id AND pos.league_id = 0
+		LEFT JOIN players p ON p.id = pos.asset_id
+		GROUP BY u.id
+		ON CONFLICT (user_id, snapshot_date) DO UPDATE SET
+			cash = EXCLUDED.cash,
+			market_value = EXCLUDED.market_value,
+			cost_basis = EXCLUDED.cost_basis,
+			"timestamp" = EXCLUDED."timestamp"`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *PSQLPortfolioRepo) GetHistory(ctx context.Context, userID int64, since *time.Time) ([]models.PortfolioPoint, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT cash, market_value, cost_basis, timestamp FROM portfolio_snapshots WHERE user_id = $1
+		AND ($2::timestamptz IS NULL OR timestamp >= $2)
+		ORDER BY timestamp ASC`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]models.PortfolioPoint, 0)
+	for rows.Next() {
+		var p models.PortfolioPoint
+		if err := rows.Scan(&p.Cash, &p.MarketValue, &p.CostBasis, &p.Timestamp); err != nil {
+			return nil, err
+		}
+		p.TotalValue = p.Cash + p.MarketValue
+		history = append(history, p)
+	}
+
+	return history, rows.Err()
+}
@@ -2,15 +2,25 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nbaisland/nbaisland/internal/models"
 )
 
 type PlayerPriceRepository interface {
-	GetPlayerPriceHistory(ctx context.Context, playerID int64, timeRange string) ([]models.PricePoint, error)
-	GetAllPlayersPriceHistory(ctx context.Context, timeRange string) (map[int64][]models.PricePoint, error)
+	// GetPlayerPriceHistory returns the recorded values from from, or from
+	// the first, until to. When there are more than maxPoints it keeps the
+	// last value in each of maxPoints equal slices of the window.
+	GetPlayerPriceHistory(ctx context.Context, playerID int64, from *time.Time, to time.Time, maxPoints int) ([]models.PricePoint, error)
+	GetAllPlayersPriceHistory(ctx context.Context, since *time.Time) (map[int64][]models.PricePoint, error)
+	// GetFirstPriceTime returns when the player's first value was recorded,
+	// or nil if none was.
+	GetFirstPriceTime(ctx context.Context, playerID int64) (*time.Time, error)
+	// GetCandles buckets the player's values and global trades by interval,
+	// one of the models.Candle intervals, from the start of the interval
+	// holding from until to.
+	GetCandles(ctx context.Context, playerID int64, interval string, from time.Time, to time.Time) ([]models.Candle, error)
 	RecordPlayerPrice(ctx context.Context, playerID int64, price float64) error
 }
 
@@ -18,39 +28,30 @@ type PSQLPlayerPriceRepo struct {
 	Pool *pgxpool.Pool
 }
 
-func timeRangeInterval(timeRange string) string {
-	switch timeRange {
-		case "7d":
-			return "7 days"
-		case "30d":
-			return "30 days"
-		case "90d":
-			return "90 days"
-		case "1y":
-			return "1 year"
-		case "all":
-			return "100 years"
-		default:
-			return "30 days"
-	}
-}
-
-func (r *PSQLPlayerPriceRepo) GetPlayerPriceHistory(ctx context.Context, playerID int64, timeRange string) ([]models.PricePoint, error) {
-	interval := timeRangeInterval(timeRange)
-
-	query := fmt.Sprintf(`
-		SELECT price, timestamp FROM player_price_history WHERE player_id = $1
-		AND timestamp >= NOW() - INTERVAL '%s'
-		ORDER BY timestamp ASC`, interval)
-
-	rows, err := r.Pool.Query(ctx, query, playerID)
+func (r *PSQLPlayerPriceRepo) GetPlayerPriceHistory(ctx context.Context, playerID int64, from *time.Time, to time.Time, maxPoints int) ([]models.PricePoint, error) {
+	// Each point falls in a slice numbered by how far into the window it is;
+	// DISTINCT ON keeps the latest point of each slice.
+	rows, err := r.Pool.Query(ctx, `
+		WITH bounds AS (
+			SELECT COALESCE($2::timestamptz, min("timestamp")) AS start
+			FROM player_price_history
+			WHERE player_id = $1
+		)
+		SELECT DISTINCT ON (slice) price::float8, ts
+		FROM (
+			SELECT h.price, h."timestamp" AS ts, h.id,
+				floor(extract(epoch FROM h."timestamp" - b.start)
+					/ GREATEST(extract(epoch FROM $3::timestamptz - b.start) / $4, 1)) AS slice
+			FROM player_price_history h, bounds b
+			WHERE h.player_id = $1 AND h."timestamp" >= b.start AND h."timestamp" <= $3
+		) points
+		ORDER BY slice, ts DESC, id DESC`, playerID, from, to, maxPoints)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var history []models.PricePoint
-
+	history := make([]models.PricePoint, 0)
 	for rows.Next() {
 		var p models.PricePoint
 		if err := rows.Scan(&p.Price, &p.Timestamp); err != nil {
@@ -62,15 +63,11 @@ func (r *PSQLPlayerPriceRepo) GetPlayerPriceHistory(ctx context.Context, playerI
 	return history, rows.Err()
 }
 
-func (r *PSQLPlayerPriceRepo) GetAllPlayersPriceHistory(ctx context.Context, timeRange string) (map[int64][]models.PricePoint, error) {
-
-	interval := timeRangeInterval(timeRange)
-
-	query := fmt.Sprintf(`
-		SELECT player_id, price, timestamp FROM player_price_history WHERE timestamp >= NOW() - INTERVAL '%s'
-		ORDER BY player_id, timestamp ASC`, interval)
-
-	rows, err := r.Pool.Query(ctx, query)
+func (r *PSQLPlayerPriceRepo) GetAllPlayersPriceHistory(ctx context.Context, since *time.Time) (map[int64][]models.PricePoint, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT player_id, price, timestamp FROM player_price_history
+		WHERE $1::timestamptz IS NULL OR timestamp >= $1
+		ORDER BY player_id, timestamp ASC`, since)
 	if err != nil {
 		return nil, err
 	}
@@ -92,9 +89,88 @@ func (r *PSQLPlayerPriceRepo) GetAllPlayersPriceHistory(ctx context.Context, tim
 	return result, rows.Err()
 }
 
+func (r *PSQLPlayerPriceRepo) GetFirstPriceTime(ctx context.Context, playerID int64) (*time.Time, error) {
+	var first *time.Time
+	err := r.Pool.QueryRow(ctx, `SELECT min("timestamp") FROM player_price_history WHERE player_id = $1`, playerID).Scan(&first)
+	return first, err
+}
+
+// GetCandles fills every interval in the window. An interval's open is the
+// previous close, or the value before the window for the first; intervals
+// before the player's first value are left out.
+func (r *PSQLPlayerPriceRepo) GetCandles(ctx context.Context, playerID int64, interval string, from time.Time, to time.Time) ([]models.Candle, error) {
+	rows, err := r.Pool.Query(ctx, `
+		WITH bounds AS (
+			SELECT date_trunc($2::text, $3::timestamptz) AS start, $4::timestamptz AS stop
+		),
+		buckets AS (
+			SELECT generate_series(b.start, b.stop, ('1 ' || $2::text)::interval) AS bucket
+			FROM bounds b
+		),
+		prices AS (
+			SELECT date_trunc($2::text, h."timestamp") AS bucket,
+				(array_agg(h.price ORDER BY h."timestamp", h.id))[1] AS first,
+				max(h.price) AS high,
+				min(h.price) AS low,
+				(array_agg(h.price ORDER BY h."timestamp" DESC, h.id DESC))[1] AS close
+			FROM player_price_history h, bounds b
+			WHERE h.player_id = $1 AND h."timestamp" >= b.start AND h."timestamp" <= b.stop
+			GROUP BY 1
+		),
+		volumes AS (
+			SELECT date_trunc($2::text, t."timestamp") AS bucket, sum(t.quantity) AS volume, count(*) AS trades
+			FROM transactions t, bounds b
+			WHERE t.asset_id = $1 AND t.league_id IS NULL AND t."timestamp" >= b.start AND t."timestamp" <= b.stop
+			GROUP BY 1
+		),
+		opening AS (
+			SELECT (
+				SELECT h.price FROM player_price_history h, bounds b
+				WHERE h.player_id = $1 AND h."timestamp" < b.start
+				ORDER BY h."timestamp" DESC, h.id DESC
+				LIMIT 1
+			) AS price
+		),
+		filled AS (
+			SELECT k.bucket, p.first, p.high, p.low, p.close,
+				COALESCE(v.volume, 0) AS volume, COALESCE(v.trades, 0) AS trades,
+				count(p.close) OVER (ORDER BY k.bucket) AS run
+			FROM buckets k
+			LEFT JOIN prices p ON p.bucket = k.bucket
+			LEFT JOIN volumes v ON v.bucket = k.bucket
+		),
+		carried AS (
+			SELECT f.*, COALESCE(first_value(f.close) OVER (PARTITION BY f.run ORDER BY f.bucket), o.price) AS closing
+			FROM filled f, opening o
+		),
+		candles AS (
+			SELECT c.*, COALESCE(lag(c.closing) OVER (ORDER BY c.bucket), o.price, c.first) AS open
+			FROM carried c, opening o
+		)
+		SELECT bucket, open::float8, GREATEST(open, high)::float8, LEAST(open, low)::float8,
+			closing::float8, volume::float8, trades
+		FROM candles
+		WHERE open IS NOT NULL
+		ORDER BY bucket`, playerID, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := make([]models.Candle, 0)
+	for rows.Next() {
+		var c models.Candle
+		if err := rows.Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Trades); err != nil {
+			return nil, err
+		}
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
 func (r *PSQLPlayerPriceRepo) RecordPlayerPrice(ctx context.Context, playerID int64, price float64) error {
 	_, err := r.Pool.Exec(ctx, `
 		INSERT INTO player_price_history (player_id, price, timestamp)
 		VALUES ($1, $2, NOW())`, playerID, price)
 	return err
-}
\ No newline at end of file
+}
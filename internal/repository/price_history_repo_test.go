@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+func TestGetCandlesFillsGaps(t *testing.T) {
+	day0 := time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC)
+	day := func(n int, hour int) time.Time { return day0.AddDate(0, 0, n).Add(time.Duration(hour) * time.Hour) }
+	flat := func(n int, price float64) models.Candle {
+		return models.Candle{Start: day(n, 0), Open: price, High: price, Low: price, Close: price}
+	}
+	type price struct {
+		value float64
+		at    time.Time
+	}
+
+	cases := []struct {
+		name   string
+		prices []price
+		from   time.Time
+		want   []models.Candle
+	}{
+		{
+			name: "opens at the last price before the window",
+			prices: []price{
+				{90, day(-1, 12)},
+				{100, day(0, 1)}, {120, day(0, 2)}, {95, day(0, 3)},
+				{110, day(2, 5)},
+			},
+			from: day(0, 0),
+			want: []models.Candle{
+				{Start: day(0, 0), Open: 90, High: 120, Low: 90, Close: 95},
+				flat(1, 95),
+				{Start: day(2, 0), Open: 95, High: 110, Low: 95, Close: 110, Volume: 3, Trades: 1},
+				flat(3, 110),
+				flat(4, 110),
+			},
+		},
+		{
+			name: "skips buckets before the first price",
+			prices: []price{
+				{100, day(0, 1)}, {120, day(0, 2)}, {95, day(0, 3)},
+				{110, day(2, 5)},
+			},
+			from: day(-2, 0),
+			want: []models.Candle{
+				{Start: day(0, 0), Open: 100, High: 120, Low: 95, Close: 95},
+				flat(1, 95),
+				{Start: day(2, 0), Open: 95, High: 110, Low: 95, Close: 110, Volume: 3, Trades: 1},
+				flat(3, 110),
+				flat(4, 110),
+			},
+		},
+	}
+	for _, tc := range cases {
+		pool := testPool(t)
+		ctx := context.Background()
+		repo := &PSQLPlayerPriceRepo{Pool: pool}
+		user := insertUser(t, pool, "alice", true)
+		player := insertPlayer(t, pool, "jalen_brunson", 110)
+		for _, p := range tc.prices {
+			_, err := pool.Exec(ctx, `INSERT INTO player_price_history (player_id, price, "timestamp") VALUES ($1, $2, $3)`, player, p.value, p.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := pool.Exec(ctx, `
+			INSERT INTO transactions (user_id, asset_id, type, quantity, price, "timestamp")
+			VALUES ($1, $2, 'BUY', 3, 110, $3)`, user, player, day(2, 6))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.GetCandles(ctx, player, models.CandleDay, tc.from, day(4, 12))
+		if err != nil {
+			t.Fatalf("%s: GetCandles: %v", tc.name, err)
+		}
+		if !slices.EqualFunc(got, tc.want, func(a, b models.Candle) bool {
+			return a.Start.Equal(b.Start) && a.Open == b.Open && a.High == b.High && a.Low == b.Low &&
+				a.Close == b.Close && a.Volume == b.Volume && a.Trades == b.Trades
+		}) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.name, got, tc.want)
+		}
+	}
+}
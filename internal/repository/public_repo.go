@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+// PublicRepository serves the unauthenticated pages. Every query here applies
+// the users' privacy settings itself so callers cannot forget to.
+type PublicRepository interface {
+	GetIslandsOfUser(ctx context.Context, userID int64) ([]*models.PublicIsland, error)
+	GetTopHolders(ctx context.Context, playerID int64, limit int) ([]*models.IslandHolder, error)
+	GetEarliestClaimers(ctx context.Context, playerID int64, limit int) ([]*models.IslandClaimer, error)
+	GetClaimBadge(ctx context.Context, claimID int64) (*models.ClaimBadge, error)
+}
+
+type PSQLPublicRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLPublicRepo) GetIslandsOfUser(ctx context.Context, userID int64) ([]*models.PublicIsland, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT p.id, p.name, p.slug, pos.quantity, pos.average_cost, p.value,
+			c.id, c.rank, c.entry_value, c.claimed_at
+		FROM positions_mv pos
+		JOIN players p ON p.id = pos.asset_id
+		LEFT JOIN claims c ON c.user_id = pos.user_id AND c.player_id = pos.asset_id
+		WHERE pos.user_id = $1 AND pos.league_id = 0
+		ORDER BY c.claimed_at ASC NULLS LAST, p.name ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	islands := make([]*models.PublicIsland, 0)
+	for rows.Next() {
+		i := &models.PublicIsland{}
+		err := rows.Scan(
+			&i.PlayerID,
+			&i.PlayerName,
+			&i.Slug,
+			&i.Quantity,
+			&i.AverageCost,
+			&i.CurrentValue,
+			&i.ClaimID,
+			&i.ClaimRank,
+			&i.EntryValue,
+			&i.ClaimedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		islands = append(islands, i)
+	}
+	return islands, rows.Err()
+}
+
+func (r *PSQLPublicRepo) GetTopHolders(ctx context.Context, playerID int64, limit int) ([]*models.IslandHolder, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT u.username, pos.quantity
+		FROM positions_mv pos
+		JOIN users u ON u.id = pos.user_id
+		WHERE pos.asset_id = $1 AND pos.league_id = 0 AND u.listed_on_islands
+		ORDER BY pos.quantity DESC, u.username ASC
+		LIMIT $2`, playerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := make([]*models.IslandHolder, 0)
+	for rows.Next() {
+		h := &models.IslandHolder{}
+		if err := rows.Scan(&h.Username, &h.Quantity); err != nil {
+			return nil, err
+		}
+		holders = append(holders, h)
+	}
+	return holders, rows.Err()
+}
+
+func (r *PSQLPublicRepo) GetEarliestClaimers(ctx context.Context, playerID int64, limit int) ([]*models.IslandClaimer, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT u.username, c.rank, c.entry_value, c.claimed_at
+		FROM claims c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.player_id = $1 AND u.listed_on_islands
+		ORDER BY c.rank ASC
+		LIMIT $2`, playerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimers := make([]*models.IslandClaimer, 0)
+	for rows.Next() {
+		cl := &models.IslandClaimer{}
+		if err := rows.Scan(&cl.Username, &cl.Rank, &cl.EntryValue, &cl.ClaimedAt); err != nil {
+			return nil, err
+		}
+		claimers = append(claimers, cl)
+	}
+	return claimers, rows.Err()
+}
+
+func (r *PSQLPublicRepo) GetClaimBadge(ctx context.Context, claimID int64) (*models.ClaimBadge, error) {
+	var b = &models.ClaimBadge{}
+	err := r.Pool.QueryRow(ctx, `
+		SELECT c.id, u.username, p.name, c.rank, c.entry_value, c.hash
+		FROM claims c
+		JOIN users u ON u.id = c.user_id
+		JOIN players p ON p.id = c.player_id
+		WHERE c.id = $1 AND u.profile_public`, claimID).Scan(
+		&b.ClaimID,
+		&b.Username,
+		&b.PlayerName,
+		&b.Rank,
+		&b.EntryValue,
+		&b.Hash,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
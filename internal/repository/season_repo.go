@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type SeasonRepository interface {
+	GetCurrent(ctx context.Context) (*models.Season, error)
+	GetByID(ctx context.Context, id int64) (*models.Season, error)
+	GetAll(ctx context.Context) ([]*models.Season, error)
+	Create(ctx context.Context, s *models.Season) error
+	Start(ctx context.Context, id int64) error
+	End(ctx context.Context, id int64) error
+	// Rollover ends season currentID if it is still active, then creates
+	// and starts next, all in one transaction.
+	Rollover(ctx context.Context, currentID int64, next *models.Season) error
+	GetStandings(ctx context.Context, seasonID int64) ([]*models.SeasonStanding, error)
+}
+
+type PSQLSeasonRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const seasonColumns = "id, nba_season, status, starting_currency, island_capacity, started_at, ended_at"
+
+func scanSeason(row pgx.Row) (*models.Season, error) {
+	var s models.Season
+	err := row.Scan(
+		&s.ID,
+		&s.NBASeason,
+		&s.Status,
+		&s.StartingCurrency,
+		&s.IslandCapacity,
+		&s.StartedAt,
+		&s.EndedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetCurrent returns the season trades are booked against, matching the
+// current_season_id() SQL function.
+func (r *PSQLSeasonRepo) GetCurrent(ctx context.Context) (*models.Season, error) {
+	return scanSeason(r.Pool.QueryRow(ctx, "SELECT "+seasonColumns+" FROM seasons WHERE id = current_season_id()"))
+}
+
+func (r *PSQLSeasonRepo) GetByID(ctx context.Context, id int64) (*models.Season, error) {
+	return scanSeason(r.Pool.QueryRow(ctx, "SELECT "+seasonColumns+" FROM seasons WHERE id=$1", id))
+}
+
+func (r *PSQLSeasonRepo) GetAll(ctx context.Context) ([]*models.Season, error) {
+	rows, err := r.Pool.Query(ctx, "SELECT "+seasonColumns+" FROM seasons ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []*models.Season
+	for rows.Next() {
+		s, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, s)
+	}
+	return seasons, rows.Err()
+}
+
+func (r *PSQLSeasonRepo) Create(ctx context.Context, s *models.Season) error {
+	return createSeason(ctx, r.Pool, s)
+}
+
+func createSeason(ctx context.Context, db rowQuerier, s *models.Season) error {
+	return db.QueryRow(ctx, `
+		INSERT INTO seasons (nba_season, status, starting_currency, island_capacity)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		s.NBASeason, models.SeasonUpcoming, s.StartingCurrency, s.IslandCapacity,
+	).Scan(&s.ID)
+}
+
+// Start opens an upcoming season: every user is granted the starting
+// currency, every island is reopened at its base capacity and positions reset.
+func (r *PSQLSeasonRepo) Start(ctx context.Context, id int64) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := startSeason(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func startSeason(ctx context.Context, tx pgx.Tx, id int64) error {
+	var currency float64
+	var capacity int
+	err := tx.QueryRow(ctx, `
+		UPDATE seasons SET status = 'active', started_at = now()
+		WHERE id = $1 AND status = 'upcoming'
+		RETURNING starting_currency, island_capacity`, id).Scan(&currency, &capacity)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE users SET currency = $1", currency); err != nil {
+		return err
+	}
+	// Positions do not carry over, so every island reopens at its base
+	// capacity; players without one get the season's.
+	if _, err := tx.Exec(ctx, "UPDATE players SET capacity = COALESCE(base_capacity, $1)", capacity); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE league_members m SET currency = l.starting_currency
+		FROM leagues l
+		WHERE l.id = m.league_id AND l.starting_currency IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "REFRESH MATERIALIZED VIEW positions_mv")
+	return err
+}
+
+// seasonActive reports whether the current season is active, share-locking
+// its row until the transaction ends so it cannot be ended meanwhile.
+func seasonActive(ctx context.Context, tx pgx.Tx) (bool, error) {
+	var active bool
+	err := tx.QueryRow(ctx, `
+		SELECT status = 'active' FROM seasons
+		WHERE id = current_season_id()
+		FOR SHARE`).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
+// End freezes an active season, records final standings and archives every
+// open position at the players' final values. The season's trades stay in
+// transactions tagged with its id.
+func (r *PSQLSeasonRepo) End(ctx context.Context, id int64) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := endSeason(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func endSeason(ctx context.Context, tx pgx.Tx, id int64) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE seasons SET status = 'ended', ended_at = now()
+		WHERE id = $1 AND status = 'active'`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := tx.Exec(ctx, "REFRESH MATERIALIZED VIEW positions_mv"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO season_positions (season_id, user_id, asset_id, league_id, quantity, average_cost, final_value)
+		SELECT $1, pos.user_id, pos.asset_id, pos.league_id, pos.quantity, pos.average_cost, p.value
+		FROM positions_mv pos
+		JOIN players p ON p.id = pos.asset_id`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO season_standings (season_id, user_id, rank, cash, position_value, net_worth)
+		SELECT $1, u.id,
+			RANK() OVER (ORDER BY COALESCE(u.currency, 0) + COALESCE(pv.value, 0) DESC),
+			COALESCE(u.currency, 0),
+			COALESCE(pv.value, 0),
+			COALESCE(u.currency, 0) + COALESCE(pv.value, 0)
+		FROM users u
+		LEFT JOIN (
+			SELECT user_id, SUM(quantity * final_value) AS value
+			FROM season_positions
+			WHERE season_id = $1 AND league_id = 0
+			GROUP BY user_id
+		) pv ON pv.user_id = u.id`, id)
+	return err
+}
+
+// Rollover locks the current season first, so a rollover racing another
+// waits for it and then fails on the duplicate NBA season rather than
+// starting a second one.
+func (r *PSQLSeasonRepo) Rollover(ctx context.Context, currentID int64, next *models.Season) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM seasons WHERE id = $1 FOR UPDATE", currentID).Scan(&status); err != nil {
+		return err
+	}
+	if status == models.SeasonActive {
+		if err := endSeason(ctx, tx, currentID); err != nil {
+			return err
+		}
+	}
+	if err := createSeason(ctx, tx, next); err != nil {
+		return err
+	}
+	if err := startSeason(ctx, tx, next.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PSQLSeasonRepo) GetStandings(ctx context.Context, seasonID int64) ([]*models.SeasonStanding, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT s.season_id, s.user_id, u.username, s.rank, s.cash, s.position_value, s.net_worth
+		FROM season_standings s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.season_id = $1
+		ORDER BY s.rank ASC, u.username ASC`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := make([]*models.SeasonStanding, 0)
+	for rows.Next() {
+		st := &models.SeasonStanding{}
+		err := rows.Scan(
+			&st.SeasonID,
+			&st.UserID,
+			&st.Username,
+			&st.Rank,
+			&st.Cash,
+			&st.PositionValue,
+			&st.NetWorth,
+		)
+		if err != nil {
+			return nil, err
+		}
+		standings = append(standings, st)
+	}
+	return standings, rows.Err()
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+// TestSeasonRolloverReopensBaseCapacity rolls the season over inside a
+// transaction that is rolled back, leaving the migrated season active for
+// the other tests.
+func TestSeasonRolloverReopensBaseCapacity(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	sized := insertPlayer(t, pool, "jalen_brunson", 10)
+	unsized := insertPlayer(t, pool, "josh_hart", 10)
+	if _, err := pool.Exec(ctx, "UPDATE players SET capacity = 4, base_capacity = 7 WHERE id = $1", sized); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "UPDATE players SET capacity = 2, base_capacity = NULL WHERE id = $1", unsized); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var current, next int64
+	if err := tx.QueryRow(ctx, "SELECT current_season_id()").Scan(&current); err != nil {
+		t.Fatal(err)
+	}
+	if open, err := seasonActive(ctx, tx); err != nil || !open {
+		t.Fatalf("seasonActive = %v, %v before the season ends, want true", open, err)
+	}
+	if err := endSeason(ctx, tx, current); err != nil {
+		t.Fatalf("end season: %v", err)
+	}
+	if open, err := seasonActive(ctx, tx); err != nil || open {
+		t.Fatalf("seasonActive = %v, %v after the season ends, want false", open, err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO seasons (nba_season, island_capacity) VALUES ('2099-00', 12)
+		RETURNING id`).Scan(&next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := startSeason(ctx, tx, next); err != nil {
+		t.Fatalf("start season: %v", err)
+	}
+	for id, want := range map[int64]int{sized: 7, unsized: 12} {
+		var capacity int
+		if err := tx.QueryRow(ctx, "SELECT capacity FROM players WHERE id = $1", id).Scan(&capacity); err != nil {
+			t.Fatal(err)
+		}
+		if capacity != want {
+			t.Errorf("player %d reopened at %d, want %d", id, capacity, want)
+		}
+	}
+}
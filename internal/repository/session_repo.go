@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, s *models.AuthSession) error
+	GetByTokenHash(ctx context.Context, hash string) (*models.AuthSession, error)
+	Rotate(ctx context.Context, old *models.AuthSession, next *models.AuthSession) (bool, error)
+	IsFamilyActive(ctx context.Context, familyID string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type PSQLSessionRepo struct {
+	Pool *pgxpool.Pool
+}
+
+func (r *PSQLSessionRepo) Create(ctx context.Context, s *models.AuthSession) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO auth_sessions (family_id, user_id, token_hash, user_agent, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		s.FamilyID, s.UserID, s.TokenHash, s.UserAgent, s.IP, s.ExpiresAt,
+	).Scan(&s.ID, &s.CreatedAt)
+}
+
+func (r *PSQLSessionRepo) GetByTokenHash(ctx context.Context, hash string) (*models.AuthSession, error) {
+	var s models.AuthSession
+	err := r.Pool.QueryRow(ctx, `
+		SELECT id, family_id, user_id, token_hash, user_agent, ip, created_at, expires_at, used_at, revoked_at
+		FROM auth_sessions
+		WHERE token_hash = $1`, hash,
+	).Scan(
+		&s.ID,
+		&s.FamilyID,
+		&s.UserID,
+		&s.TokenHash,
+		&s.UserAgent,
+		&s.IP,
+		&s.CreatedAt,
+		&s.ExpiresAt,
+		&s.UsedAt,
+		&s.RevokedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Rotate marks old used and stores next in one transaction. It reports false
+// when old was already used or revoked, i.e. a concurrent refresh won.
+func (r *PSQLSessionRepo) Rotate(ctx context.Context, old *models.AuthSession, next *models.AuthSession) (bool, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE auth_sessions SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`, old.ID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO auth_sessions (family_id, user_id, token_hash, user_agent, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		next.FamilyID, next.UserID, next.TokenHash, next.UserAgent, next.IP, next.ExpiresAt,
+	).Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// IsFamilyActive reports whether the family still holds a live refresh token,
+// which is what keeps its access tokens valid.
+func (r *PSQLSessionRepo) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	var active bool
+	err := r.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM auth_sessions
+			WHERE family_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		)`, familyID).Scan(&active)
+	return active, err
+}
+
+func (r *PSQLSessionRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE auth_sessions SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL`, familyID)
+	return err
+}
+
+func (r *PSQLSessionRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE auth_sessions SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// DeleteExpired drops families whose every token has expired or been revoked.
+func (r *PSQLSessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		DELETE FROM auth_sessions s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM auth_sessions a
+			WHERE a.family_id = s.family_id AND a.revoked_at IS NULL AND a.expires_at > now()
+		)`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/nbaisland/nbaisland/internal/models"
 )
 
@@ -12,16 +14,27 @@ type TransactionRepository interface {
     GetByID(ctx context.Context, id int64) (*models.Transaction, error)
     GetByUserID(ctx context.Context, id int64) ([]*models.Transaction, error)
     GetByPlayerID(ctx context.Context, id int64) ([]*models.Transaction, error)
-    GetAll(ctx context.Context) ([]*models.Transaction, error)
+	GetByUserIDAndPlayerID(ctx context.Context, userID int64, playerID int64, leagueID int64) ([]*models.Transaction, error)
+	List(ctx context.Context, filter models.TransactionFilter, req models.PageRequest) (*models.Page[*models.Transaction], error)
     CreateTransaction(ctx context.Context, u *models.Transaction) error
+	ExecuteTrade(ctx context.Context, t *models.Transaction, seal func(c *models.Claim)) (*models.Claim, error)
+	GetLastBuyTime(ctx context.Context, userID int64, playerID int64, leagueID int64) (time.Time, error)
+	GetEconomySummary(ctx context.Context) (*models.EconomySummary, error)
     Delete(ctx context.Context, id int64) error
-	GetPositionsByUserIDAndPlayerID(ctx context.Context, user_id int64, player_id int64) (*models.Position, error)
-	GetAllPositions(ctx context.Context) ([]*models.Position, error)
-	GetPositionsByUserID(ctx context.Context, id int64) ([]*models.Position, error)
-	GetPositionsByPlayerID(ctx context.Context, id int64) ([]*models.Position, error)
+	GetPositionsByUserIDAndPlayerID(ctx context.Context, user_id int64, player_id int64, league_id int64) (*models.Position, error)
+	ListPositions(ctx context.Context, filter models.PositionFilter, req models.PageRequest) (*models.Page[*models.Position], error)
+	GetPositionsByUserID(ctx context.Context, id int64, leagueID int64) ([]*models.Position, error)
+	GetPositionsByPlayerID(ctx context.Context, id int64, leagueID int64) ([]*models.Position, error)
 	RefreshPositionsMV(ctx context.Context) error 
 }
 
+var (
+	ErrInsufficientFunds  = errors.New("wallet cannot cover the trade")
+	ErrNoCapacity         = errors.New("player has no capacity left")
+	ErrInsufficientShares = errors.New("user holds fewer shares than the sale")
+	ErrTradingClosed      = errors.New("current season is not active")
+)
+
 type PSQLTransactionRepo struct {
     Pool *pgxpool.Pool
 }
@@ -35,6 +48,9 @@ func scanTransaction(row pgx.Row) (*models.Transaction, error) {
         &t.Type,
         &t.Quantity,
         &t.Price,
+        &t.Fee,
+        &t.SeasonID,
+        &t.LeagueID,
         &t.Timestamp,
     )
     if err != nil {
@@ -54,6 +70,9 @@ func scanTransactionRows(rows pgx.Rows) ([]*models.Transaction, error) {
 			&t.Type,
 			&t.Quantity,
 			&t.Price,
+			&t.Fee,
+			&t.SeasonID,
+			&t.LeagueID,
 			&t.Timestamp,
 		)
 		if err != nil {
@@ -69,7 +88,7 @@ func scanTransactionRows(rows pgx.Rows) ([]*models.Transaction, error) {
 
 
 func (r *PSQLTransactionRepo) GetByID(ctx context.Context, id int64) (*models.Transaction, error) {
-	row := r.Pool.QueryRow(ctx, "SELECT id, user_id, asset_id, type, quantity, price, timestamp from transactions where id=$1", id)
+	row := r.Pool.QueryRow(ctx, "SELECT id, user_id, asset_id, type, quantity, price, fee, season_id, COALESCE(league_id, 0), timestamp from transactions where id=$1", id)
 
 	t, err := scanTransaction(row)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -84,7 +103,7 @@ func (r *PSQLTransactionRepo) GetByID(ctx context.Context, id int64) (*models.Tr
 }
 
 func (r *PSQLTransactionRepo)  GetByUserID(ctx context.Context, id int64) ([]*models.Transaction, error){
-	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, timestamp from transactions WHERE user_id=$1", id)
+	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, fee, season_id, COALESCE(league_id, 0), timestamp from transactions WHERE user_id=$1", id)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -96,7 +115,7 @@ func (r *PSQLTransactionRepo)  GetByUserID(ctx context.Context, id int64) ([]*mo
 
 
 func (r *PSQLTransactionRepo) GetByPlayerID(ctx context.Context, id int64) ([]*models.Transaction, error){
-	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, timestamp from transactions WHERE asset_id=$1", id)
+	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, fee, season_id, COALESCE(league_id, 0), timestamp from transactions WHERE asset_id=$1", id)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -107,34 +126,280 @@ func (r *PSQLTransactionRepo) GetByPlayerID(ctx context.Context, id int64) ([]*m
 }
 
 
-func (r *PSQLTransactionRepo) GetAll(ctx context.Context) ([]*models.Transaction, error){
-	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, timestamp from transactions")
-	defer rows.Close()
+// GetByUserIDAndPlayerID returns the current season's trades in one league
+// (0 for the global islands), oldest first.
+func (r *PSQLTransactionRepo) GetByUserIDAndPlayerID(ctx context.Context, userID int64, playerID int64, leagueID int64) ([]*models.Transaction, error){
+	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, asset_id, type, quantity, price, fee, season_id, COALESCE(league_id, 0), timestamp from transactions WHERE user_id=$1 AND asset_id=$2 AND COALESCE(league_id, 0)=$3 AND season_id=current_season_id() ORDER BY timestamp ASC, id ASC", userID, playerID, leagueID)
 	if err != nil {
 		return nil, err
 	}
-	Transactions, err := scanTransactionRows(rows)
+	defer rows.Close()
 
-	return Transactions, nil
+	return scanTransactionRows(rows)
+}
+
+
+var transactionSorts = map[string]listKey[*models.Transaction]{
+	models.TransactionSortTimestamp: {"timestamp", keyTime, func(t *models.Transaction) interface{} { return t.Timestamp }},
+	models.TransactionSortPrice:     {"price", keyFloat, func(t *models.Transaction) interface{} { return t.Price }},
+	models.TransactionSortQuantity:  {"quantity", keyInt, func(t *models.Transaction) interface{} { return t.Quantity }},
 }
 
+var transactionID = listKey[*models.Transaction]{"id", keyInt, func(t *models.Transaction) interface{} { return t.ID }}
+
+func (r *PSQLTransactionRepo) List(ctx context.Context, filter models.TransactionFilter, req models.PageRequest) (*models.Page[*models.Transaction], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, transactionSorts, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &listQuery{}
+	if filter.Type != "" {
+		q.where("type = " + q.arg(filter.Type))
+	}
+	if filter.UserID != 0 {
+		q.where("user_id = " + q.arg(filter.UserID))
+	}
+	if filter.PlayerID != 0 {
+		q.where("asset_id = " + q.arg(filter.PlayerID))
+	}
+	if filter.LeagueID != nil {
+		q.where("COALESCE(league_id, 0) = " + q.arg(*filter.LeagueID))
+	}
+	if filter.From != nil {
+		q.where("timestamp >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("timestamp < " + q.arg(*filter.To))
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT id, user_id, asset_id, type, quantity, price, fee, season_id, COALESCE(league_id, 0), timestamp FROM transactions", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	transactions, err := scanTransactionRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(transactions, req.Limit, keys), nil
+}
 
 func (r *PSQLTransactionRepo) CreateTransaction(ctx context.Context, t *models.Transaction) error {
-	_, err := r.Pool.Exec(ctx, "INSERT INTO transactions (user_id, asset_id, type, quantity, price, timestamp) VALUES ($1, $2, $3, $4, $5, $6)", t.UserID, t.AssetID, t.Type, t.Quantity, t.Price, t.Timestamp)
+	err := r.Pool.QueryRow(ctx, "INSERT INTO transactions (user_id, asset_id, type, quantity, price, fee, timestamp, league_id) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0)) RETURNING id, season_id", t.UserID, t.AssetID, t.Type, t.Quantity, t.Price, t.Fee, t.Timestamp, t.LeagueID).Scan(&t.ID, &t.SeasonID)
 	return err
 }
 
+// ExecuteTrade books t together with its cash and, for a global trade, the
+// player's capacity, in one database transaction. A BUY is charged
+// Quantity*Price plus Fee and a SELL credited Quantity*Price less Fee. The
+// wallet and capacity are changed relative to their current values, so
+// concurrent trades cannot spend the same balance; ErrInsufficientFunds or
+// ErrNoCapacity means the trade would have taken one below zero.
+//
+// Holdings are summed from transactions rather than read from positions_mv,
+// which may lag: a SELL of more than the wallet holds fails with
+// ErrInsufficientShares, and a league BUY locks the league and fails with
+// ErrNoCapacity if its islands hold too many of the player already.
+//
+// The trade fails with ErrTradingClosed unless the current season is
+// active when it is booked.
+//
+// When seal is set, a global BUY that is the user's first of the player
+// also appends its claim to the chain in the same transaction and returns
+// it; see appendClaim. Otherwise the returned claim is nil.
+func (r *PSQLTransactionRepo) ExecuteTrade(ctx context.Context, t *models.Transaction, seal func(c *models.Claim)) (*models.Claim, error) {
+	gross := float64(t.Quantity) * t.Price
+	cash, capacity := gross-t.Fee, t.Quantity
+	if t.Type == "BUY" {
+		cash, capacity = -(gross + t.Fee), -t.Quantity
+	}
+
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The season row is share-locked first: a trade cannot land in a season
+	// that is ending, and Start/End wait for in-flight trades.
+	open, err := seasonActive(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !open {
+		return nil, ErrTradingClosed
+	}
+
+	// The wallet is updated first, so one user's trades queue on its row.
+	var tag pgconn.CommandTag
+	if t.LeagueID == 0 {
+		tag, err = tx.Exec(ctx, "UPDATE users SET currency = currency + $1 WHERE id = $2 AND currency + $1 >= 0", cash, t.UserID)
+	} else {
+		tag, err = tx.Exec(ctx, `
+			UPDATE league_members SET currency = currency + $1
+			WHERE league_id = $2 AND user_id = $3 AND currency + $1 >= 0`, cash, t.LeagueID, t.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	// The wallet row lock serializes this user's trades, so the shares
+	// summed here cannot be sold twice.
+	if t.Type == "SELL" {
+		var held int
+		err = tx.QueryRow(ctx, `
+			SELECT COALESCE(SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END), 0)
+			FROM transactions
+			WHERE user_id = $1 AND asset_id = $2 AND COALESCE(league_id, 0) = $3 AND season_id = current_season_id()`,
+			t.UserID, t.AssetID, t.LeagueID).Scan(&held)
+		if err != nil {
+			return nil, err
+		}
+		if held < t.Quantity {
+			return nil, ErrInsufficientShares
+		}
+	}
+
+	if t.LeagueID == 0 {
+		tag, err = tx.Exec(ctx, "UPDATE players SET capacity = capacity + $1 WHERE id = $2 AND capacity + $1 >= 0", capacity, t.AssetID)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, ErrNoCapacity
+		}
+	} else if t.Type == "BUY" {
+		var remaining int
+		err = tx.QueryRow(ctx, `
+			WITH league AS (
+				SELECT island_capacity FROM leagues WHERE id = $1 FOR UPDATE
+			)
+			SELECT l.island_capacity - COALESCE((
+				SELECT SUM(CASE WHEN type = 'BUY' THEN quantity ELSE -quantity END)
+				FROM transactions
+				WHERE league_id = $1 AND asset_id = $2 AND season_id = current_season_id()
+			), 0)
+			FROM league l`, t.LeagueID, t.AssetID).Scan(&remaining)
+		if err != nil {
+			return nil, err
+		}
+		if remaining < t.Quantity {
+			return nil, ErrNoCapacity
+		}
+	}
+
+	// A first buy takes the chain lock before its trade gets an ID, so
+	// first buys are numbered in the order their claims are chained.
+	var claim *models.Claim
+	if seal != nil && t.Type == "BUY" && t.LeagueID == 0 {
+		exists, err := hasClaim(ctx, tx, t.UserID, t.AssetID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			if _, err := tx.Exec(ctx, lockClaimsChain); err != nil {
+				return nil, err
+			}
+			// Backfill may have claimed an older buy while we waited.
+			if exists, err = hasClaim(ctx, tx, t.UserID, t.AssetID); err != nil {
+				return nil, err
+			}
+		}
+		if !exists {
+			claim = &models.Claim{UserID: t.UserID, PlayerID: t.AssetID}
+		}
+	}
+
+	// The claim records the trade as stored, so its hash can be recomputed
+	// from the rows.
+	var storedPrice float64
+	var storedAt time.Time
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transactions (user_id, asset_id, type, quantity, price, fee, timestamp, league_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0))
+		RETURNING id, season_id, price::float8, timestamp`,
+		t.UserID, t.AssetID, t.Type, t.Quantity, t.Price, t.Fee, t.Timestamp, t.LeagueID,
+	).Scan(&t.ID, &t.SeasonID, &storedPrice, &storedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if claim != nil {
+		claim.TransactionID, claim.EntryValue, claim.ClaimedAt = t.ID, storedPrice, storedAt
+		if err := appendClaim(ctx, tx, claim, seal); err != nil {
+			return nil, err
+		}
+	}
+
+	return claim, tx.Commit(ctx)
+}
+
+func (r *PSQLTransactionRepo) GetLastBuyTime(ctx context.Context, userID int64, playerID int64, leagueID int64) (time.Time, error) {
+	var ts time.Time
+	err := r.Pool.QueryRow(ctx, `
+		SELECT timestamp FROM transactions
+		WHERE user_id=$1 AND asset_id=$2 AND COALESCE(league_id, 0)=$3 AND type='BUY' AND season_id=current_season_id()
+		ORDER BY timestamp DESC
+		LIMIT 1`, userID, playerID, leagueID).Scan(&ts)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ts, nil
+}
+
+func (r *PSQLTransactionRepo) GetEconomySummary(ctx context.Context) (*models.EconomySummary, error) {
+	var e = &models.EconomySummary{}
+	err := r.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COALESCE(SUM(currency), 0) FROM users),
+			(SELECT COALESCE(SUM(pos.quantity * p.value), 0) FROM positions_mv pos JOIN players p ON p.id = pos.asset_id WHERE pos.league_id = 0),
+			COUNT(*),
+			COALESCE(SUM(quantity * price), 0),
+			COALESCE(SUM(fee) FILTER (WHERE type = 'BUY'), 0),
+			COALESCE(SUM(fee) FILTER (WHERE type = 'SELL'), 0)
+		FROM transactions`).Scan(
+		&e.Users,
+		&e.CurrencyInCirculation,
+		&e.PositionMarketValue,
+		&e.TradeCount,
+		&e.TradeVolume,
+		&e.BuyFeesCollected,
+		&e.SellFeesCollected,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.TotalFeesCollected = e.BuyFeesCollected + e.SellFeesCollected
+
+	return e, nil
+}
+
 func (r *PSQLTransactionRepo) Delete(ctx context.Context, id int64) error {
 	_, err := r.Pool.Exec(ctx, "DELETE FROM transactions WHERE id=$1", id)
 	return err
 }
 
-func (r *PSQLTransactionRepo) GetPositionsByUserIDAndPlayerID(ctx context.Context, user_id int64, player_id int64) (*models.Position, error) {
+func (r *PSQLTransactionRepo) GetPositionsByUserIDAndPlayerID(ctx context.Context, user_id int64, player_id int64, league_id int64) (*models.Position, error) {
 	var p = &models.Position{}
-	err := r.Pool.QueryRow(ctx, "SELECT user_id, asset_id, quantity, average_cost from positions_mv where user_id=$1 AND asset_id=$2", user_id, player_id).Scan(
+	err := r.Pool.QueryRow(ctx, "SELECT user_id, asset_id, league_id, quantity, average_cost from positions_mv where user_id=$1 AND asset_id=$2 AND league_id=$3", user_id, player_id, league_id).Scan(
 		&p.UserID,
 		&p.AssetID,
+		&p.LeagueID,
 		&p.Quantity,
 		&p.AverageCost,
 	)
@@ -150,32 +415,61 @@ func (r *PSQLTransactionRepo) GetPositionsByUserIDAndPlayerID(ctx context.Contex
 	return p, nil
 }
 
-func (r *PSQLTransactionRepo) GetAllPositions(ctx context.Context) ([]*models.Position, error){
-	var positions []*models.Position
-	rows, err := r.Pool.Query(ctx, "SELECT user_id, asset_id, quantity, average_cost from positions_mv")
-	defer rows.Close()
+var positionSorts = map[string]listKey[*models.Position]{
+	models.PositionSortQuantity:    {"quantity", keyInt, func(p *models.Position) interface{} { return p.Quantity }},
+	models.PositionSortAverageCost: {"average_cost", keyFloat, func(p *models.Position) interface{} { return p.AverageCost }},
+}
+
+// positionKey identifies a row of positions_mv.
+var positionKey = []listKey[*models.Position]{
+	{"user_id", keyInt, func(p *models.Position) interface{} { return p.UserID }},
+	{"asset_id", keyInt, func(p *models.Position) interface{} { return p.AssetID }},
+	{"league_id", keyInt, func(p *models.Position) interface{} { return p.LeagueID }},
+}
+
+func (r *PSQLTransactionRepo) ListPositions(ctx context.Context, filter models.PositionFilter, req models.PageRequest) (*models.Page[*models.Position], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, positionSorts, positionKey...)
 	if err != nil {
 		return nil, err
 	}
+
+	q := &listQuery{}
+	if filter.UserID != 0 {
+		q.where("user_id = " + q.arg(filter.UserID))
+	}
+	if filter.PlayerID != 0 {
+		q.where("asset_id = " + q.arg(filter.PlayerID))
+	}
+	if filter.LeagueID != nil {
+		q.where("league_id = " + q.arg(*filter.LeagueID))
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT user_id, asset_id, league_id, quantity, average_cost FROM positions_mv", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []*models.Position
 	for rows.Next() {
 		p := &models.Position{}
-		err := rows.Scan(
-			&p.UserID,
-			&p.AssetID,
-			&p.Quantity,
-			&p.AverageCost,
-		)
-		if err != nil {
+		if err := rows.Scan(&p.UserID, &p.AssetID, &p.LeagueID, &p.Quantity, &p.AverageCost); err != nil {
 			return nil, err
 		}
 		positions = append(positions, p)
 	}
-	return positions, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(positions, req.Limit, keys), nil
 }
 
-func (r *PSQLTransactionRepo) GetPositionsByUserID(ctx context.Context, id int64) ([]*models.Position, error){
+func (r *PSQLTransactionRepo) GetPositionsByUserID(ctx context.Context, id int64, leagueID int64) ([]*models.Position, error){
 	var positions []*models.Position
-	rows, err := r.Pool.Query(ctx, "SELECT user_id, asset_id, quantity, average_cost from positions_mv WHERE user_id=$1", id)
+	rows, err := r.Pool.Query(ctx, "SELECT user_id, asset_id, league_id, quantity, average_cost from positions_mv WHERE user_id=$1 AND league_id=$2", id, leagueID)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -185,6 +479,7 @@ func (r *PSQLTransactionRepo) GetPositionsByUserID(ctx context.Context, id int64
 		err := rows.Scan(
 			&p.UserID,
 			&p.AssetID,
+			&p.LeagueID,
 			&p.Quantity,
 			&p.AverageCost,
 		)
@@ -196,9 +491,9 @@ func (r *PSQLTransactionRepo) GetPositionsByUserID(ctx context.Context, id int64
 	return positions, nil
 }
 
-func (r *PSQLTransactionRepo) GetPositionsByPlayerID(ctx context.Context, id int64) ([]*models.Position, error){
+func (r *PSQLTransactionRepo) GetPositionsByPlayerID(ctx context.Context, id int64, leagueID int64) ([]*models.Position, error){
 	var positions []*models.Position
-	rows, err := r.Pool.Query(ctx, "SELECT user_id, asset_id, quantity, average_cost from positions_mv WHERE asset_id=$1", id)
+	rows, err := r.Pool.Query(ctx, "SELECT user_id, asset_id, league_id, quantity, average_cost from positions_mv WHERE asset_id=$1 AND league_id=$2", id, leagueID)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -208,6 +503,7 @@ func (r *PSQLTransactionRepo) GetPositionsByPlayerID(ctx context.Context, id int
 		err := rows.Scan(
 			&p.UserID,
 			&p.AssetID,
+			&p.LeagueID,
 			&p.Quantity,
 			&p.AverageCost,
 		)
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+func TestExecuteTradeChecksSharesAndLeagueCapacity(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	repo := &PSQLTransactionRepo{Pool: pool}
+	alice := insertUser(t, pool, "alice", true)
+	bob := insertUser(t, pool, "bob", true)
+	player := insertPlayer(t, pool, "jalen_brunson", 10)
+
+	var league int64
+	err := pool.QueryRow(ctx, `
+		INSERT INTO leagues (name, invite_code, owner_id, starting_currency, island_capacity)
+		VALUES ('knicks', 'KNICKS', $1, 1000, 5) RETURNING id`, alice).Scan(&league)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, "INSERT INTO league_members (league_id, user_id, currency) VALUES ($1, $2, 1000), ($1, $3, 1000)", league, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		user     int64
+		kind     string
+		quantity int
+		league   int64
+		want     error
+	}{
+		{"buy globally", alice, "BUY", 3, 0, nil},
+		{"sell more than held", alice, "SELL", 4, 0, ErrInsufficientShares},
+		{"sell all", alice, "SELL", 3, 0, nil},
+		{"sell again", alice, "SELL", 1, 0, ErrInsufficientShares},
+		{"league buy", alice, "BUY", 3, league, nil},
+		{"league buy past capacity", bob, "BUY", 3, league, ErrNoCapacity},
+		{"league buy up to capacity", bob, "BUY", 2, league, nil},
+		{"league shares are not global", bob, "SELL", 1, 0, ErrInsufficientShares},
+		{"league sell frees capacity", alice, "SELL", 1, league, nil},
+		{"league buy into freed capacity", bob, "BUY", 1, league, nil},
+	}
+	for _, step := range steps {
+		_, err := repo.ExecuteTrade(ctx, &models.Transaction{
+			UserID:    step.user,
+			AssetID:   player,
+			Type:      step.kind,
+			Quantity:  step.quantity,
+			Price:     10,
+			LeagueID:  step.league,
+			Timestamp: time.Now(),
+		}, nil)
+		if !errors.Is(err, step.want) {
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.want)
+		}
+	}
+
+	var currency float64
+	if err := pool.QueryRow(ctx, "SELECT currency::float8 FROM users WHERE id = $1", alice).Scan(&currency); err != nil {
+		t.Fatal(err)
+	}
+	if currency != 1000 {
+		t.Errorf("alice's wallet = %v after buying and selling 3 at 10, want 1000", currency)
+	}
+}
@@ -4,20 +4,51 @@ import (
 	"context"
 	"errors"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nbaisland/nbaisland/internal/models"
 )
 
+// Errors Create returns when the users table rejects a row.
+var (
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrEmailTaken      = errors.New("email already registered")
+	ErrInvalidUsername = errors.New("username breaks the users table constraints")
+)
+
+// userConstraintError maps a Postgres unique or check violation on users to
+// one of the typed errors above, or returns err unchanged.
+func userConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case "users_username_key":
+		return ErrUsernameTaken
+	case "users_email_key":
+		return ErrEmailTaken
+	case "username_chars", "username_length":
+		return ErrInvalidUsername
+	}
+	return err
+}
+
 type UserRepository interface {
     GetByID(ctx context.Context, id int64) (*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
     GetAll(ctx context.Context) ([]*models.User, error)
+	List(ctx context.Context, filter models.UserFilter, req models.PageRequest) (*models.Page[*models.User], error)
     Create(ctx context.Context, u *models.User) error
     UpdateName(ctx context.Context, id int64, name string) error
 	UpdateUsername(ctx context.Context, id int64, username string) error
     UpdatePassword(ctx context.Context, id int64, password string) error
     UpdateEmail(ctx context.Context, id int64, email string) error
-    UpdateCurrency(ctx context.Context, id int64, currency float64) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	MarkEmailVerified(ctx context.Context, id int64) error
+	GetPrivacy(ctx context.Context, id int64) (*models.PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, id int64, settings *models.PrivacySettings) error
     Delete(ctx context.Context, id int64) error
 }
 
@@ -27,12 +58,14 @@ type PSQLUserRepo struct {
 
 func (r *PSQLUserRepo) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	var u = &models.User{}
-	err := r.Pool.QueryRow(ctx, "SELECT id, name, username, email, currency from users where id=$1", id).Scan(
+	err := r.Pool.QueryRow(ctx, "SELECT id, name, username, email, currency, role, email_verified_at IS NOT NULL from users where id=$1", id).Scan(
 		&u.ID,
-		&u.Username,
 		&u.Name,
+		&u.Username,
 		&u.Email,
 		&u.Currency,
+		&u.Role,
+		&u.EmailVerified,
 	)
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -48,13 +81,15 @@ func (r *PSQLUserRepo) GetByID(ctx context.Context, id int64) (*models.User, err
 
 func (r *PSQLUserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var u = &models.User{}
-	err := r.Pool.QueryRow(ctx, "SELECT id, name, username, password, email, currency from users where username=$1", username).Scan(
+	err := r.Pool.QueryRow(ctx, "SELECT id, name, username, password, email, currency, role, email_verified_at IS NOT NULL from users where username=$1", username).Scan(
 		&u.ID,
-		&u.Username,
 		&u.Name,
+		&u.Username,
 		&u.Password,
 		&u.Email,
 		&u.Currency,
+		&u.Role,
+		&u.EmailVerified,
 	)
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -68,17 +103,41 @@ func (r *PSQLUserRepo) GetByUsername(ctx context.Context, username string) (*mod
 	return u, nil
 }
 
+// GetByEmail matches case-insensitively and returns the oldest account when
+// several share an address.
+func (r *PSQLUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u = &models.User{}
+	err := r.Pool.QueryRow(ctx, "SELECT id, name, username, email, currency, role, email_verified_at IS NOT NULL from users where lower(email)=lower($1) ORDER BY id LIMIT 1", email).Scan(
+		&u.ID,
+		&u.Name,
+		&u.Username,
+		&u.Email,
+		&u.Currency,
+		&u.Role,
+		&u.EmailVerified,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
 
 func (r *PSQLUserRepo) GetAll(ctx context.Context) ([]*models.User, error){
 	var users []*models.User
 
-	rows, err := r.Pool.Query(ctx, "SELECT id, name, username, email, currency from users")
+	rows, err := r.Pool.Query(ctx, "SELECT id, name, username, email, currency, role, email_verified_at IS NOT NULL from users")
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
 	for rows.Next() {
 		u := &models.User{}
-		err := rows.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Currency)
+		err := rows.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Currency, &u.Role, &u.EmailVerified)
 
 		if err != nil {
 			return nil, err
@@ -88,11 +147,55 @@ func (r *PSQLUserRepo) GetAll(ctx context.Context) ([]*models.User, error){
 	return users, nil
 }
 
+var userSorts = map[string]listKey[*models.User]{
+	models.UserSortID:       {"id", keyInt, func(u *models.User) interface{} { return u.ID }},
+	models.UserSortUsername: {"username", keyText, func(u *models.User) interface{} { return u.Username }},
+	models.UserSortCurrency: {"COALESCE(currency, 0)", keyFloat, func(u *models.User) interface{} { return u.Currency }},
+}
+
+func (r *PSQLUserRepo) List(ctx context.Context, filter models.UserFilter, req models.PageRequest) (*models.Page[*models.User], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, userSorts, userSorts[models.UserSortID])
+	if err != nil {
+		return nil, err
+	}
+
+	q := &listQuery{}
+	if filter.Search != "" {
+		pattern := q.arg(likePattern(filter.Search))
+		q.where("(username ILIKE " + pattern + " OR name ILIKE " + pattern + ")")
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT id, name, username, email, COALESCE(currency, 0), role, email_verified_at IS NOT NULL FROM users", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		u := &models.User{}
+		if err := rows.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Currency, &u.Role, &u.EmailVerified); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(users, req.Limit, keys), nil
+}
+
 func (r *PSQLUserRepo) Create(ctx context.Context, u *models.User) error {
-	err := r.Pool.QueryRow(ctx, "INSERT INTO users (name, username, email, currency, password) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-	u.Username, u.Username, u.Email, u.Currency, u.Password,
-	).Scan(&u.ID)
-	return err
+	if u.Name == "" {
+		u.Name = u.Username
+	}
+	err := r.Pool.QueryRow(ctx, "INSERT INTO users (name, username, email, currency, password) VALUES ($1, $2, $3, $4, $5) RETURNING id, role",
+	u.Name, u.Username, u.Email, u.Currency, u.Password,
+	).Scan(&u.ID, &u.Role)
+	return userConstraintError(err)
 }
 
 func (r *PSQLUserRepo) UpdateName(ctx context.Context, id int64, name string) error {
@@ -102,12 +205,12 @@ func (r *PSQLUserRepo) UpdateName(ctx context.Context, id int64, name string) er
 
 func (r *PSQLUserRepo) UpdateUsername(ctx context.Context, id int64, username string) error {
 	_, err := r.Pool.Exec(ctx, "UPDATE users SET username=$2 where id = $1", id, username)
-	return err
+	return userConstraintError(err)
 }
 
 func (r *PSQLUserRepo) UpdateEmail(ctx context.Context, id int64, email string) error {
-	_, err := r.Pool.Exec(ctx, "UPDATE users SET email=$2 where id = $1", id, email)
-	return err
+	_, err := r.Pool.Exec(ctx, "UPDATE users SET email=$2, email_verified_at=NULL where id = $1", id, email)
+	return userConstraintError(err)
 }
 
 func (r *PSQLUserRepo) UpdatePassword(ctx context.Context, id int64, password string) error {
@@ -115,8 +218,36 @@ func (r *PSQLUserRepo) UpdatePassword(ctx context.Context, id int64, password st
 	return err
 }
 
-func (r *PSQLUserRepo) UpdateCurrency(ctx context.Context, id int64, currency float64) error {
-	_, err := r.Pool.Exec(ctx, "UPDATE users SET currency=$2 where id = $1", id, currency)
+func (r *PSQLUserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	_, err := r.Pool.Exec(ctx, "UPDATE users SET role=$2 where id = $1", id, role)
+	return err
+}
+
+func (r *PSQLUserRepo) MarkEmailVerified(ctx context.Context, id int64) error {
+	_, err := r.Pool.Exec(ctx, "UPDATE users SET email_verified_at=COALESCE(email_verified_at, now()) where id = $1", id)
+	return err
+}
+
+func (r *PSQLUserRepo) GetPrivacy(ctx context.Context, id int64) (*models.PrivacySettings, error) {
+	var p = &models.PrivacySettings{}
+	err := r.Pool.QueryRow(ctx, "SELECT profile_public, listed_on_islands from users where id=$1", id).Scan(
+		&p.ProfilePublic,
+		&p.ListedOnIslands,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *PSQLUserRepo) UpdatePrivacy(ctx context.Context, id int64, settings *models.PrivacySettings) error {
+	_, err := r.Pool.Exec(ctx, "UPDATE users SET profile_public=$2, listed_on_islands=$3 where id = $1", id, settings.ProfilePublic, settings.ListedOnIslands)
 	return err
 }
 
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, w *models.Webhook) error
+	GetByID(ctx context.Context, id int64) (*models.Webhook, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*models.Webhook, error)
+	CountByUserID(ctx context.Context, userID int64) (int, error)
+	Delete(ctx context.Context, id int64, userID int64) (bool, error)
+
+	ListDeliveries(ctx context.Context, webhookID int64, status string, req models.PageRequest) (*models.Page[*models.WebhookDelivery], error)
+	// ClaimDue locks up to limit pending deliveries that are due for lease,
+	// so no other worker picks them up until the lease runs out.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.DueDelivery, error)
+	// RecordAttempt stores the outcome of an attempt and releases the lease.
+	// next is when to try again and is ignored unless status is pending.
+	RecordAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt, status string, next time.Time) error
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+type PSQLWebhookRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const webhookColumns = "id, user_id, url, secret, event_types, min_trade_value::float8, created_at"
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &w.EventTypes, &w.MinTradeValue, &w.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *PSQLWebhookRepo) Create(ctx context.Context, w *models.Webhook) error {
+	return r.Pool.QueryRow(ctx, `
+		INSERT INTO webhooks (user_id, url, secret, event_types, min_trade_value)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		w.UserID, w.URL, w.Secret, w.EventTypes, w.MinTradeValue,
+	).Scan(&w.ID, &w.CreatedAt)
+}
+
+func (r *PSQLWebhookRepo) GetByID(ctx context.Context, id int64) (*models.Webhook, error) {
+	return scanWebhook(r.Pool.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+}
+
+func (r *PSQLWebhookRepo) GetByUserID(ctx context.Context, userID int64) ([]*models.Webhook, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *PSQLWebhookRepo) CountByUserID(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM webhooks WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// Delete reports false when the user has no such webhook. Its deliveries go
+// with it.
+func (r *PSQLWebhookRepo) Delete(ctx context.Context, id int64, userID int64) (bool, error) {
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+var deliverySorts = map[string]listKey[*models.WebhookDelivery]{
+	models.DeliverySortCreatedAt: {"created_at", keyTime, func(d *models.WebhookDelivery) interface{} { return d.CreatedAt }},
+}
+
+var deliveryID = listKey[*models.WebhookDelivery]{"id", keyInt, func(d *models.WebhookDelivery) interface{} { return d.ID }}
+
+const deliveryColumns = "id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, last_status_code, last_error, delivered_at, created_at"
+
+func scanDelivery(row pgx.Row, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var next time.Time
+	dest := []interface{}{
+		&d.ID,
+		&d.WebhookID,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&next,
+		&d.LastAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	// Only a pending delivery has a next attempt worth showing.
+	if d.Status == models.DeliveryPending {
+		d.NextAttemptAt = &next
+	}
+	return &d, nil
+}
+
+func (r *PSQLWebhookRepo) ListDeliveries(ctx context.Context, webhookID int64, status string, req models.PageRequest) (*models.Page[*models.WebhookDelivery], error) {
+	keys, err := newKeyset(req.Sort, req.Desc, deliverySorts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &listQuery{}
+	q.where("webhook_id = " + q.arg(webhookID))
+	if status != "" {
+		q.where("status = " + q.arg(status))
+	}
+	if err := keys.after(q, req.Cursor); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool.Query(ctx, q.sql("SELECT "+deliveryColumns+" FROM webhook_deliveries", keys.orderBy(), req.Limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newPage(deliveries, req.Limit, keys), nil
+}
+
+func (r *PSQLWebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.DueDelivery, error) {
+	rows, err := r.Pool.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending'
+				AND next_attempt_at <= now()
+				AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET locked_until = now() + $2::interval
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.last_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at,
+			w.url, w.secret`, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*models.DueDelivery
+	for rows.Next() {
+		dd := &models.DueDelivery{}
+		dd.WebhookDelivery, err = scanDelivery(rows, &dd.URL, &dd.Secret)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, dd)
+	}
+	return due, rows.Err()
+}
+
+func (r *PSQLWebhookRepo) RecordAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt, status string, next time.Time) error {
+	var code *int
+	if attempt.StatusCode != 0 {
+		code = &attempt.StatusCode
+	}
+	var lastError *string
+	if attempt.Error != "" {
+		lastError = &attempt.Error
+	}
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			last_attempt_at = now(),
+			last_status_code = $3,
+			last_error = $4,
+			next_attempt_at = CASE WHEN $2 = 'pending' THEN $5 ELSE next_attempt_at END,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END,
+			locked_until = NULL
+		WHERE id = $1`, id, status, code, lastError, next)
+	return err
+}
+
+// PruneDeliveries deletes finished deliveries created before before.
+func (r *PSQLWebhookRepo) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
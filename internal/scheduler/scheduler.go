@@ -2,22 +2,39 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/utils"
 )
 
+var ErrJobNotFound = errors.New("no such scheduled job")
+
 type Job struct {
 	Name     string
 	Schedule time.Duration
 	RunAt    time.Time
-	Fn       func(ctx context.Context) error
+	// Interval jobs run every Schedule from startup instead of at RunAt.
+	Interval bool
+	// After names the job this one follows: it runs each time that job's
+	// scheduled run succeeds, and has no schedule of its own.
+	After string
+	Fn    func(ctx context.Context) error
 }
 
 type Scheduler struct {
 	jobs []Job
+	ctx  context.Context
+}
+
+// JobInfo describes a registered job for admin tooling.
+type JobInfo struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Schedule string `json:"schedule"`
 }
 
 func New() *Scheduler {
@@ -44,14 +61,88 @@ func (s *Scheduler) AddNightly(name string, runAtHour, runAtMinute int, fn func(
 	})
 }
 
+// AddInterval runs fn every interval. Its runs are logged at debug level
+// since they are frequent.
+func (s *Scheduler) AddInterval(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, Job{
+		Name:     name,
+		Schedule: interval,
+		Interval: true,
+		Fn:       fn,
+	})
+}
+
+// AddAfter runs fn right after each successful scheduled run of the job
+// named after, for work that needs that job's results.
+func (s *Scheduler) AddAfter(name string, after string, fn func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, Job{
+		Name:  name,
+		After: after,
+		Fn:    fn,
+	})
+}
+
 func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
 	for _, job := range s.jobs {
+		if job.After != "" {
+			continue
+		}
 		go s.runJob(ctx, job)
 	}
 }
 
+func (s *Scheduler) Jobs() []JobInfo {
+	jobs := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		schedule := job.Schedule.String()
+		if job.After != "" {
+			schedule = "after " + job.After
+		}
+		jobs = append(jobs, JobInfo{
+			Name:     job.Name,
+			Slug:     utils.ToSlug(job.Name),
+			Schedule: schedule,
+		})
+	}
+	return jobs
+}
+
+// Trigger runs the job with the given slug now, in the background, outside
+// its schedule.
+func (s *Scheduler) Trigger(slug string) error {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, job := range s.jobs {
+		if utils.ToSlug(job.Name) != slug {
+			continue
+		}
+		go func() {
+			logger.Log.Info("scheduled job triggered manually", zap.String("job", job.Name))
+			if err := job.Fn(ctx); err != nil {
+				logger.Log.Error(
+					"scheduled job failed",
+					zap.String("job", job.Name),
+					zap.Error(err),
+				)
+				return
+			}
+			logger.Log.Info("scheduled job completed", zap.String("job", job.Name))
+		}()
+		return nil
+	}
+	return ErrJobNotFound
+}
+
 func (s *Scheduler) runJob(ctx context.Context, job Job) {
 	nextRun := s.calculateNextRun(job.RunAt, job.Schedule)
+	logRun := logger.Log.Info
+	if job.Interval {
+		nextRun = time.Now().Add(job.Schedule)
+		logRun = logger.Log.Debug
+	}
 
 	logger.Log.Info(
 		"scheduled job initialized",
@@ -69,7 +160,7 @@ func (s *Scheduler) runJob(ctx context.Context, job Job) {
 			return
 
 		case <-time.After(time.Until(nextRun)):
-			logger.Log.Info(
+			logRun(
 				"scheduled job starting",
 				zap.String("job", job.Name),
 				zap.Time("run_at", nextRun),
@@ -81,11 +172,16 @@ func (s *Scheduler) runJob(ctx context.Context, job Job) {
 					zap.String("job", job.Name),
 					zap.Error(err),
 				)
+			} else {
+				s.runFollowers(ctx, job.Name)
 			}
 
 			nextRun = nextRun.Add(job.Schedule)
+			if job.Interval {
+				nextRun = time.Now().Add(job.Schedule)
+			}
 
-			logger.Log.Info(
+			logRun(
 				"scheduled job completed",
 				zap.String("job", job.Name),
 				zap.Time("next_run_at", nextRun),
@@ -94,6 +190,27 @@ func (s *Scheduler) runJob(ctx context.Context, job Job) {
 	}
 }
 
+// runFollowers runs the jobs added with AddAfter name, in the order they
+// were added, and in turn those that follow them.
+func (s *Scheduler) runFollowers(ctx context.Context, name string) {
+	for _, job := range s.jobs {
+		if job.After != name {
+			continue
+		}
+		logger.Log.Info("scheduled job starting", zap.String("job", job.Name), zap.String("after", name))
+		if err := job.Fn(ctx); err != nil {
+			logger.Log.Error(
+				"scheduled job failed",
+				zap.String("job", job.Name),
+				zap.Error(err),
+			)
+			continue
+		}
+		logger.Log.Info("scheduled job completed", zap.String("job", job.Name))
+		s.runFollowers(ctx, job.Name)
+	}
+}
+
 func (s *Scheduler) calculateNextRun(runAt time.Time, schedule time.Duration) time.Time {
 	now := time.Now()
 
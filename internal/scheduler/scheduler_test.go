@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+)
+
+func TestFollowersRunAfterSuccessInOrder(t *testing.T) {
+	logger.Log = zap.NewNop()
+	var ran []string
+	job := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+
+	s := New()
+	s.AddNightly("Update", 2, 40, job("Update", nil))
+	s.AddAfter("Snapshot", "Update", job("Snapshot", nil))
+	s.AddAfter("Leaderboard", "Update", job("Leaderboard", errors.New("boom")))
+	s.AddAfter("After Snapshot", "Snapshot", job("After Snapshot", nil))
+	s.AddAfter("After Leaderboard", "Leaderboard", job("After Leaderboard", nil))
+
+	s.runFollowers(context.Background(), "Update")
+
+	want := []string{"Snapshot", "After Snapshot", "Leaderboard"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestFollowersListedWithTheirPredecessor(t *testing.T) {
+	s := New()
+	s.AddNightly("Update", 2, 40, nil)
+	s.AddAfter("Snapshot", "Update", nil)
+
+	jobs := s.Jobs()
+	if len(jobs) != 2 || jobs[1].Schedule != "after Update" || jobs[1].Slug != "snapshot" {
+		t.Errorf("jobs = %+v", jobs)
+	}
+}
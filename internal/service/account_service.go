@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/mail"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrInvalidAccountToken  = apperror.Invalid("INVALID_ACCOUNT_TOKEN", "invalid or expired token")
+	ErrEmailAlreadyVerified = apperror.Conflict("EMAIL_ALREADY_VERIFIED", "email is already verified")
+)
+
+// errResetCoolingDown skips a reset email because the account was sent one
+// within ResetCooldown.
+var errResetCoolingDown = errors.New("password reset sent recently")
+
+// AccountService mails single-use links for verifying an email address and
+// resetting a forgotten password. Links point at BaseURL, the web app, which
+// posts the token back to the API.
+type AccountService struct {
+	Users     repository.UserRepository
+	Tokens    repository.AccountTokenRepository
+	Mailer    mail.Mailer
+	Sessions  *SessionService
+	BaseURL   string
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
+	// ResetCooldown is how long after one reset email an account gets no
+	// other, so repeated requests cannot flood its inbox.
+	ResetCooldown time.Duration
+
+	// resetSent, if set, is called with the outcome of each background
+	// reset email; tests use it to wait for one.
+	resetSent func(error)
+}
+
+func NewAccountService(users repository.UserRepository, tokens repository.AccountTokenRepository, mailer mail.Mailer, sessions *SessionService, baseURL string) *AccountService {
+	return &AccountService{
+		Users:     users,
+		Tokens:    tokens,
+		Mailer:    mailer,
+		Sessions:  sessions,
+		BaseURL:   baseURL,
+		VerifyTTL: 48 * time.Hour,
+		ResetTTL:  time.Hour,
+
+		ResetCooldown: 5 * time.Minute,
+	}
+}
+
+// issue stores a new token for user, spending any earlier one of the same
+// purpose so only the latest link works.
+func (s *AccountService) issue(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	if err := s.Tokens.InvalidateForUser(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.Tokens.Create(ctx, &models.AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, err
+}
+
+func (s *AccountService) link(path string, token string) string {
+	return s.BaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// SendVerification mails user a link confirming their email address.
+func (s *AccountService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	token, err := s.issue(ctx, user.ID, models.TokenVerifyEmail, s.VerifyTTL)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your NBA Island email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, s.link("/verify-email", token), s.VerifyTTL),
+	})
+}
+
+// ResendVerification mails a fresh verification link to the user.
+func (s *AccountService) ResendVerification(ctx context.Context, userID int64) error {
+	user, err := s.Users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.SendVerification(ctx, user)
+}
+
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	t, err := s.Tokens.Consume(ctx, models.TokenVerifyEmail, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrInvalidAccountToken
+	}
+	return s.Users.MarkEmailVerified(ctx, t.UserID)
+}
+
+// ForgotPassword mails a reset link when email belongs to an account and it
+// was not sent one within ResetCooldown. It returns nil either way so
+// callers cannot probe which addresses exist, and the link is issued and
+// mailed in the background so the response takes as long for an unknown
+// address as for a known one.
+func (s *AccountService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		logger.Log.Info("Password reset requested for unknown email")
+		return nil
+	}
+	go s.sendReset(context.WithoutCancel(ctx), user)
+	return nil
+}
+
+func (s *AccountService) sendReset(ctx context.Context, user *models.User) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	err := s.mailReset(ctx, user)
+	if errors.Is(err, errResetCoolingDown) {
+		logger.Log.Info("Skipped password reset during cooldown", zap.Int64("user_id", user.ID))
+	} else if err != nil {
+		logger.Log.Error("Failed to send password reset",
+			zap.Int64("user_id", user.ID),
+			zap.Error(err),
+		)
+	}
+	if s.resetSent != nil {
+		s.resetSent(err)
+	}
+}
+
+func (s *AccountService) mailReset(ctx context.Context, user *models.User) error {
+	recent, err := s.Tokens.IssuedSince(ctx, user.ID, models.TokenResetPassword, time.Now().Add(-s.ResetCooldown))
+	if err != nil {
+		return err
+	}
+	if recent {
+		return errResetCoolingDown
+	}
+	token, err := s.issue(ctx, user.ID, models.TokenResetPassword, s.ResetTTL)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your NBA Island password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset your password. If it was you, open this link:\n\n%s\n\nThe link expires in %s. If you did not ask, ignore this email.\n",
+			user.Username, s.link("/reset-password", token), s.ResetTTL),
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and logs
+// the user out everywhere. Receiving the link also proves the email address.
+func (s *AccountService) ResetPassword(ctx context.Context, token string, password string) error {
+	v := &ValidationError{}
+	validatePassword(v, password, "")
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	t, err := s.Tokens.Consume(ctx, models.TokenResetPassword, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrInvalidAccountToken
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.Users.UpdatePassword(ctx, t.UserID, hashed); err != nil {
+		return err
+	}
+	if err := s.Users.MarkEmailVerified(ctx, t.UserID); err != nil {
+		return err
+	}
+	if err := s.Sessions.LogoutAll(ctx, t.UserID); err != nil {
+		return err
+	}
+	logger.Log.Info("Password reset", zap.Int64("user_id", t.UserID))
+	return nil
+}
+
+func (s *AccountService) Cleanup(ctx context.Context) error {
+	deleted, err := s.Tokens.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Deleted spent account tokens", zap.Int64("count", deleted))
+	return nil
+}
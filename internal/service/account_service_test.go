@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/mail"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[int64]*models.User
+	// createErr is returned by Create, as when a constraint rejects the row.
+	createErr error
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	return r.users[id], nil
+}
+
+func (r *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, u *models.User) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	u.ID = int64(len(r.users) + 1)
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	r.users[id].Role = role
+	return nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeAccountTokenRepo struct {
+	repository.AccountTokenRepository
+	created chan *models.AccountToken
+	// recent makes IssuedSince report a token inside the cooldown.
+	recent bool
+}
+
+func (r *fakeAccountTokenRepo) IssuedSince(ctx context.Context, userID int64, purpose string, since time.Time) (bool, error) {
+	return r.recent, nil
+}
+
+func (r *fakeAccountTokenRepo) InvalidateForUser(ctx context.Context, userID int64, purpose string) error {
+	return nil
+}
+
+func (r *fakeAccountTokenRepo) Consume(ctx context.Context, purpose string, hash string) (*models.AccountToken, error) {
+	return nil, nil
+}
+
+func (r *fakeAccountTokenRepo) Create(ctx context.Context, t *models.AccountToken) error {
+	r.created <- t
+	return nil
+}
+
+// blockingMailer holds every send until release is closed.
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mail.Message
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mail.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+func TestForgotPasswordDoesNotWaitForMail(t *testing.T) {
+	users := &fakeUserRepo{users: map[int64]*models.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com"},
+	}}
+	tokens := &fakeAccountTokenRepo{created: make(chan *models.AccountToken, 1)}
+	mailer := &blockingMailer{release: make(chan struct{}), sent: make(chan mail.Message, 1)}
+	s := NewAccountService(users, tokens, mailer, nil, "https://app.test")
+	done := make(chan error, 1)
+	s.resetSent = func(err error) { done <- err }
+
+	// Returning while the mailer is still blocked shows a known address
+	// answers no slower than an unknown one.
+	for _, email := range []string{"nobody@example.com", "Alice@example.com"} {
+		if err := s.ForgotPassword(context.Background(), email); err != nil {
+			t.Fatalf("ForgotPassword(%s): %v", email, err)
+		}
+	}
+	close(mailer.release)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("reset email failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset email was never sent")
+	}
+	token := <-tokens.created
+	msg := <-mailer.sent
+	if token.UserID != 1 || token.Purpose != models.TokenResetPassword {
+		t.Errorf("issued %+v, want a reset token for user 1", token)
+	}
+	if msg.To != "alice@example.com" || !strings.Contains(msg.Body, "https://app.test/reset-password?token=") {
+		t.Errorf("sent %+v, want a reset link to alice", msg)
+	}
+}
+
+func TestForgotPasswordCoolsDownPerAccount(t *testing.T) {
+	users := &fakeUserRepo{users: map[int64]*models.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com"},
+	}}
+	tokens := &fakeAccountTokenRepo{created: make(chan *models.AccountToken, 1), recent: true}
+	mailer := &blockingMailer{release: make(chan struct{}), sent: make(chan mail.Message, 1)}
+	close(mailer.release)
+	s := NewAccountService(users, tokens, mailer, nil, "https://app.test")
+	done := make(chan error, 1)
+	s.resetSent = func(err error) { done <- err }
+
+	if err := s.ForgotPassword(context.Background(), "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, errResetCoolingDown) {
+			t.Fatalf("reset inside the cooldown finished with %v, want it skipped", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset never finished")
+	}
+	if len(tokens.created) != 0 || len(mailer.sent) != 0 {
+		t.Error("reset inside the cooldown issued a token or sent mail")
+	}
+}
+
+// The code must not be the session middleware's INVALID_TOKEN, which clients
+// take to mean they are signed out.
+func TestUnknownAccountTokenHasItsOwnCode(t *testing.T) {
+	s := NewAccountService(&fakeUserRepo{}, &fakeAccountTokenRepo{}, nil, nil, "https://app.test")
+	err := s.VerifyEmail(context.Background(), "nope")
+
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != "INVALID_ACCOUNT_TOKEN" {
+		t.Errorf("err = %v, want INVALID_ACCOUNT_TOKEN", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrAlertNotFound = apperror.NotFound("ALERT_NOT_FOUND", "alert not found")
+	ErrNotWatching   = apperror.NotFound("NOT_WATCHING", "player is not on your watchlist")
+	ErrTooManyAlerts = apperror.Conflict("TOO_MANY_ALERTS", "too many alerts; delete one first")
+	ErrWatchlistFull = apperror.Conflict("WATCHLIST_FULL", "watchlist is full; remove a player first")
+)
+
+// maxAlertWindowHours bounds change alerts to a month of price history.
+const maxAlertWindowHours = 24 * 30
+
+// alertBatchDelay is how long Run gathers price changes before evaluating
+// them, so a nightly update of every player is one evaluation rather than
+// hundreds.
+const alertBatchDelay = time.Second
+
+// AlertService manages watchlists and price alerts, and turns alerts that
+// fire into notifications.
+type AlertService struct {
+	Repo          repository.AlertRepository
+	Players       repository.PlayerRepository
+	Notifications *NotificationService
+
+	MaxAlertsPerUser int
+	MaxWatchlist     int
+
+	mu      sync.Mutex
+	changed map[int64]struct{}
+	wake    chan struct{}
+}
+
+func NewAlertService(repo repository.AlertRepository, players repository.PlayerRepository, notifications *NotificationService) *AlertService {
+	return &AlertService{
+		Repo:             repo,
+		Players:          players,
+		Notifications:    notifications,
+		MaxAlertsPerUser: 50,
+		MaxWatchlist:     100,
+		changed:          make(map[int64]struct{}),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+func (s *AlertService) Watchlist(ctx context.Context, userID int64) ([]*models.WatchlistItem, error) {
+	return s.Repo.GetWatchlist(ctx, userID)
+}
+
+func (s *AlertService) Watch(ctx context.Context, userID int64, playerID int64) error {
+	if err := s.checkPlayer(ctx, playerID); err != nil {
+		return err
+	}
+	count, err := s.Repo.CountWatchlist(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count >= s.MaxWatchlist {
+		return ErrWatchlistFull
+	}
+	return s.Repo.AddToWatchlist(ctx, userID, playerID)
+}
+
+func (s *AlertService) Unwatch(ctx context.Context, userID int64, playerID int64) error {
+	removed, err := s.Repo.RemoveFromWatchlist(ctx, userID, playerID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotWatching
+	}
+	return nil
+}
+
+func (s *AlertService) checkPlayer(ctx context.Context, playerID int64) error {
+	player, err := s.Players.GetByID(ctx, playerID)
+	if err != nil {
+		return err
+	}
+	if player == nil {
+		return apperror.NotFound("PLAYER_NOT_FOUND", "Could not find player")
+	}
+	return nil
+}
+
+// CreateAlertRequest describes a price alert. Threshold is a value for above
+// and below, and a percentage for change, which also needs WindowHours.
+type CreateAlertRequest struct {
+	PlayerID    int64   `json:"player_id" binding:"required"`
+	Kind        string  `json:"kind" binding:"required"`
+	Threshold   float64 `json:"threshold" binding:"required"`
+	WindowHours *int    `json:"window_hours"`
+	Email       bool    `json:"email"`
+}
+
+// CreateAlert stores the alert and checks it straight away, so a condition
+// that already holds notifies now rather than at the next price update.
+func (s *AlertService) CreateAlert(ctx context.Context, userID int64, req CreateAlertRequest) (*models.PriceAlert, error) {
+	v := &ValidationError{}
+	switch req.Kind {
+	case models.AlertAbove, models.AlertBelow:
+		if req.Threshold <= 0 {
+			v.add("threshold", "must be positive")
+		}
+		if req.WindowHours != nil {
+			v.add("window_hours", "only applies to change alerts")
+		}
+	case models.AlertChange:
+		if req.Threshold <= 0 || req.Threshold > 1000 {
+			v.add("threshold", "must be a percentage between 0 and 1000")
+		}
+		if req.WindowHours == nil || *req.WindowHours < 1 || *req.WindowHours > maxAlertWindowHours {
+			v.add("window_hours", fmt.Sprintf("must be between 1 and %d", maxAlertWindowHours))
+		}
+	default:
+		v.add("kind", "must be above, below or change")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPlayer(ctx, req.PlayerID); err != nil {
+		return nil, err
+	}
+	count, err := s.Repo.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= s.MaxAlertsPerUser {
+		return nil, ErrTooManyAlerts
+	}
+
+	alert := &models.PriceAlert{
+		UserID:      userID,
+		PlayerID:    req.PlayerID,
+		Kind:        req.Kind,
+		Threshold:   req.Threshold,
+		WindowHours: req.WindowHours,
+		Email:       req.Email,
+	}
+	if err := s.Repo.Create(ctx, alert); err != nil {
+		return nil, err
+	}
+	if err := s.Evaluate(ctx, []int64{alert.PlayerID}); err != nil {
+		logger.Log.Warn("Failed to check new price alert", zap.Int64("alert_id", alert.ID), zap.Error(err))
+	}
+	return alert, nil
+}
+
+func (s *AlertService) GetAlerts(ctx context.Context, userID int64) ([]*models.PriceAlert, error) {
+	return s.Repo.GetByUserID(ctx, userID)
+}
+
+func (s *AlertService) DeleteAlert(ctx context.Context, userID int64, id int64) error {
+	deleted, err := s.Repo.Delete(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAlertNotFound
+	}
+	return nil
+}
+
+// PriceChanged queues the event's player for Run to evaluate. It watches
+// price.changed on the stream service, which hears every write to a
+// player's value, whatever made it.
+func (s *AlertService) PriceChanged(e *models.MarketEvent) {
+	s.mu.Lock()
+	s.changed[e.PlayerID] = struct{}{}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run evaluates the alerts on players queued by PriceChanged until ctx
+// ends. Every replica hears every change and runs its own evaluation; that
+// is safe because an alert fires once per crossing however many check it.
+func (s *AlertService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(alertBatchDelay):
+		}
+
+		s.mu.Lock()
+		playerIDs := slices.Collect(maps.Keys(s.changed))
+		clear(s.changed)
+		s.mu.Unlock()
+
+		if err := s.Evaluate(ctx, playerIDs); err != nil {
+			logger.Log.Error("Failed to evaluate price alerts",
+				zap.Int("players", len(playerIDs)),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Evaluate checks the alerts on playerIDs against their current values and
+// notifies the users whose alerts fire. The repository stores the
+// notifications along with disarming the alerts; only the emails are sent
+// here.
+func (s *AlertService) Evaluate(ctx context.Context, playerIDs []int64) error {
+	if len(playerIDs) == 0 {
+		return nil
+	}
+	fired, err := s.Repo.Evaluate(ctx, playerIDs)
+	if err != nil {
+		return err
+	}
+	for _, f := range fired {
+		s.Notifications.Email(ctx, f.Notification, f.EmailTo)
+	}
+	if len(fired) > 0 {
+		logger.Log.Info("Price alerts fired", zap.Int("count", len(fired)))
+	}
+	return nil
+}
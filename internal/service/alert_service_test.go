@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/mail"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type fakeAlertRepo struct {
+	repository.AlertRepository
+	fired     []*models.FiredAlert
+	evaluated chan []int64
+}
+
+// Evaluate stores each fired alert's notification, as the real repository
+// does in the transaction that disarms it.
+func (r *fakeAlertRepo) Evaluate(ctx context.Context, playerIDs []int64) ([]*models.FiredAlert, error) {
+	if r.evaluated != nil {
+		r.evaluated <- playerIDs
+	}
+	for i, f := range r.fired {
+		f.Notification = f.NewNotification()
+		f.Notification.ID = int64(i + 1)
+	}
+	return r.fired, nil
+}
+
+type fakeMailer struct {
+	sent []mail.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestFiredAlertsEmailWhenAsked(t *testing.T) {
+	window := 24
+	ref := 50.0
+	alerts := &fakeAlertRepo{fired: []*models.FiredAlert{
+		{
+			PriceAlert: &models.PriceAlert{ID: 1, UserID: 10, PlayerID: 3, Kind: models.AlertAbove, Threshold: 60},
+			PlayerName: "Jalen Brunson",
+			Value:      61.5,
+		},
+		{
+			PriceAlert:     &models.PriceAlert{ID: 2, UserID: 11, PlayerID: 3, Kind: models.AlertChange, Threshold: 20, WindowHours: &window, Email: true},
+			PlayerName:     "Jalen Brunson",
+			Value:          61.5,
+			ReferenceValue: &ref,
+			EmailTo:        "fan@example.com",
+		},
+	}}
+	mailer := &fakeMailer{}
+	s := NewAlertService(alerts, nil, NewNotificationService(nil, mailer, "https://nbaisland.test"))
+
+	if err := s.Evaluate(context.Background(), []int64{3}); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+
+	for i, f := range alerts.fired {
+		n := f.Notification
+		if n.Type != models.NotificationPriceAlert || n.UserID != f.UserID || n.PlayerID == nil || *n.PlayerID != 3 {
+			t.Errorf("notification %d = %+v", i, n)
+		}
+	}
+	if body := alerts.fired[1].Notification.Body; !strings.Contains(body, "50.00 to 61.50") || !strings.Contains(body, "24 hours") {
+		t.Errorf("change alert body = %q", body)
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "fan@example.com" {
+		t.Fatalf("sent %+v, want one email to fan@example.com", mailer.sent)
+	}
+	if !strings.Contains(mailer.sent[0].Body, "https://nbaisland.test/notifications") {
+		t.Errorf("email body = %q", mailer.sent[0].Body)
+	}
+}
+
+func TestPriceChangesEvaluatedTogether(t *testing.T) {
+	alerts := &fakeAlertRepo{evaluated: make(chan []int64, 1)}
+	s := NewAlertService(alerts, nil, nil)
+	streams := NewStreamService(nil, nil)
+	streams.Watch(models.EventPriceChanged, s.PriceChanged)
+
+	for _, e := range []*models.MarketEvent{
+		{Type: models.EventPriceChanged, PlayerID: 4},
+		{Type: models.EventTradeExecuted, PlayerID: 5},
+		{Type: models.EventPriceChanged, PlayerID: 3},
+		{Type: models.EventPriceChanged, PlayerID: 4},
+	} {
+		streams.deliver(e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case ids := <-alerts.evaluated:
+		slices.Sort(ids)
+		if !slices.Equal(ids, []int64{3, 4}) {
+			t.Errorf("evaluated %v, want [3 4]", ids)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("price changes were never evaluated")
+	}
+}
+
+func TestCreateAlertValidation(t *testing.T) {
+	s := NewAlertService(&fakeAlertRepo{}, nil, nil)
+	window := 24
+	tooLong := maxAlertWindowHours + 1
+	cases := []struct {
+		name  string
+		req   CreateAlertRequest
+		field string
+	}{
+		{"unknown kind", CreateAlertRequest{PlayerID: 1, Kind: "sideways", Threshold: 10}, "kind"},
+		{"negative price", CreateAlertRequest{PlayerID: 1, Kind: models.AlertAbove, Threshold: -5}, "threshold"},
+		{"window on price alert", CreateAlertRequest{PlayerID: 1, Kind: models.AlertBelow, Threshold: 5, WindowHours: &window}, "window_hours"},
+		{"change without window", CreateAlertRequest{PlayerID: 1, Kind: models.AlertChange, Threshold: 10}, "window_hours"},
+		{"window past history", CreateAlertRequest{PlayerID: 1, Kind: models.AlertChange, Threshold: 10, WindowHours: &tooLong}, "window_hours"},
+		{"change over 1000%", CreateAlertRequest{PlayerID: 1, Kind: models.AlertChange, Threshold: 1001, WindowHours: &window}, "threshold"},
+	}
+	for _, tc := range cases {
+		_, err := s.CreateAlert(context.Background(), 1, tc.req)
+		if fieldError(err, tc.field) == "" {
+			t.Errorf("%s: err = %v, want %s flagged", tc.name, err, tc.field)
+		}
+	}
+}
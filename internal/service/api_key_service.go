@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrAPIKeyNotFound = apperror.NotFound("API_KEY_NOT_FOUND", "API key not found")
+	ErrTooManyAPIKeys = apperror.Conflict("TOO_MANY_API_KEYS", "too many active API keys; revoke one first")
+)
+
+// apiKeyPrefix marks our keys so they are recognisable in leaked-secret scans
+// and can be rejected without a database lookup.
+const (
+	apiKeyPrefix       = "nbi_"
+	apiKeyDisplayChars = 12
+)
+
+// APIKeyService manages the keys users create for their scripts. A key
+// authenticates as its owner, limited to its scopes and to
+// RateLimitPerMinute requests per minute.
+type APIKeyService struct {
+	Repo             repository.APIKeyRepository
+	DefaultRateLimit int
+	MaxRateLimit     int
+	MaxKeysPerUser   int
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{Repo: repo, DefaultRateLimit: 60, MaxRateLimit: 600, MaxKeysPerUser: 10}
+}
+
+type CreateAPIKeyRequest struct {
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+	ExpiresInDays      int      `json:"expires_in_days"`
+}
+
+// Create stores a new key and returns it with the plaintext key, which is
+// shown to the user once and never again.
+func (s *APIKeyService) Create(ctx context.Context, userID int64, req CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.RateLimitPerMinute == 0 {
+		req.RateLimitPerMinute = s.DefaultRateLimit
+	}
+
+	v := &ValidationError{}
+	if req.Name == "" || len([]rune(req.Name)) > 50 {
+		v.add("name", "must be 1-50 characters")
+	}
+	if len(req.Scopes) == 0 {
+		v.add("scopes", "at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if scope != models.ScopeRead && scope != models.ScopeTrade {
+			v.add("scopes", "must be read or trade")
+		}
+	}
+	if req.RateLimitPerMinute < 1 || req.RateLimitPerMinute > s.MaxRateLimit {
+		v.add("rate_limit_per_minute", "must be between 1 and 600")
+	}
+	if req.ExpiresInDays < 0 {
+		v.add("expires_in_days", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		return nil, "", err
+	}
+
+	active, err := s.Repo.CountActive(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if active >= s.MaxKeysPerUser {
+		return nil, "", ErrTooManyAPIKeys
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := apiKeyPrefix + secret
+
+	// trade implies read.
+	scopes := slices.Clone(req.Scopes)
+	if slices.Contains(scopes, models.ScopeTrade) && !slices.Contains(scopes, models.ScopeRead) {
+		scopes = append(scopes, models.ScopeRead)
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	key := &models.APIKey{
+		UserID:             userID,
+		Name:               req.Name,
+		Prefix:             plaintext[:apiKeyDisplayChars],
+		KeyHash:            hashToken(plaintext),
+		Scopes:             scopes,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expires
+	}
+	if err := s.Repo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) GetByUserID(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	return s.Repo.GetByUserID(ctx, userID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID int64, id int64) error {
+	revoked, err := s.Repo.Revoke(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a key to claims for its owner and counts the
+// request against the key's rate limit. API key claims never carry the
+// admin role.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Claims, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	use, err := s.Repo.Use(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	if use == nil {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if use.WindowCount > use.Key.RateLimitPerMinute {
+		return nil, &auth.RateLimitError{
+			Limit:      use.Key.RateLimitPerMinute,
+			RetryAfter: time.Until(use.WindowStart.Add(time.Minute)),
+		}
+	}
+	return &auth.Claims{
+		UserID:   use.Key.UserID,
+		Username: use.Username,
+		Role:     models.RoleUser,
+		APIKeyID: use.Key.ID,
+		Scopes:   use.Key.Scopes,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// GenesisHash is the PrevHash of the first claim in the chain.
+var GenesisHash = strings.Repeat("0", 64)
+
+type ClaimService struct {
+	Repo repository.ClaimRepository
+}
+
+func NewClaimService(repo repository.ClaimRepository) *ClaimService {
+	return &ClaimService{Repo: repo}
+}
+
+// ClaimHash is the receipt for a claim. Anyone holding the claim fields can
+// recompute it, and because PrevHash is included no earlier claim can be
+// altered without breaking every receipt after it.
+func ClaimHash(c *models.Claim) string {
+	payload := strings.Join([]string{
+		c.PrevHash,
+		strconv.FormatInt(c.UserID, 10),
+		strconv.FormatInt(c.PlayerID, 10),
+		strconv.FormatInt(c.TransactionID, 10),
+		strconv.Itoa(c.Rank),
+		strconv.FormatFloat(c.EntryValue, 'f', 6, 64),
+		c.ClaimedAt.UTC().Format(time.RFC3339Nano),
+	}, "|")
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+func sealClaim(c *models.Claim) {
+	if c.PrevHash == "" {
+		c.PrevHash = GenesisHash
+	}
+	c.Hash = ClaimHash(c)
+}
+
+// RecordBuy claims the player for the buyer of t, a booked trade, if they
+// hold no claim on it yet. Buys claim as they are booked; this is for
+// Backfill.
+func (s *ClaimService) RecordBuy(ctx context.Context, t *models.Transaction) (*models.Claim, error) {
+	c := &models.Claim{
+		UserID:        t.UserID,
+		PlayerID:      t.AssetID,
+		TransactionID: t.ID,
+		EntryValue:    roundToStored(t.Price),
+		// Postgres keeps microseconds; truncate so the stored row rehashes identically.
+		ClaimedAt: t.Timestamp.UTC().Truncate(time.Microsecond),
+	}
+	created, err := s.Repo.CreateChained(ctx, c, sealClaim)
+	if err != nil {
+		return nil, fmt.Errorf("record claim (user_id=%d, player_id=%d): %w", t.UserID, t.AssetID, err)
+	}
+	if !created {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// roundToStored rounds to the NUMERIC(18,6) scale the claim is stored at.
+func roundToStored(v float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', 6, 64), 64)
+	return rounded
+}
+
+// Backfill claims first buys that were made before claims existed, in the
+// order they happened.
+func (s *ClaimService) Backfill(ctx context.Context) (int, error) {
+	buys, err := s.Repo.GetUnclaimedFirstBuys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, t := range buys {
+		c, err := s.RecordBuy(ctx, t)
+		if err != nil {
+			return count, err
+		}
+		if c != nil {
+			count++
+		}
+	}
+	if count > 0 {
+		logger.Log.Info("Backfilled claims", zap.Int("count", count))
+	}
+	return count, nil
+}
+
+func (s *ClaimService) GetByUserID(ctx context.Context, userID int64) ([]*models.Claim, error) {
+	return s.Repo.GetByUserID(ctx, userID)
+}
+
+// Verify recomputes every receipt in the chain. If receipt is non-empty the
+// matching claim is returned with the result; a receipt that is not in the
+// chain makes the result invalid.
+func (s *ClaimService) Verify(ctx context.Context, receipt string) (*models.ClaimVerification, error) {
+	chain, err := s.Repo.GetChain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ClaimVerification{Valid: true}
+	prev := GenesisHash
+	for _, c := range chain {
+		result.ClaimsChecked++
+		if c.PrevHash != prev || ClaimHash(c) != c.Hash {
+			id := c.ID
+			result.Valid = false
+			result.BrokenAtID = &id
+			break
+		}
+		if receipt != "" && c.Hash == receipt {
+			result.Receipt = c
+		}
+		prev = c.Hash
+	}
+
+	if receipt != "" && result.Receipt == nil {
+		result.Valid = false
+	}
+	return result, nil
+}
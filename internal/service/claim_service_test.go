@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type fakeClaimRepo struct {
+	repository.ClaimRepository
+	chain []*models.Claim
+}
+
+func (r *fakeClaimRepo) GetChain(ctx context.Context) ([]*models.Claim, error) {
+	return r.chain, nil
+}
+
+// claimChain seals n claims in order, each chained to the one before.
+func claimChain(n int) []*models.Claim {
+	at := time.Date(2025, 10, 21, 23, 0, 0, 123456000, time.UTC)
+	chain := make([]*models.Claim, n)
+	prev := ""
+	for i := range chain {
+		c := &models.Claim{
+			ID:            int64(i + 1),
+			UserID:        int64(10 + i),
+			PlayerID:      3,
+			TransactionID: int64(100 + i),
+			Rank:          i + 1,
+			EntryValue:    42.5 + float64(i),
+			ClaimedAt:     at.Add(time.Duration(i) * time.Minute),
+			PrevHash:      prev,
+		}
+		sealClaim(c)
+		chain[i] = c
+		prev = c.Hash
+	}
+	return chain
+}
+
+func TestClaimsChainToTheirPredecessor(t *testing.T) {
+	chain := claimChain(3)
+	if chain[0].PrevHash != GenesisHash {
+		t.Errorf("first claim follows %q, want the genesis hash", chain[0].PrevHash)
+	}
+	for i := 1; i < len(chain); i++ {
+		if chain[i].PrevHash != chain[i-1].Hash {
+			t.Errorf("claim %d follows %q, want %q", i+1, chain[i].PrevHash, chain[i-1].Hash)
+		}
+	}
+
+	// The hash covers the instant, not how it was written down.
+	c := *chain[0]
+	c.ClaimedAt = c.ClaimedAt.In(time.FixedZone("EST", -5*3600))
+	if ClaimHash(&c) != chain[0].Hash {
+		t.Error("hash changed with the claim's time zone")
+	}
+}
+
+func TestVerifyClaimChain(t *testing.T) {
+	cases := []struct {
+		name        string
+		tamper      func(chain []*models.Claim)
+		receipt     func(chain []*models.Claim) string
+		valid       bool
+		brokenAt    int64
+		checked     int
+		wantReceipt bool
+	}{
+		{name: "intact", valid: true, checked: 3},
+		{
+			name:     "edited claim",
+			tamper:   func(chain []*models.Claim) { chain[1].EntryValue = 1 },
+			checked:  2,
+			brokenAt: 2,
+		},
+		{
+			name: "resealed claim breaks its successor",
+			tamper: func(chain []*models.Claim) {
+				chain[1].EntryValue = 1
+				sealClaim(chain[1])
+			},
+			checked:  3,
+			brokenAt: 3,
+		},
+		{
+			name:     "removed claim",
+			tamper:   func(chain []*models.Claim) { chain[1] = chain[2]; chain[2] = nil },
+			checked:  2,
+			brokenAt: 3,
+		},
+		{
+			name:        "known receipt",
+			receipt:     func(chain []*models.Claim) string { return chain[1].Hash },
+			valid:       true,
+			checked:     3,
+			wantReceipt: true,
+		},
+		{
+			name:    "unknown receipt",
+			receipt: func(chain []*models.Claim) string { return GenesisHash },
+			checked: 3,
+		},
+	}
+	for _, tc := range cases {
+		chain := claimChain(3)
+		if tc.tamper != nil {
+			tc.tamper(chain)
+		}
+		if chain[len(chain)-1] == nil {
+			chain = chain[:len(chain)-1]
+		}
+		receipt := ""
+		if tc.receipt != nil {
+			receipt = tc.receipt(chain)
+		}
+
+		s := NewClaimService(&fakeClaimRepo{chain: chain})
+		got, err := s.Verify(context.Background(), receipt)
+		if err != nil {
+			t.Fatalf("%s: Verify: %v", tc.name, err)
+		}
+		if got.Valid != tc.valid || got.ClaimsChecked != tc.checked {
+			t.Errorf("%s: valid = %v after %d claims, want %v after %d", tc.name, got.Valid, got.ClaimsChecked, tc.valid, tc.checked)
+		}
+		switch {
+		case tc.brokenAt == 0 && got.BrokenAtID != nil:
+			t.Errorf("%s: broken at %d, want unbroken", tc.name, *got.BrokenAtID)
+		case tc.brokenAt != 0 && (got.BrokenAtID == nil || *got.BrokenAtID != tc.brokenAt):
+			t.Errorf("%s: broken at %v, want %d", tc.name, got.BrokenAtID, tc.brokenAt)
+		}
+		if (got.Receipt != nil) != tc.wantReceipt {
+			t.Errorf("%s: receipt = %+v", tc.name, got.Receipt)
+		}
+	}
+}
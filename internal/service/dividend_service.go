@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// DefaultDividendPerStat is paid per share for each point, rebound, assist,
+// steal and block a player records in a week.
+const DefaultDividendPerStat = 0.05
+
+type DividendService struct {
+	Repo    repository.DividendRepository
+	PerStat float64
+}
+
+func NewDividendService(repo repository.DividendRepository) *DividendService {
+	return &DividendService{Repo: repo, PerStat: DefaultDividendPerStat}
+}
+
+// PayWeekly pays holders of global islands for the latest weekly stats.
+func (s *DividendService) PayWeekly(ctx context.Context) error {
+	count, err := s.Repo.PayWeekly(ctx, s.PerStat)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Weekly dividends paid", zap.Int64("payments", count), zap.Float64("per_stat", s.PerStat))
+	return nil
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrDraftNotFound       = apperror.NotFound("DRAFT_NOT_FOUND", "draft not found")
+	ErrDraftExists         = apperror.Conflict("DRAFT_EXISTS", "this league already has a draft this season")
+	ErrDraftNotPending     = apperror.Conflict("DRAFT_NOT_PENDING", "draft has already started")
+	ErrDraftNotActive      = apperror.Conflict("DRAFT_NOT_ACTIVE", "draft is not running")
+	ErrDraftNeedsContained = apperror.Invalid("DRAFT_NEEDS_CONTAINED", "drafts are only for leagues with their own islands")
+	ErrDraftNeedsMembers   = apperror.Invalid("DRAFT_NEEDS_MEMBERS", "a draft needs at least two members")
+	ErrInvalidDraft        = apperror.Invalid("INVALID_DRAFT", "draft settings must be positive and price_pct not negative")
+	ErrNotLeagueOwner      = apperror.Forbidden("NOT_LEAGUE_OWNER", "only the league owner can do this")
+	ErrNotYourPick         = apperror.Forbidden("NOT_YOUR_PICK", "it is not your pick")
+)
+
+// DraftService runs snake drafts that seed a contained league's islands.
+// Members pick in a shuffled order that reverses every round. A pick left on
+// the clock past its deadline is made for the member: the most valuable
+// player they are allowed to take. Picks are booked as fee-free BUY
+// transactions at the draft price once the last pick is in.
+type DraftService struct {
+	Repo    repository.DraftRepository
+	Leagues repository.LeagueRepository
+	Players repository.PlayerRepository
+}
+
+func NewDraftService(repo repository.DraftRepository, leagues repository.LeagueRepository, players repository.PlayerRepository) *DraftService {
+	return &DraftService{Repo: repo, Leagues: leagues, Players: players}
+}
+
+// CreateDraftRequest schedules a draft; zero settings take their defaults.
+// PricePct is the share of a player's value a pick costs: omitted means
+// full price and 0 a free draft.
+type CreateDraftRequest struct {
+	Rounds        int      `json:"rounds"`
+	PickSeconds   int      `json:"pick_seconds"`
+	SharesPerPick int      `json:"shares_per_pick"`
+	MaxIslands    int      `json:"max_islands"`
+	PricePct      *float64 `json:"price_pct"`
+}
+
+const (
+	defaultDraftRounds      = 5
+	defaultDraftPickSeconds = 120
+)
+
+// snakePick returns the order slot (0-based) and round of a pick number.
+func snakePick(members int, pickNumber int) (int, int) {
+	slot := (pickNumber - 1) % members
+	round := (pickNumber-1)/members + 1
+	if round%2 == 0 {
+		slot = members - 1 - slot
+	}
+	return slot, round
+}
+
+// onTheClock fills in who owns the current pick of an active draft.
+func onTheClock(d *models.Draft) {
+	d.OnTheClock = nil
+	if d.Status != models.DraftActive || len(d.Order) == 0 {
+		return
+	}
+	slot, _ := snakePick(len(d.Order), d.CurrentPick)
+	userID := d.Order[slot].UserID
+	d.OnTheClock = &userID
+}
+
+func (s *DraftService) ownedContainedLeague(ctx context.Context, leagueID int64, userID int64) (*models.League, error) {
+	league, err := s.Leagues.GetByID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if league == nil {
+		return nil, ErrLeagueNotFound
+	}
+	if league.OwnerID != userID {
+		return nil, ErrNotLeagueOwner
+	}
+	if !league.Contained() {
+		return nil, ErrDraftNeedsContained
+	}
+	return league, nil
+}
+
+func (s *DraftService) Create(ctx context.Context, leagueID int64, userID int64, req CreateDraftRequest) (*models.Draft, error) {
+	if _, err := s.ownedContainedLeague(ctx, leagueID, userID); err != nil {
+		return nil, err
+	}
+	existing, err := s.Repo.GetByLeagueID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrDraftExists
+	}
+
+	d := &models.Draft{
+		LeagueID:      leagueID,
+		Rounds:        req.Rounds,
+		PickSeconds:   req.PickSeconds,
+		SharesPerPick: req.SharesPerPick,
+		MaxIslands:    req.MaxIslands,
+		PricePct:      1,
+	}
+	if d.Rounds == 0 {
+		d.Rounds = defaultDraftRounds
+	}
+	if d.PickSeconds == 0 {
+		d.PickSeconds = defaultDraftPickSeconds
+	}
+	if d.SharesPerPick == 0 {
+		d.SharesPerPick = 1
+	}
+	if d.MaxIslands == 0 {
+		d.MaxIslands = d.Rounds
+	}
+	if req.PricePct != nil {
+		d.PricePct = *req.PricePct
+	}
+	if d.Rounds < 0 || d.PickSeconds < 0 || d.SharesPerPick < 0 || d.MaxIslands < 0 || d.PricePct < 0 {
+		return nil, ErrInvalidDraft
+	}
+
+	if err := s.Repo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	d.Order = []models.DraftSlot{}
+	d.Picks = []models.DraftPick{}
+	return d, nil
+}
+
+// Get returns the league's draft for the current season to a member.
+func (s *DraftService) Get(ctx context.Context, leagueID int64, userID int64) (*models.Draft, error) {
+	member, err := s.Leagues.GetMember(ctx, leagueID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrNotLeagueMember
+	}
+	d, err := s.Repo.GetByLeagueID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDraftNotFound
+	}
+	onTheClock(d)
+	return d, nil
+}
+
+// Start shuffles the members into the draft order and opens the first pick.
+func (s *DraftService) Start(ctx context.Context, leagueID int64, userID int64) (*models.Draft, error) {
+	if _, err := s.ownedContainedLeague(ctx, leagueID, userID); err != nil {
+		return nil, err
+	}
+	d, err := s.Repo.GetByLeagueID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDraftNotFound
+	}
+	if d.Status != models.DraftPending {
+		return nil, ErrDraftNotPending
+	}
+	members, err := s.Leagues.GetMembers(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) < 2 {
+		return nil, ErrDraftNeedsMembers
+	}
+
+	order := make([]int64, len(members))
+	for i, m := range members {
+		order[i] = m.UserID
+	}
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+	deadline := time.Now().Add(time.Duration(d.PickSeconds) * time.Second)
+	if err := s.Repo.Start(ctx, d.ID, order, deadline); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDraftNotPending
+		}
+		return nil, err
+	}
+	return s.Get(ctx, leagueID, userID)
+}
+
+// Pick makes the current pick for the member on the clock.
+func (s *DraftService) Pick(ctx context.Context, leagueID int64, userID int64, playerID int64) (*models.Draft, error) {
+	d, err := s.Get(ctx, leagueID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != models.DraftActive {
+		return nil, ErrDraftNotActive
+	}
+	if d.OnTheClock == nil || *d.OnTheClock != userID {
+		return nil, ErrNotYourPick
+	}
+	if err := s.makePick(ctx, d, &playerID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, leagueID, userID)
+}
+
+// AutoPickExpired makes every pick that has run out its timer.
+func (s *DraftService) AutoPickExpired(ctx context.Context) error {
+	ids, err := s.Repo.GetExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, id := range ids {
+		d, err := s.Repo.GetByID(ctx, id)
+		if err == nil && d != nil {
+			onTheClock(d)
+			err = s.makePick(ctx, d, nil)
+		}
+		if err != nil && !errors.Is(err, ErrDraftNotActive) {
+			logger.Log.Error("Failed to auto-pick",
+				zap.Int64("draft_id", id),
+				zap.Error(err),
+			)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// draftBook is what a draft has committed so far, used to validate picks.
+type draftBook struct {
+	league  *models.League
+	held    map[int64]int
+	picked  map[int64]int
+	islands map[int64]bool
+	spent   float64
+	wallet  float64
+}
+
+func (s *DraftService) loadBook(ctx context.Context, d *models.Draft, userID int64) (*draftBook, error) {
+	league, err := s.Leagues.GetByID(ctx, d.LeagueID)
+	if err != nil {
+		return nil, err
+	}
+	if league == nil || !league.Contained() {
+		return nil, ErrDraftNeedsContained
+	}
+	member, err := s.Leagues.GetMember(ctx, d.LeagueID, userID)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.Leagues.GetHeldQuantities(ctx, d.LeagueID)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &draftBook{
+		league:  league,
+		held:    held,
+		picked:  make(map[int64]int),
+		islands: make(map[int64]bool),
+	}
+	if member != nil && member.Currency != nil {
+		book.wallet = *member.Currency
+	}
+	for _, p := range d.Picks {
+		if p.PlayerID == nil {
+			continue
+		}
+		book.picked[*p.PlayerID] += p.Quantity
+		if p.UserID == userID {
+			book.islands[*p.PlayerID] = true
+			book.spent += float64(p.Quantity) * p.Price
+		}
+	}
+	return book, nil
+}
+
+// validate checks a pick against the league islands' remaining capacity, the
+// draft's per-user island limit and the member's league wallet, and returns
+// the draft price per share.
+func (b *draftBook) validate(d *models.Draft, player *models.Player) (float64, error) {
+	remaining := *b.league.IslandCapacity - b.held[player.ID] - b.picked[player.ID]
+	if remaining < d.SharesPerPick {
+		return 0, apperror.Invalid("NO_CAPACITY", fmt.Sprintf("Player only has %v capacity remaining in this league, a pick takes %v", remaining, d.SharesPerPick))
+	}
+	if !b.islands[player.ID] && len(b.islands) >= d.MaxIslands {
+		return 0, apperror.Invalid("ISLAND_LIMIT", fmt.Sprintf("Drafts allow at most %v islands per member", d.MaxIslands))
+	}
+	price := roundCurrency(player.Value * d.PricePct)
+	cost := price * float64(d.SharesPerPick)
+	if b.spent+cost > b.wallet {
+		return 0, apperror.Invalid("USER_LACKS_MONEY", fmt.Sprintf("This pick would cost %v, user only has %v left", cost, b.wallet-b.spent))
+	}
+	return price, nil
+}
+
+// makePick records the current pick of d. A nil playerID auto-picks the most
+// valuable player that passes validation, or skips the pick if there is none.
+func (s *DraftService) makePick(ctx context.Context, d *models.Draft, playerID *int64) error {
+	if d.Status != models.DraftActive || d.OnTheClock == nil {
+		return ErrDraftNotActive
+	}
+	userID := *d.OnTheClock
+	book, err := s.loadBook(ctx, d, userID)
+	if err != nil {
+		return err
+	}
+
+	_, round := snakePick(len(d.Order), d.CurrentPick)
+	pick := &models.DraftPick{
+		PickNumber: d.CurrentPick,
+		Round:      round,
+		UserID:     userID,
+		Auto:       playerID == nil,
+	}
+
+	if playerID != nil {
+		player, err := s.Players.GetByID(ctx, *playerID)
+		if err != nil {
+			return err
+		}
+		if player == nil {
+			return apperror.NotFound("PLAYER_NOT_FOUND", "Could not find player")
+		}
+		price, err := book.validate(d, player)
+		if err != nil {
+			return err
+		}
+		pick.PlayerID, pick.Quantity, pick.Price = &player.ID, d.SharesPerPick, price
+	} else {
+		players, err := s.Players.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(players, func(i, j int) bool { return players[i].Value > players[j].Value })
+		for _, player := range players {
+			price, err := book.validate(d, player)
+			if err != nil {
+				continue
+			}
+			pick.PlayerID, pick.Quantity, pick.Price = &player.ID, d.SharesPerPick, price
+			break
+		}
+	}
+
+	// The last pick has no deadline; recording it completes the draft.
+	var next *time.Time
+	if d.CurrentPick < d.TotalPicks() {
+		deadline := time.Now().Add(time.Duration(d.PickSeconds) * time.Second)
+		next = &deadline
+	}
+	ok, err := s.Repo.RecordPick(ctx, d.ID, pick, next)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDraftNotActive
+	}
+	return nil
+}
+
+// InProgress reports whether the league has a draft running, during which
+// its islands cannot be traded.
+func (s *DraftService) InProgress(ctx context.Context, leagueID int64) (bool, error) {
+	d, err := s.Repo.GetByLeagueID(ctx, leagueID)
+	if err != nil {
+		return false, err
+	}
+	return d != nil && d.Status == models.DraftActive, nil
+}
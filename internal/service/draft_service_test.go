@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type fakeLeagueRepo struct {
+	repository.LeagueRepository
+	league *models.League
+}
+
+func (r *fakeLeagueRepo) GetByID(ctx context.Context, id int64) (*models.League, error) {
+	return r.league, nil
+}
+
+type fakeDraftRepo struct {
+	repository.DraftRepository
+	created *models.Draft
+}
+
+func (r *fakeDraftRepo) GetByLeagueID(ctx context.Context, leagueID int64) (*models.Draft, error) {
+	return nil, nil
+}
+
+func (r *fakeDraftRepo) Create(ctx context.Context, d *models.Draft) error {
+	r.created = d
+	return nil
+}
+
+func TestCreateDraftPricePct(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+	currency, capacity := 1000.0, 5
+	league := &models.League{ID: 6, OwnerID: 1, StartingCurrency: &currency, IslandCapacity: &capacity}
+
+	cases := []struct {
+		name string
+		pct  *float64
+		want float64
+		err  error
+	}{
+		{"omitted is full price", nil, 1, nil},
+		{"zero is a free draft", pct(0), 0, nil},
+		{"discount", pct(0.5), 0.5, nil},
+		{"negative", pct(-0.5), 0, ErrInvalidDraft},
+	}
+	for _, tc := range cases {
+		drafts := &fakeDraftRepo{}
+		s := NewDraftService(drafts, &fakeLeagueRepo{league: league}, nil)
+
+		d, err := s.Create(context.Background(), league.ID, league.OwnerID, CreateDraftRequest{PricePct: tc.pct})
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if err != nil {
+			if drafts.created != nil {
+				t.Errorf("%s: draft was stored", tc.name)
+			}
+			continue
+		}
+		if d.PricePct != tc.want {
+			t.Errorf("%s: price_pct = %v, want %v", tc.name, d.PricePct, tc.want)
+		}
+	}
+}
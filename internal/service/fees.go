@@ -0,0 +1,52 @@
+package service
+
+import (
+	"math"
+	"time"
+)
+
+// FeeSchedule controls the currency sinks applied to trades. Fees are taken
+// out of the economy entirely rather than paid to another user.
+type FeeSchedule struct {
+	BuyPct  float64
+	SellPct float64
+	Minimum float64
+
+	// EarlyFlipPct is charged on top of the sell fee when shares are sold
+	// within EarlyFlipWindow of being acquired.
+	EarlyFlipPct    float64
+	EarlyFlipWindow time.Duration
+}
+
+func DefaultFeeSchedule() FeeSchedule {
+	return FeeSchedule{
+		BuyPct:          0.01,
+		SellPct:         0.01,
+		Minimum:         1.0,
+		EarlyFlipPct:    0.10,
+		EarlyFlipWindow: 7 * 24 * time.Hour,
+	}
+}
+
+func (f FeeSchedule) BuyFee(cost float64) float64 {
+	return roundCurrency(math.Max(cost*f.BuyPct, f.Minimum))
+}
+
+func (f FeeSchedule) SellFee(proceeds float64, acquiredAt time.Time, now time.Time) float64 {
+	fee := math.Max(proceeds*f.SellPct, f.Minimum)
+	if f.IsEarlyFlip(acquiredAt, now) {
+		fee += proceeds * f.EarlyFlipPct
+	}
+	return roundCurrency(math.Min(fee, proceeds))
+}
+
+func (f FeeSchedule) IsEarlyFlip(acquiredAt time.Time, now time.Time) bool {
+	if acquiredAt.IsZero() || f.EarlyFlipWindow <= 0 {
+		return false
+	}
+	return now.Sub(acquiredAt) < f.EarlyFlipWindow
+}
+
+func roundCurrency(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuyFee(t *testing.T) {
+	f := DefaultFeeSchedule()
+	cases := []struct {
+		name string
+		cost float64
+		want float64
+	}{
+		{"percentage", 1000, 10},
+		{"minimum on small trades", 50, 1},
+		{"rounded to cents", 1234.567, 12.35},
+	}
+	for _, tc := range cases {
+		if got := f.BuyFee(tc.cost); got != tc.want {
+			t.Errorf("%s: BuyFee(%v) = %v, want %v", tc.name, tc.cost, got, tc.want)
+		}
+	}
+}
+
+func TestSellFee(t *testing.T) {
+	f := DefaultFeeSchedule()
+	now := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name       string
+		proceeds   float64
+		acquiredAt time.Time
+		want       float64
+	}{
+		{"held past the window", 1000, now.Add(-8 * 24 * time.Hour), 10},
+		{"early flip adds the penalty", 1000, now.Add(-6 * 24 * time.Hour), 110},
+		{"window end is not early", 1000, now.Add(-7 * 24 * time.Hour), 10},
+		{"unknown acquisition is not early", 1000, time.Time{}, 10},
+		{"minimum on small sales", 50, now.Add(-30 * 24 * time.Hour), 1},
+		{"early flip on the minimum", 5, now.Add(-time.Hour), 1.5},
+		{"never more than the proceeds", 0.5, now.Add(-time.Hour), 0.5},
+	}
+	for _, tc := range cases {
+		if got := f.SellFee(tc.proceeds, tc.acquiredAt, now); got != tc.want {
+			t.Errorf("%s: SellFee(%v) = %v, want %v", tc.name, tc.proceeds, got, tc.want)
+		}
+	}
+}
+
+func TestEarlyFlipDisabledWithoutWindow(t *testing.T) {
+	f := DefaultFeeSchedule()
+	f.EarlyFlipWindow = 0
+	now := time.Now()
+	if f.IsEarlyFlip(now.Add(-time.Minute), now) {
+		t.Error("IsEarlyFlip with no window = true, want false")
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrUnknownLeaderboard = apperror.Invalid("UNKNOWN_LEADERBOARD", "unknown leaderboard")
+	ErrUnknownWindow      = apperror.Invalid("UNKNOWN_WINDOW", "unknown leaderboard window")
+)
+
+var LeaderboardKinds = []string{
+	models.LeaderboardNetWorth,
+	models.LeaderboardReturn,
+	models.LeaderboardEarlyBeliever,
+}
+
+// leaderboardWindows maps a window to how many days back its baseline
+// snapshot is; "all" ranks on the live score.
+var leaderboardWindows = map[string]int{
+	"all": 0,
+	"1d":  1,
+	"7d":  7,
+	"30d": 30,
+}
+
+const (
+	defaultLeaderboardLimit = 25
+	maxLeaderboardLimit     = 100
+)
+
+type LeaderboardService struct {
+	Repo repository.LeaderboardRepository
+}
+
+func NewLeaderboardService(repo repository.LeaderboardRepository) *LeaderboardService {
+	return &LeaderboardService{Repo: repo}
+}
+
+func isLeaderboardKind(kind string) bool {
+	for _, k := range LeaderboardKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *LeaderboardService) Get(ctx context.Context, kind string, window string, page int, limit int) (*models.Leaderboard, error) {
+	if !isLeaderboardKind(kind) {
+		return nil, ErrUnknownLeaderboard
+	}
+	if window == "" {
+		window = "all"
+	}
+	days, ok := leaderboardWindows[window]
+	if !ok {
+		return nil, ErrUnknownWindow
+	}
+	page, limit = leaderboardPage(page, limit)
+
+	entries, total, err := s.Repo.GetRanked(ctx, kind, days, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Leaderboard{
+		Kind:    kind,
+		Window:  window,
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+		Entries: entries,
+	}, nil
+}
+
+// GetLeague ranks a league's members on their live score. Contained leagues
+// are scored on their league wallets and islands.
+func (s *LeaderboardService) GetLeague(ctx context.Context, league *models.League, kind string, page int, limit int) (*models.Leaderboard, error) {
+	if !isLeaderboardKind(kind) {
+		return nil, ErrUnknownLeaderboard
+	}
+	page, limit = leaderboardPage(page, limit)
+
+	entries, total, err := s.Repo.GetLeagueRanked(ctx, kind, league.ID, league.Contained(), limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Leaderboard{
+		Kind:    kind,
+		Window:  "all",
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+		Entries: entries,
+	}, nil
+}
+
+func leaderboardPage(page int, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultLeaderboardLimit
+	}
+	if limit > maxLeaderboardLimit {
+		limit = maxLeaderboardLimit
+	}
+	return page, limit
+}
+
+func (s *LeaderboardService) GetHistory(ctx context.Context, kind string, userID int64, timeRange string) ([]models.LeaderboardSnapshot, error) {
+	if !isLeaderboardKind(kind) {
+		return nil, ErrUnknownLeaderboard
+	}
+	return s.Repo.GetHistory(ctx, kind, userID, rangeStart(timeRange, time.Now()))
+}
+
+// SnapshotAll records today's ranking for every leaderboard. It is safe to
+// rerun on the same day.
+func (s *LeaderboardService) SnapshotAll(ctx context.Context) error {
+	for _, kind := range LeaderboardKinds {
+		if err := s.Repo.Snapshot(ctx, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrLeagueNotFound      = apperror.NotFound("LEAGUE_NOT_FOUND", "league not found")
+	ErrInvalidLeague       = apperror.Invalid("INVALID_LEAGUE", "league name must be 1-50 characters and league settings must be positive")
+	ErrNotLeagueMember     = apperror.Forbidden("NOT_LEAGUE_MEMBER", "user is not a member of this league")
+	ErrAlreadyLeagueMember = apperror.Conflict("ALREADY_LEAGUE_MEMBER", "user is already a member of this league")
+	ErrOwnerCannotLeave    = apperror.Conflict("OWNER_CANNOT_LEAVE", "the league owner cannot leave their league")
+	ErrLeaguePositionsOpen = apperror.Conflict("LEAGUE_POSITIONS_OPEN", "sell your league islands before leaving")
+)
+
+// inviteAlphabet leaves out characters that are easy to misread when a code
+// is shared by hand.
+const (
+	inviteAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	inviteCodeLength = 8
+)
+
+// LeagueService runs private leagues. A league ranks its members against each
+// other; a contained league also gives every member a league wallet and its
+// own islands so the group plays a separate game.
+type LeagueService struct {
+	Repo    repository.LeagueRepository
+	Seasons *SeasonService
+}
+
+func NewLeagueService(repo repository.LeagueRepository, seasons *SeasonService) *LeagueService {
+	return &LeagueService{Repo: repo, Seasons: seasons}
+}
+
+type CreateLeagueRequest struct {
+	Name             string   `json:"name"`
+	Contained        bool     `json:"contained"`
+	StartingCurrency *float64 `json:"starting_currency"`
+	IslandCapacity   *int     `json:"island_capacity"`
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	return string(b), nil
+}
+
+// Create makes a league owned by ownerID. Setting either starting currency or
+// island capacity makes it contained; missing settings then default to the
+// current season's.
+func (s *LeagueService) Create(ctx context.Context, ownerID int64, req CreateLeagueRequest) (*models.League, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 50 {
+		return nil, ErrInvalidLeague
+	}
+	league := &models.League{Name: name, OwnerID: ownerID}
+
+	if req.Contained || req.StartingCurrency != nil || req.IslandCapacity != nil {
+		currency, capacity := req.StartingCurrency, req.IslandCapacity
+		if currency == nil || capacity == nil {
+			season, err := s.Seasons.GetCurrent(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if currency == nil {
+				currency = &season.StartingCurrency
+			}
+			if capacity == nil {
+				capacity = &season.IslandCapacity
+			}
+		}
+		if *currency <= 0 || *capacity <= 0 {
+			return nil, ErrInvalidLeague
+		}
+		league.StartingCurrency = currency
+		league.IslandCapacity = capacity
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	league.InviteCode = code
+	if err := s.Repo.Create(ctx, league); err != nil {
+		return nil, err
+	}
+	return league, nil
+}
+
+func (s *LeagueService) GetByUserID(ctx context.Context, userID int64) ([]*models.League, error) {
+	return s.Repo.GetByUserID(ctx, userID)
+}
+
+// GetForMember returns a league only to its members. The invite code is
+// hidden from everyone but the owner.
+func (s *LeagueService) GetForMember(ctx context.Context, leagueID int64, userID int64) (*models.League, error) {
+	league, err := s.Repo.GetByID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if league == nil {
+		return nil, ErrLeagueNotFound
+	}
+	member, err := s.Repo.GetMember(ctx, leagueID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrNotLeagueMember
+	}
+	if league.OwnerID != userID {
+		league.InviteCode = ""
+	}
+	return league, nil
+}
+
+func (s *LeagueService) GetMembers(ctx context.Context, leagueID int64, userID int64) ([]*models.LeagueMember, error) {
+	if _, err := s.GetForMember(ctx, leagueID, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetMembers(ctx, leagueID)
+}
+
+// Join adds the user to the league behind an invite code. Members of a
+// contained league start with the league's starting currency.
+func (s *LeagueService) Join(ctx context.Context, userID int64, inviteCode string) (*models.League, error) {
+	league, err := s.Repo.GetByInviteCode(ctx, strings.ToUpper(strings.TrimSpace(inviteCode)))
+	if err != nil {
+		return nil, err
+	}
+	if league == nil {
+		return nil, ErrLeagueNotFound
+	}
+	added, err := s.Repo.AddMember(ctx, &models.LeagueMember{
+		LeagueID: league.ID,
+		UserID:   userID,
+		Currency: league.StartingCurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, ErrAlreadyLeagueMember
+	}
+	league.InviteCode = ""
+	return league, nil
+}
+
+// Leave removes the user from a league. Open league positions must be sold
+// first so the league islands' capacity is returned.
+func (s *LeagueService) Leave(ctx context.Context, leagueID int64, userID int64) error {
+	league, err := s.GetForMember(ctx, leagueID, userID)
+	if err != nil {
+		return err
+	}
+	if league.OwnerID == userID {
+		return ErrOwnerCannotLeave
+	}
+	open, err := s.Repo.HasOpenPositions(ctx, leagueID, userID)
+	if err != nil {
+		return err
+	}
+	if open {
+		return ErrLeaguePositionsOpen
+	}
+	return s.Repo.RemoveMember(ctx, leagueID, userID)
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var ErrInvalidCursor = apperror.Invalid("INVALID_CURSOR", "cursor is invalid or was issued for a different sort")
+
+// listError reports a cursor the repository could not use as the client's
+// mistake rather than a server error.
+func listError(err error) error {
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return ErrInvalidCursor
+	}
+	return err
+}
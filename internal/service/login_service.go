@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// ErrLoginLockedOut is returned by LoginService.Check; the LockoutError
+// carrying it says when to try again.
+var ErrLoginLockedOut = apperror.New(apperror.KindRateLimited, "LOGIN_LOCKED_OUT", "too many failed login attempts")
+
+// ErrResetThrottled is returned, with the retry time, for password reset
+// requests from an IP that ThrottleReset has locked out.
+var ErrResetThrottled = apperror.New(apperror.KindRateLimited, "PASSWORD_RESET_THROTTLED", "too many password reset requests")
+
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string { return ErrLoginLockedOut.Error() }
+func (e *LockoutError) Unwrap() error { return ErrLoginLockedOut }
+
+// LoginPolicy sets when repeated failures lock out a username or an IP. Once
+// a key reaches its limit, each further failure doubles the lockout, starting
+// from BaseLockout and capped at MaxLockout. Counts reset after Window
+// without a failure.
+type LoginPolicy struct {
+	MaxUserFailures int
+	MaxIPFailures   int
+	BaseLockout     time.Duration
+	MaxLockout      time.Duration
+	Window          time.Duration
+}
+
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxUserFailures: 5,
+		MaxIPFailures:   20,
+		BaseLockout:     30 * time.Second,
+		MaxLockout:      time.Hour,
+		Window:          time.Hour,
+	}
+}
+
+// lockout is how long a key is locked after its failures-th failure.
+func (p LoginPolicy) lockout(failures int, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	d := p.BaseLockout
+	for i := limit; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxLockout)
+}
+
+// LoginService throttles login attempts per username and per IP and keeps an
+// audit trail of failures. Unknown usernames are throttled like real ones so
+// lockouts do not reveal which accounts exist.
+type LoginService struct {
+	Repo   repository.LoginRepository
+	Policy LoginPolicy
+}
+
+func NewLoginService(repo repository.LoginRepository) *LoginService {
+	return &LoginService{Repo: repo, Policy: DefaultLoginPolicy()}
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetIPKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+// Check returns a LockoutError when the username or the client's IP is locked
+// out.
+func (s *LoginService) Check(ctx context.Context, username string, client Client) error {
+	until, err := s.Repo.LockedUntil(ctx, []string{userKey(username), ipKey(client.IP)})
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		return &LockoutError{Until: *until}
+	}
+	return nil
+}
+
+// Failure audits a rejected attempt and counts it towards both lockouts.
+// userID is nil when the username does not exist.
+func (s *LoginService) Failure(ctx context.Context, username string, userID *int64, client Client, reason string) error {
+	err := s.Repo.CreateFailedLogin(ctx, &models.FailedLogin{
+		Username:  username,
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	if reason == models.LoginFailureLockedOut {
+		return nil
+	}
+
+	if err := s.count(ctx, userKey(username), s.Policy.MaxUserFailures); err != nil {
+		return err
+	}
+	return s.count(ctx, ipKey(client.IP), s.Policy.MaxIPFailures)
+}
+
+func (s *LoginService) count(ctx context.Context, key string, limit int) error {
+	failures, err := s.Repo.RecordFailure(ctx, key, s.Policy.Window)
+	if err != nil {
+		return err
+	}
+	lockout := s.Policy.lockout(failures, limit)
+	if lockout == 0 {
+		return nil
+	}
+	logger.Log.Warn("Locking out logins",
+		zap.String("key", key),
+		zap.Int("failures", failures),
+		zap.Duration("lockout", lockout),
+	)
+	return s.Repo.Lock(ctx, key, time.Now().Add(lockout))
+}
+
+// ThrottleReset counts a password reset request against the client's IP
+// with the same limit and backoff as failed logins, under a key of its own
+// so resets never lock out logins. It returns when the IP may ask again if
+// it is locked out, and the zero time otherwise.
+func (s *LoginService) ThrottleReset(ctx context.Context, client Client) (time.Time, error) {
+	key := resetIPKey(client.IP)
+	until, err := s.Repo.LockedUntil(ctx, []string{key})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if until != nil {
+		return *until, nil
+	}
+	return time.Time{}, s.count(ctx, key, s.Policy.MaxIPFailures)
+}
+
+// Success clears the username's failure count. The IP count is left to
+// expire, so one valid account cannot shield guessing at others.
+func (s *LoginService) Success(ctx context.Context, username string) error {
+	return s.Repo.Reset(ctx, userKey(username))
+}
+
+func (s *LoginService) GetFailedLogins(ctx context.Context, username string, limit int) ([]*models.FailedLogin, error) {
+	return s.Repo.GetFailedLogins(ctx, username, limit)
+}
+
+func (s *LoginService) Cleanup(ctx context.Context) error {
+	deleted, err := s.Repo.DeleteStale(ctx, s.Policy.Window)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Deleted stale login throttles", zap.Int64("count", deleted))
+	return nil
+}
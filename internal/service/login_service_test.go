@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+func TestLockoutBackoff(t *testing.T) {
+	p := DefaultLoginPolicy()
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{11, 32 * time.Minute},
+		{12, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tc := range cases {
+		if got := p.lockout(tc.failures, p.MaxUserFailures); got != tc.want {
+			t.Errorf("lockout after %d failures = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+type fakeLoginRepo struct {
+	repository.LoginRepository
+	failures map[string]int
+	locked   map[string]time.Time
+	audited  []*models.FailedLogin
+}
+
+func (r *fakeLoginRepo) LockedUntil(ctx context.Context, keys []string) (*time.Time, error) {
+	var latest *time.Time
+	for _, k := range keys {
+		if until, ok := r.locked[k]; ok && time.Now().Before(until) && (latest == nil || until.After(*latest)) {
+			latest = &until
+		}
+	}
+	return latest, nil
+}
+
+func (r *fakeLoginRepo) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	r.failures[key]++
+	return r.failures[key], nil
+}
+
+func (r *fakeLoginRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	r.locked[key] = until
+	return nil
+}
+
+func (r *fakeLoginRepo) Reset(ctx context.Context, key string) error {
+	delete(r.failures, key)
+	return nil
+}
+
+func (r *fakeLoginRepo) CreateFailedLogin(ctx context.Context, f *models.FailedLogin) error {
+	r.audited = append(r.audited, f)
+	return nil
+}
+
+func TestFailuresLockOutUsernameAndIP(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeLoginRepo{failures: map[string]int{}, locked: map[string]time.Time{}}
+	s := NewLoginService(repo)
+	s.Policy.MaxIPFailures = 8
+	home := Client{IP: "198.51.100.7"}
+	away := Client{IP: "203.0.113.9"}
+
+	for i := 0; i < s.Policy.MaxUserFailures-1; i++ {
+		if err := s.Failure(ctx, "Alice", nil, home, models.LoginFailureBadPassword); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Check(ctx, "alice", away); err != nil {
+		t.Fatalf("locked out before the limit: %v", err)
+	}
+
+	s.Failure(ctx, "alice", nil, home, models.LoginFailureBadPassword)
+	var lockout *LockoutError
+	if err := s.Check(ctx, "ALICE", away); !errors.As(err, &lockout) || !errors.Is(err, ErrLoginLockedOut) {
+		t.Fatalf("username not locked out at the limit: %v", err)
+	}
+	if wait := time.Until(lockout.Until); wait <= 0 || wait > s.Policy.BaseLockout {
+		t.Errorf("first lockout lasts %v, want up to %v", wait, s.Policy.BaseLockout)
+	}
+
+	// Attempts rejected for being locked out are audited but not counted.
+	s.Failure(ctx, "alice", nil, home, models.LoginFailureLockedOut)
+	if got := repo.failures[userKey("alice")]; got != s.Policy.MaxUserFailures {
+		t.Errorf("username failures = %d, want %d", got, s.Policy.MaxUserFailures)
+	}
+	if len(repo.audited) != s.Policy.MaxUserFailures+1 {
+		t.Errorf("audited %d failures, want %d", len(repo.audited), s.Policy.MaxUserFailures+1)
+	}
+
+	// Another username from the same IP only runs into the IP limit.
+	for i := 0; i < 3; i++ {
+		s.Failure(ctx, "bob", nil, home, models.LoginFailureBadPassword)
+	}
+	if err := s.Check(ctx, "carol", home); !errors.Is(err, ErrLoginLockedOut) {
+		t.Errorf("IP not locked out after %d failures: %v", repo.failures[ipKey(home.IP)], err)
+	}
+	if err := s.Check(ctx, "carol", away); err != nil {
+		t.Errorf("other IP locked out: %v", err)
+	}
+
+	if err := s.Success(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.failures[userKey("bob")] != 0 || repo.failures[ipKey(home.IP)] == 0 {
+		t.Errorf("success reset %v, want only the username's count cleared", repo.failures)
+	}
+}
+
+func TestResetRequestsThrottledPerIPApartFromLogins(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeLoginRepo{failures: map[string]int{}, locked: map[string]time.Time{}}
+	s := NewLoginService(repo)
+	s.Policy.MaxIPFailures = 3
+	home := Client{IP: "198.51.100.7"}
+
+	for i := 1; i <= s.Policy.MaxIPFailures+1; i++ {
+		until, err := s.ThrottleReset(ctx, home)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked := !until.IsZero(); locked != (i > s.Policy.MaxIPFailures) {
+			t.Errorf("request %d: locked until %v", i, until)
+		}
+	}
+	if err := s.Check(ctx, "alice", home); err != nil {
+		t.Errorf("reset requests locked out logins: %v", err)
+	}
+	if until, _ := s.ThrottleReset(ctx, Client{IP: "203.0.113.9"}); !until.IsZero() {
+		t.Errorf("other IP locked until %v", until)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+// HoldingRules keeps islands from being flipped like stocks. Every lot must be
+// held for MinHoldPeriod before it can be sold. With FIFOLots the hold is
+// checked per lot, oldest first; otherwise it runs from the latest buy.
+type HoldingRules struct {
+	MinHoldPeriod time.Duration
+	FIFOLots      bool
+}
+
+func DefaultHoldingRules() HoldingRules {
+	return HoldingRules{
+		MinHoldPeriod: 24 * time.Hour,
+		FIFOLots:      true,
+	}
+}
+
+// BuildLots replays one user's transactions for one player and returns the
+// open lots, oldest first.
+func BuildLots(transactions []*models.Transaction, minHold time.Duration) []models.Lot {
+	sorted := make([]*models.Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	lots := make([]models.Lot, 0)
+	for _, t := range sorted {
+		switch t.Type {
+		case "BUY":
+			lots = append(lots, models.Lot{
+				TransactionID: t.ID,
+				Quantity:      t.Quantity,
+				Price:         t.Price,
+				AcquiredAt:    t.Timestamp,
+				UnlocksAt:     t.Timestamp.Add(minHold),
+			})
+		case "SELL":
+			remaining := t.Quantity
+			for remaining > 0 && len(lots) > 0 {
+				if lots[0].Quantity > remaining {
+					lots[0].Quantity -= remaining
+					remaining = 0
+					break
+				}
+				remaining -= lots[0].Quantity
+				lots = lots[1:]
+			}
+		}
+	}
+	return lots
+}
+
+// consumeLots returns the lots a sell of quantity would draw from, FIFO.
+func consumeLots(lots []models.Lot, quantity int) []models.Lot {
+	consumed := make([]models.Lot, 0)
+	for _, l := range lots {
+		if quantity <= 0 {
+			break
+		}
+		take := l.Quantity
+		if take > quantity {
+			take = quantity
+		}
+		l.Quantity = take
+		consumed = append(consumed, l)
+		quantity -= take
+	}
+	return consumed
+}
+
+func holdPeriodError(unlocksAt time.Time) *apperror.Error {
+	return apperror.Conflict("HOLD_PERIOD_ACTIVE",
+		fmt.Sprintf("Shares are still in their minimum hold period until %v", unlocksAt.UTC().Format(time.RFC3339)),
+	).WithDetail("unlocks_at", unlocksAt)
+}
+
+// latestSeasonOnly drops trades from archived seasons. An open position only
+// exists in the current season, which is always the newest one traded in.
+func latestSeasonOnly(transactions []*models.Transaction) []*models.Transaction {
+	var latest int64
+	for _, t := range transactions {
+		if t.SeasonID > latest {
+			latest = t.SeasonID
+		}
+	}
+	filtered := make([]*models.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if t.SeasonID == latest {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+func inLeague(transactions []*models.Transaction, leagueID int64) []*models.Transaction {
+	filtered := make([]*models.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if t.LeagueID == leagueID {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+func groupTransactionsByUser(transactions []*models.Transaction) map[int64][]*models.Transaction {
+	grouped := make(map[int64][]*models.Transaction)
+	for _, t := range transactions {
+		grouped[t.UserID] = append(grouped[t.UserID], t)
+	}
+	return grouped
+}
+
+func groupTransactionsByPlayer(transactions []*models.Transaction) map[int64][]*models.Transaction {
+	grouped := make(map[int64][]*models.Transaction)
+	for _, t := range transactions {
+		grouped[t.AssetID] = append(grouped[t.AssetID], t)
+	}
+	return grouped
+}
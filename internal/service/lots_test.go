@@ -0,0 +1,93 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+)
+
+func TestBuildLotsMatchesSellsFIFO(t *testing.T) {
+	day := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	at := func(days int) time.Time { return day.Add(time.Duration(days) * 24 * time.Hour) }
+	buy := func(id int64, days int, qty int, price float64) *models.Transaction {
+		return &models.Transaction{ID: id, Type: "BUY", Quantity: qty, Price: price, Timestamp: at(days)}
+	}
+	sell := func(id int64, days int, qty int) *models.Transaction {
+		return &models.Transaction{ID: id, Type: "SELL", Quantity: qty, Timestamp: at(days)}
+	}
+	lot := func(id int64, days int, qty int, price float64) models.Lot {
+		return models.Lot{TransactionID: id, Quantity: qty, Price: price, AcquiredAt: at(days), UnlocksAt: at(days + 1)}
+	}
+
+	cases := []struct {
+		name string
+		txs  []*models.Transaction
+		want []models.Lot
+	}{
+		{"no trades", nil, []models.Lot{}},
+		{
+			"buys stay separate",
+			[]*models.Transaction{buy(1, 0, 5, 10), buy(2, 1, 3, 12)},
+			[]models.Lot{lot(1, 0, 5, 10), lot(2, 1, 3, 12)},
+		},
+		{
+			"sell draws from the oldest lot",
+			[]*models.Transaction{buy(1, 0, 5, 10), buy(2, 1, 3, 12), sell(3, 2, 2)},
+			[]models.Lot{lot(1, 0, 3, 10), lot(2, 1, 3, 12)},
+		},
+		{
+			"sell spans lots",
+			[]*models.Transaction{buy(1, 0, 5, 10), buy(2, 1, 3, 12), sell(3, 2, 6)},
+			[]models.Lot{lot(2, 1, 2, 12)},
+		},
+		{
+			"sell empties a lot exactly",
+			[]*models.Transaction{buy(1, 0, 5, 10), buy(2, 1, 3, 12), sell(3, 2, 5)},
+			[]models.Lot{lot(2, 1, 3, 12)},
+		},
+		{
+			"replayed in time order whatever the input order",
+			[]*models.Transaction{sell(3, 2, 2), buy(2, 1, 3, 12), buy(1, 0, 5, 10)},
+			[]models.Lot{lot(1, 0, 3, 10), lot(2, 1, 3, 12)},
+		},
+		{
+			"same timestamp ordered by id",
+			[]*models.Transaction{sell(2, 0, 1), buy(1, 0, 2, 10)},
+			[]models.Lot{lot(1, 0, 1, 10)},
+		},
+		{
+			"buy after selling out opens a new lot",
+			[]*models.Transaction{buy(1, 0, 2, 10), sell(2, 1, 2), buy(3, 2, 4, 11)},
+			[]models.Lot{lot(3, 2, 4, 11)},
+		},
+	}
+	for _, tc := range cases {
+		if got := BuildLots(tc.txs, 24*time.Hour); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: lots = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestConsumeLotsTakesOldestFirst(t *testing.T) {
+	lots := []models.Lot{{TransactionID: 1, Quantity: 5}, {TransactionID: 2, Quantity: 3}, {TransactionID: 3, Quantity: 4}}
+	cases := []struct {
+		quantity int
+		want     []models.Lot
+	}{
+		{0, []models.Lot{}},
+		{2, []models.Lot{{TransactionID: 1, Quantity: 2}}},
+		{5, []models.Lot{{TransactionID: 1, Quantity: 5}}},
+		{7, []models.Lot{{TransactionID: 1, Quantity: 5}, {TransactionID: 2, Quantity: 2}}},
+		{20, lots},
+	}
+	for _, tc := range cases {
+		if got := consumeLots(lots, tc.quantity); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("consumeLots(%d) = %+v, want %+v", tc.quantity, got, tc.want)
+		}
+	}
+	if lots[0].Quantity != 5 {
+		t.Errorf("consumeLots changed its input: %+v", lots)
+	}
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	keys, err := auth.EphemeralKeySet("nbaisland", "nbaisland-api", 15*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	auth.Configure(keys)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/mail"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var ErrNotificationNotFound = apperror.NotFound("NOTIFICATION_NOT_FOUND", "notification not found")
+
+// NotificationService keeps each user's in-app notifications and, when
+// asked, emails them too. Mailer may be nil, which turns email off.
+type NotificationService struct {
+	Repo    repository.NotificationRepository
+	Mailer  mail.Mailer
+	BaseURL string
+}
+
+func NewNotificationService(repo repository.NotificationRepository, mailer mail.Mailer, baseURL string) *NotificationService {
+	return &NotificationService{Repo: repo, Mailer: mailer, BaseURL: baseURL}
+}
+
+// Notify stores n and, if emailTo is set, emails it there as well. A failed
+// email is logged rather than returned; the notification is still in the
+// app.
+func (s *NotificationService) Notify(ctx context.Context, n *models.Notification, emailTo string) error {
+	if err := s.Repo.Create(ctx, n); err != nil {
+		return err
+	}
+	s.Email(ctx, n, emailTo)
+	return nil
+}
+
+// Email sends the stored notification n to emailTo, doing nothing if
+// emailTo is empty or email is off. Failures are logged.
+func (s *NotificationService) Email(ctx context.Context, n *models.Notification, emailTo string) {
+	if emailTo == "" || s.Mailer == nil {
+		return
+	}
+	err := s.Mailer.Send(ctx, mail.Message{
+		To:      emailTo,
+		Subject: n.Title,
+		Body:    n.Body + "\n\nSee all your notifications at " + s.BaseURL + "/notifications\n",
+	})
+	if err != nil {
+		logger.Log.Warn("Failed to email notification",
+			zap.Int64("notification_id", n.ID),
+			zap.Int64("user_id", n.UserID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *NotificationService) List(ctx context.Context, filter models.NotificationFilter, req models.PageRequest) (*models.Page[*models.Notification], error) {
+	page, err := s.Repo.List(ctx, filter, req)
+	return page, listError(err)
+}
+
+func (s *NotificationService) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	return s.Repo.CountUnread(ctx, userID)
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID int64, id int64) error {
+	found, err := s.Repo.MarkRead(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	return s.Repo.MarkAllRead(ctx, userID)
+}
+
+// Cleanup drops notifications read more than age ago.
+func (s *NotificationService) Cleanup(ctx context.Context, age time.Duration) error {
+	n, err := s.Repo.PruneRead(ctx, time.Now().Add(-age))
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Pruned read notifications", zap.Int64("count", n))
+	return nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/oidc"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrUnknownProvider   = apperror.NotFound("UNKNOWN_PROVIDER", "unknown sign-in provider")
+	ErrInvalidOIDCState  = apperror.Invalid("INVALID_OIDC_STATE", "sign-in expired or was already used; start again")
+	ErrOIDCEmailRequired = apperror.Invalid("OIDC_EMAIL_REQUIRED", "the provider did not share a verified email address")
+	ErrOIDCEmailTaken    = apperror.Conflict("EMAIL_TAKEN", "an account already uses this email; sign in and link the provider from your account")
+	ErrIdentityLinked    = apperror.Conflict("IDENTITY_LINKED", "this identity is linked to another account")
+)
+
+// OIDCService signs users in through external OpenID Connect providers. The
+// first sign-in with an identity creates an account for it; a signed-in user
+// can instead link the identity to their existing account. Accounts are
+// never matched by email, which would let a provider take over an account.
+type OIDCService struct {
+	Providers  map[string]*oidc.Provider
+	Identities repository.IdentityRepository
+	Users      repository.UserRepository
+	Seasons    *SeasonService
+	StateTTL   time.Duration
+}
+
+func NewOIDCService(providers map[string]*oidc.Provider, identities repository.IdentityRepository, users repository.UserRepository, seasons *SeasonService) *OIDCService {
+	return &OIDCService{
+		Providers:  providers,
+		Identities: identities,
+		Users:      users,
+		Seasons:    seasons,
+		StateTTL:   10 * time.Minute,
+	}
+}
+
+func (s *OIDCService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin starts a sign-in with provider and returns the URL to send the user
+// to. linkUserID is set when a signed-in user is linking the identity.
+func (s *OIDCService) Begin(ctx context.Context, provider string, linkUserID *int64) (string, error) {
+	p, ok := s.Providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	state, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	url, err := p.AuthCodeURL(ctx, state, nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		return "", err
+	}
+	err = s.Identities.CreateState(ctx, &models.OIDCState{
+		State:        state,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       linkUserID,
+		ExpiresAt:    time.Now().Add(s.StateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// Complete finishes a sign-in from the provider's callback and returns the
+// user to issue a session for. A link started with Begin cannot be finished
+// here; it needs CompleteLink and the session of the user who started it.
+func (s *OIDCService) Complete(ctx context.Context, provider string, state string, code string) (*models.User, error) {
+	st, claims, err := s.exchange(ctx, provider, state, code)
+	if err != nil {
+		return nil, err
+	}
+	if st.UserID != nil {
+		return nil, ErrInvalidOIDCState
+	}
+
+	identity, err := s.Identities.Get(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if err := s.Identities.TouchLogin(ctx, identity.ID); err != nil {
+			return nil, err
+		}
+		return s.Users.GetByID(ctx, identity.UserID)
+	}
+	return s.signUp(ctx, provider, claims)
+}
+
+// CompleteLink finishes linking an identity to userID, the signed-in user
+// posting the callback. The state must have been started by that same user,
+// so a callback for someone else's link cannot attach the caller's identity
+// to their account.
+func (s *OIDCService) CompleteLink(ctx context.Context, provider string, state string, code string, userID int64) (*models.User, error) {
+	st, claims, err := s.exchange(ctx, provider, state, code)
+	if err != nil {
+		return nil, err
+	}
+	if st.UserID == nil || *st.UserID != userID {
+		logger.Log.Warn("OIDC link completed by a different user",
+			zap.Int64("user_id", userID),
+			zap.String("provider", provider),
+		)
+		return nil, ErrInvalidOIDCState
+	}
+
+	identity, err := s.Identities.Get(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.link(ctx, provider, claims, identity, userID)
+}
+
+// exchange consumes state and trades code for the identity's claims.
+func (s *OIDCService) exchange(ctx context.Context, provider string, state string, code string) (*models.OIDCState, *oidc.Claims, error) {
+	p, ok := s.Providers[provider]
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+	st, err := s.Identities.ConsumeState(ctx, state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st == nil || st.Provider != provider {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	claims, err := p.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, claims, nil
+}
+
+func (s *OIDCService) link(ctx context.Context, provider string, claims *oidc.Claims, identity *models.UserIdentity, userID int64) (*models.User, error) {
+	if identity != nil && identity.UserID != userID {
+		return nil, ErrIdentityLinked
+	}
+	if identity == nil {
+		err := s.Identities.Create(ctx, &models.UserIdentity{
+			UserID:   userID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+		if errors.Is(err, repository.ErrIdentityLinked) {
+			return nil, ErrIdentityLinked
+		}
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.Info("Linked OIDC identity",
+			zap.Int64("user_id", userID),
+			zap.String("provider", provider),
+		)
+	}
+	return s.Users.GetByID(ctx, userID)
+}
+
+// signUp creates an account for an identity seen for the first time.
+func (s *OIDCService) signUp(ctx context.Context, provider string, claims *oidc.Claims) (*models.User, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailRequired
+	}
+	existing, err := s.Users.GetByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrOIDCEmailTaken
+	}
+
+	startingCurrency, err := s.Seasons.StartingCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	name := []rune(strings.TrimSpace(claims.Name))
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+
+	user := &models.User{
+		Username: username,
+		Name:     string(name),
+		Email:    claims.Email,
+		Password: auth.UnusablePassword,
+		Currency: startingCurrency,
+	}
+	if err := s.Users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return nil, ErrOIDCEmailTaken
+		}
+		return nil, err
+	}
+	if err := s.Users.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	user.EmailVerified = true
+
+	err = s.Identities.Create(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		// A concurrent callback for the same identity won; drop our account.
+		if delErr := s.Users.Delete(ctx, user.ID); delErr != nil {
+			logger.Log.Error("Failed to remove account after identity link failed", zap.Error(delErr))
+		}
+		if errors.Is(err, repository.ErrIdentityLinked) {
+			return nil, ErrIdentityLinked
+		}
+		return nil, err
+	}
+	logger.Log.Info("Created account from OIDC identity",
+		zap.Int64("user_id", user.ID),
+		zap.String("provider", provider),
+	)
+	return user, nil
+}
+
+// availableUsername derives a username from the provider's preferred username
+// or the email's local part, adding digits until it is free.
+func (s *OIDCService) availableUsername(ctx context.Context, claims *oidc.Claims) (string, error) {
+	source := claims.PreferredUsername
+	if source == "" {
+		source, _, _ = strings.Cut(claims.Email, "@")
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(source) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	base := b.String()
+	if len(base) > 15 {
+		base = base[:15]
+	}
+	if len(base) < 3 || reservedUsernames[base] {
+		base = "player_" + base
+		if len(base) > 15 {
+			base = base[:15]
+		}
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 10; attempt++ {
+		existing, err := s.Users.GetByUsername(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s%04d", base, n.Int64())
+	}
+	return "", errors.New("could not find a free username")
+}
+
+func (s *OIDCService) GetIdentities(ctx context.Context, userID int64) ([]*models.UserIdentity, error) {
+	return s.Identities.GetByUserID(ctx, userID)
+}
+
+func (s *OIDCService) Cleanup(ctx context.Context) error {
+	deleted, err := s.Identities.DeleteExpiredStates(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Deleted expired OIDC states", zap.Int64("count", deleted))
+	return nil
+}
@@ -19,6 +19,11 @@ func(s *PlayerService) GetAll(ctx context.Context) ([]*models.Player, error) {
 	return s.Repo.GetAll(ctx)
 }
 
+func(s *PlayerService) List(ctx context.Context, filter models.PlayerFilter, req models.PageRequest) (*models.Page[*models.Player], error) {
+	page, err := s.Repo.List(ctx, filter, req)
+	return page, listError(err)
+}
+
 func(s *PlayerService) GetPlayersByIDs(ctx context.Context, player_ids []int64) ([]*models.Player, error) {
 	return s.Repo.GetByIDs(ctx, player_ids)
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type PortfolioService struct {
+	Repo repository.PortfolioRepository
+}
+
+func NewPortfolioService(repo repository.PortfolioRepository) *PortfolioService {
+	return &PortfolioService{Repo: repo}
+}
+
+func (s *PortfolioService) SnapshotAll(ctx context.Context) error {
+	count, err := s.Repo.SnapshotAll(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Portfolio snapshots recorded", zap.Int64("users", count))
+	return nil
+}
+
+func (s *PortfolioService) GetHistory(ctx context.Context, userID int64, timeRange string) ([]models.PortfolioPoint, error) {
+	return s.Repo.GetHistory(ctx, userID, rangeStart(timeRange, time.Now()))
+}
@@ -1,11 +1,32 @@
 package service
 
-import ( 
+import (
 	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
 	"github.com/nbaisland/nbaisland/internal/models"
-    "github.com/nbaisland/nbaisland/internal/repository"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// A price history is downsampled to at most maxHistoryPoints points; a
+// candle series may not be longer than maxCandles.
+const (
+	maxHistoryPoints = 500
+	maxCandles       = 1000
 )
 
+// candleIntervals are finest first, the order an interval is picked in.
+var candleIntervals = []string{models.CandleHour, models.CandleDay, models.CandleWeek}
+
+var candleDurations = map[string]time.Duration{
+	models.CandleHour: time.Hour,
+	models.CandleDay:  24 * time.Hour,
+	models.CandleWeek: 7 * 24 * time.Hour,
+}
+
 type PriceHistoryService struct {
 	Repo repository.PlayerPriceRepository
 }
@@ -14,6 +35,108 @@ func NewPriceHistoryService(repo repository.PlayerPriceRepository) *PriceHistory
 	return &PriceHistoryService{Repo: repo}
 }
 
-func(s *PriceHistoryService) GetPlayerPriceHistory(ctx context.Context, playerID int64, timeRange string) ([]models.PricePoint, error) {
-	return s.Repo.GetPlayerPriceHistory(ctx, playerID, timeRange)
+// rangeStart is when a history ?range= starts, counting back from now. It is
+// nil for "all", meaning from the first record. Unknown ranges mean 30 days.
+func rangeStart(timeRange string, now time.Time) *time.Time {
+	var start time.Time
+	switch timeRange {
+	case "7d":
+		start = now.AddDate(0, 0, -7)
+	case "90d":
+		start = now.AddDate(0, 0, -90)
+	case "1y":
+		start = now.AddDate(-1, 0, 0)
+	case "all":
+		return nil
+	default:
+		start = now.AddDate(0, 0, -30)
+	}
+	return &start
+}
+
+// HistoryWindow is the span a history covers: either Range, counting back
+// from now, or From and To. A missing From means from the first record and
+// a missing To means now.
+type HistoryWindow struct {
+	Range string
+	From  *time.Time
+	To    *time.Time
+}
+
+func (w HistoryWindow) resolve(now time.Time) (*time.Time, time.Time, error) {
+	if w.From == nil && w.To == nil {
+		return rangeStart(w.Range, now), now, nil
+	}
+	if w.Range != "" {
+		return nil, now, apperror.InvalidRequest("Use either range or from and to, not both")
+	}
+	to := now
+	if w.To != nil && w.To.Before(now) {
+		to = *w.To
+	}
+	if w.From != nil && !w.From.Before(to) {
+		return nil, now, apperror.InvalidRequest("from must be before to")
+	}
+	return w.From, to, nil
+}
+
+// GetPlayerPriceHistory returns the player's recorded values over window,
+// downsampled when there are too many to chart.
+func (s *PriceHistoryService) GetPlayerPriceHistory(ctx context.Context, playerID int64, window HistoryWindow) ([]models.PricePoint, error) {
+	from, to, err := window.resolve(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.GetPlayerPriceHistory(ctx, playerID, from, to, maxHistoryPoints)
+}
+
+// GetCandles returns the player's candles over window. With no interval it
+// uses the finest one that fits in maxCandles.
+func (s *PriceHistoryService) GetCandles(ctx context.Context, playerID int64, interval string, window HistoryWindow) (*models.CandleSeries, error) {
+	if interval != "" && !slices.Contains(candleIntervals, interval) {
+		return nil, apperror.InvalidRequest("interval must be hour, day or week")
+	}
+	start, to, err := window.resolve(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if start == nil {
+		if start, err = s.Repo.GetFirstPriceTime(ctx, playerID); err != nil {
+			return nil, err
+		}
+		if start == nil {
+			start = &to
+		}
+	}
+	from := *start
+
+	if interval == "" {
+		for _, candidate := range candleIntervals {
+			interval = candidate
+			if candleCount(candidate, from, to) <= maxCandles {
+				break
+			}
+		}
+	}
+	if n := candleCount(interval, from, to); n > maxCandles {
+		return nil, apperror.InvalidRequest(fmt.Sprintf(
+			"That is %d %s candles; at most %d fit, so use a longer interval or a shorter window", n, interval, maxCandles))
+	}
+
+	candles, err := s.Repo.GetCandles(ctx, playerID, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CandleSeries{
+		PlayerID: playerID,
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Candles:  candles,
+	}, nil
+}
+
+// candleCount is about how many candles of interval cover from to to.
+func candleCount(interval string, from time.Time, to time.Time) int {
+	return int(to.Sub(from)/candleDurations[interval]) + 1
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+type fakePriceRepo struct {
+	repository.PlayerPriceRepository
+	first    *time.Time
+	interval string
+	from     time.Time
+	to       time.Time
+}
+
+func (r *fakePriceRepo) GetFirstPriceTime(ctx context.Context, playerID int64) (*time.Time, error) {
+	return r.first, nil
+}
+
+func (r *fakePriceRepo) GetCandles(ctx context.Context, playerID int64, interval string, from time.Time, to time.Time) ([]models.Candle, error) {
+	r.interval, r.from, r.to = interval, from, to
+	return []models.Candle{}, nil
+}
+
+func TestCandleIntervalFitsWindow(t *testing.T) {
+	cases := []struct {
+		window HistoryWindow
+		want   string
+	}{
+		{HistoryWindow{Range: "7d"}, models.CandleHour},
+		{HistoryWindow{Range: "90d"}, models.CandleDay},
+		{HistoryWindow{Range: "all"}, models.CandleWeek},
+	}
+	first := time.Now().AddDate(-5, 0, 0)
+	for _, tc := range cases {
+		repo := &fakePriceRepo{first: &first}
+		series, err := NewPriceHistoryService(repo).GetCandles(context.Background(), 1, "", tc.window)
+		if err != nil {
+			t.Fatalf("%s: GetCandles: %v", tc.window.Range, err)
+		}
+		if series.Interval != tc.want || repo.interval != tc.want {
+			t.Errorf("%s: interval %q, want %q", tc.window.Range, series.Interval, tc.want)
+		}
+	}
+}
+
+func TestCandlesAllStartAtFirstPrice(t *testing.T) {
+	first := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakePriceRepo{first: &first}
+	if _, err := NewPriceHistoryService(repo).GetCandles(context.Background(), 1, models.CandleWeek, HistoryWindow{Range: "all"}); err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if !repo.from.Equal(first) {
+		t.Errorf("from = %v, want the first price at %v", repo.from, first)
+	}
+}
+
+func TestCandleWindowValidation(t *testing.T) {
+	from := time.Now().AddDate(-1, 0, 0)
+	to := from.AddDate(0, 1, 0)
+	cases := []struct {
+		name     string
+		interval string
+		window   HistoryWindow
+		want     string
+	}{
+		{"unknown interval", "minute", HistoryWindow{Range: "7d"}, "interval must be"},
+		{"too many candles", models.CandleHour, HistoryWindow{Range: "1y"}, "hour candles; at most"},
+		{"range with from", "", HistoryWindow{Range: "7d", From: &from}, "either range or from and to"},
+		{"from after to", "", HistoryWindow{From: &to, To: &from}, "from must be before to"},
+	}
+	for _, tc := range cases {
+		_, err := NewPriceHistoryService(&fakePriceRepo{}).GetCandles(context.Background(), 1, tc.interval, tc.window)
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) || appErr.Code != apperror.CodeInvalidRequest || !strings.Contains(appErr.Message, tc.want) {
+			t.Errorf("%s: err = %v, want an invalid request about %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+const islandListLimit = 10
+
+type PublicService struct {
+	UserRepo   repository.UserRepository
+	PlayerRepo repository.PlayerRepository
+	Repo       repository.PublicRepository
+}
+
+func NewPublicService(userRepo repository.UserRepository, playerRepo repository.PlayerRepository, repo repository.PublicRepository) *PublicService {
+	return &PublicService{UserRepo: userRepo, PlayerRepo: playerRepo, Repo: repo}
+}
+
+// GetProfile returns nil when the user does not exist or has opted out, so
+// private profiles are indistinguishable from missing ones.
+func (s *PublicService) GetProfile(ctx context.Context, username string) (*models.PublicProfile, error) {
+	user, err := s.UserRepo.GetByUsername(ctx, username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	privacy, err := s.UserRepo.GetPrivacy(ctx, user.ID)
+	if err != nil || privacy == nil || !privacy.ProfilePublic {
+		return nil, err
+	}
+	islands, err := s.Repo.GetIslandsOfUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PublicProfile{Username: user.Username, Islands: islands}, nil
+}
+
+func (s *PublicService) GetPlayerIsland(ctx context.Context, slug string) (*models.PlayerIsland, error) {
+	player, err := s.PlayerRepo.GetBySlug(ctx, slug)
+	if err != nil || player == nil {
+		return nil, err
+	}
+	holders, err := s.Repo.GetTopHolders(ctx, player.ID, islandListLimit)
+	if err != nil {
+		return nil, err
+	}
+	claimers, err := s.Repo.GetEarliestClaimers(ctx, player.ID, islandListLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PlayerIsland{
+		Player:           player,
+		TopHolders:       holders,
+		EarliestClaimers: claimers,
+	}, nil
+}
+
+var badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="#1d428a"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.MessageX}}" y="14">{{.Message}}</text>
+</g>
+<desc>{{.Hash}}</desc>
+</svg>
+`))
+
+type badgeView struct {
+	Label, Message, Hash string
+	Width, LabelWidth    int
+	MessageWidth         int
+	LabelX, MessageX     int
+}
+
+// badgeTextWidth approximates Verdana 11px closely enough for a badge.
+func badgeTextWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+// RenderClaimBadge returns nil when the claim does not exist or its owner's
+// profile is private.
+func (s *PublicService) RenderClaimBadge(ctx context.Context, claimID int64) ([]byte, error) {
+	b, err := s.Repo.GetClaimBadge(ctx, claimID)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	names := strings.Fields(b.PlayerName)
+	shortName := b.PlayerName
+	if len(names) > 1 {
+		shortName = names[len(names)-1]
+	}
+	message := fmt.Sprintf("Claimed %s at #%d for %.1f", shortName, b.Rank, b.EntryValue)
+
+	v := badgeView{
+		Label:        html.EscapeString(b.Username),
+		Message:      html.EscapeString(message),
+		Hash:         b.Hash,
+		LabelWidth:   badgeTextWidth(b.Username),
+		MessageWidth: badgeTextWidth(message),
+	}
+	v.Width = v.LabelWidth + v.MessageWidth
+	v.LabelX = v.LabelWidth / 2
+	v.MessageX = v.LabelWidth + v.MessageWidth/2
+
+	var buf bytes.Buffer
+	if err := badgeTemplate.Execute(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
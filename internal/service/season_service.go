@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrNoCurrentSeason = apperror.NotFound("NO_CURRENT_SEASON", "no season has been started")
+	ErrNoActiveSeason  = apperror.Conflict("NO_ACTIVE_SEASON", "no season is currently active")
+	ErrTradingClosed   = apperror.Conflict("TRADING_CLOSED", "Trading is closed until the next season starts")
+)
+
+// SeasonService drives the game season lifecycle:
+// upcoming -> active (currency granted, islands open) -> ended (trading
+// frozen, standings and positions archived), then rollover to the next NBA
+// season.
+type SeasonService struct {
+	Repo repository.SeasonRepository
+}
+
+func NewSeasonService(repo repository.SeasonRepository) *SeasonService {
+	return &SeasonService{Repo: repo}
+}
+
+func (s *SeasonService) GetCurrent(ctx context.Context) (*models.Season, error) {
+	season, err := s.Repo.GetCurrent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if season == nil {
+		return nil, ErrNoCurrentSeason
+	}
+	return season, nil
+}
+
+// CurrentNBASeason is the season string stats ingestion should use, e.g. "2025-26".
+func (s *SeasonService) CurrentNBASeason(ctx context.Context) (string, error) {
+	season, err := s.GetCurrent(ctx)
+	if err != nil {
+		return "", err
+	}
+	return season.NBASeason, nil
+}
+
+func (s *SeasonService) StartingCurrency(ctx context.Context) (float64, error) {
+	season, err := s.GetCurrent(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return season.StartingCurrency, nil
+}
+
+func (s *SeasonService) EnsureTradingOpen(ctx context.Context) error {
+	season, err := s.Repo.GetCurrent(ctx)
+	if err != nil {
+		return err
+	}
+	if season == nil || season.Status != models.SeasonActive {
+		return ErrTradingClosed
+	}
+	return nil
+}
+
+func (s *SeasonService) GetAll(ctx context.Context) ([]*models.Season, error) {
+	return s.Repo.GetAll(ctx)
+}
+
+func (s *SeasonService) GetStandings(ctx context.Context, seasonID int64) ([]*models.SeasonStanding, error) {
+	return s.Repo.GetStandings(ctx, seasonID)
+}
+
+func (s *SeasonService) Create(ctx context.Context, nbaSeason string, startingCurrency float64, islandCapacity int) (*models.Season, error) {
+	season := &models.Season{
+		NBASeason:        nbaSeason,
+		Status:           models.SeasonUpcoming,
+		StartingCurrency: startingCurrency,
+		IslandCapacity:   islandCapacity,
+	}
+	if err := s.Repo.Create(ctx, season); err != nil {
+		return nil, err
+	}
+	return season, nil
+}
+
+func (s *SeasonService) Start(ctx context.Context, id int64) error {
+	err := s.Repo.Start(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("season %d is not upcoming", id)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Season started", zap.Int64("season_id", id))
+	return nil
+}
+
+// End freezes trading in the active season and archives its results.
+func (s *SeasonService) End(ctx context.Context) (*models.Season, error) {
+	season, err := s.GetCurrent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if season.Status != models.SeasonActive {
+		return nil, ErrNoActiveSeason
+	}
+	if err := s.Repo.End(ctx, season.ID); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Season ended",
+		zap.Int64("season_id", season.ID),
+		zap.String("nba_season", season.NBASeason),
+	)
+	return s.Repo.GetByID(ctx, season.ID)
+}
+
+// Rollover ends the current season if it is still active, then creates and
+// starts the following NBA season with the same settings. It all happens in
+// one transaction, so a failure leaves the current season as it was.
+func (s *SeasonService) Rollover(ctx context.Context) (*models.Season, error) {
+	current, err := s.GetCurrent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nextName, err := NextNBASeason(current.NBASeason)
+	if err != nil {
+		return nil, err
+	}
+
+	next := &models.Season{
+		NBASeason:        nextName,
+		Status:           models.SeasonUpcoming,
+		StartingCurrency: current.StartingCurrency,
+		IslandCapacity:   current.IslandCapacity,
+	}
+	if err := s.Repo.Rollover(ctx, current.ID, next); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Season rolled over",
+		zap.Int64("from_season_id", current.ID),
+		zap.Int64("season_id", next.ID),
+		zap.String("nba_season", next.NBASeason),
+	)
+	return s.Repo.GetByID(ctx, next.ID)
+}
+
+// NextNBASeason turns "2025-26" into "2026-27".
+func NextNBASeason(season string) (string, error) {
+	if len(season) < 4 {
+		return "", fmt.Errorf("unrecognised NBA season %q", season)
+	}
+	start, err := strconv.Atoi(season[:4])
+	if err != nil {
+		return "", fmt.Errorf("unrecognised NBA season %q", season)
+	}
+	return fmt.Sprintf("%d-%02d", start+1, (start+2)%100), nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrInvalidRefreshToken = apperror.Unauthenticated("INVALID_REFRESH_TOKEN", "invalid or expired refresh token")
+	ErrRefreshTokenReused  = apperror.Unauthenticated("REFRESH_TOKEN_REUSED", "refresh token was already used; session revoked")
+)
+
+// SessionService issues access tokens alongside rotating refresh tokens. A
+// login starts a session family; each refresh spends the presented token and
+// hands out its successor. Only SHA-256 hashes of refresh tokens are stored.
+type SessionService struct {
+	Repo       repository.SessionRepository
+	Users      repository.UserRepository
+	RefreshTTL time.Duration
+}
+
+func NewSessionService(repo repository.SessionRepository, users repository.UserRepository, refreshTTL time.Duration) *SessionService {
+	return &SessionService{Repo: repo, Users: users, RefreshTTL: refreshTTL}
+}
+
+// TokenPair is what a client keeps: a short-lived access token for API calls
+// and the refresh token that buys the next pair.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Client identifies the device a session was issued to.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Start begins a new session family for user, as on login or registration.
+func (s *SessionService) Start(ctx context.Context, user *models.User, client Client) (*TokenPair, error) {
+	family := make([]byte, 16)
+	if _, err := rand.Read(family); err != nil {
+		return nil, err
+	}
+	session := &models.AuthSession{
+		FamilyID: hex.EncodeToString(family),
+		UserID:   user.ID,
+	}
+	refresh, err := s.prepare(session, client)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, session, refresh)
+}
+
+// Refresh spends a refresh token and returns the next pair in its family.
+// Presenting a token that was already spent revokes the whole family, since
+// either the client or an attacker is replaying a stolen token.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string, client Client) (*TokenPair, error) {
+	session, err := s.Repo.GetByTokenHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.UsedAt != nil {
+		return nil, s.revokeReused(ctx, session)
+	}
+
+	user, err := s.Users.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next := &models.AuthSession{
+		FamilyID: session.FamilyID,
+		UserID:   session.UserID,
+	}
+	refresh, err := s.prepare(next, client)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := s.Repo.Rotate(ctx, session, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, s.revokeReused(ctx, session)
+	}
+	return s.tokenPair(user, next, refresh)
+}
+
+func (s *SessionService) revokeReused(ctx context.Context, session *models.AuthSession) error {
+	logger.Log.Warn("Refresh token reused, revoking session family",
+		zap.Int64("user_id", session.UserID),
+		zap.String("family_id", session.FamilyID),
+	)
+	if err := s.Repo.RevokeFamily(ctx, session.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes the session family an access token belongs to.
+func (s *SessionService) Logout(ctx context.Context, familyID string) error {
+	return s.Repo.RevokeFamily(ctx, familyID)
+}
+
+// LogoutAll revokes every session the user has, on every device.
+func (s *SessionService) LogoutAll(ctx context.Context, userID int64) error {
+	return s.Repo.RevokeAllForUser(ctx, userID)
+}
+
+// IsActive reports whether access tokens carrying familyID as their sid are
+// still honoured.
+func (s *SessionService) IsActive(ctx context.Context, familyID string) (bool, error) {
+	return s.Repo.IsFamilyActive(ctx, familyID)
+}
+
+func (s *SessionService) Cleanup(ctx context.Context) error {
+	deleted, err := s.Repo.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Deleted expired sessions", zap.Int64("count", deleted))
+	return nil
+}
+
+func (s *SessionService) prepare(session *models.AuthSession, client Client) (string, error) {
+	refresh, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	session.TokenHash = hashToken(refresh)
+	session.UserAgent = client.UserAgent
+	session.IP = client.IP
+	session.ExpiresAt = time.Now().Add(s.RefreshTTL)
+	return refresh, nil
+}
+
+func (s *SessionService) tokenPair(user *models.User, session *models.AuthSession, refresh string) (*TokenPair, error) {
+	access, expiresAt, err := auth.GenerateToken(user.ID, user.Username, user.Role, session.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresAt: expiresAt}, nil
+}
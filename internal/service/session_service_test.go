@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// fakeSessionRepo keeps sessions in memory. Rotate spends old only if it is
+// still unused, as the real conditional UPDATE does; loseRace makes it act
+// as though a concurrent refresh spent it first.
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	sessions []*models.AuthSession
+	loseRace bool
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, s *models.AuthSession) error {
+	s.ID = int64(len(r.sessions) + 1)
+	r.sessions = append(r.sessions, s)
+	return nil
+}
+
+func (r *fakeSessionRepo) GetByTokenHash(ctx context.Context, hash string) (*models.AuthSession, error) {
+	for _, s := range r.sessions {
+		if s.TokenHash == hash {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepo) Rotate(ctx context.Context, old *models.AuthSession, next *models.AuthSession) (bool, error) {
+	now := time.Now()
+	for _, s := range r.sessions {
+		if s.ID == old.ID {
+			if s.UsedAt != nil || r.loseRace {
+				s.UsedAt = &now
+				return false, nil
+			}
+			s.UsedAt = &now
+		}
+	}
+	return true, r.Create(ctx, next)
+}
+
+func (r *fakeSessionRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	for _, s := range r.sessions {
+		if s.FamilyID == familyID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	now := time.Now()
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionRepo) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	for _, s := range r.sessions {
+		if s.FamilyID == familyID && s.RevokedAt == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRefreshRotation(t *testing.T) {
+	alice := &models.User{ID: 1, Username: "alice", Role: "user"}
+	client := Client{UserAgent: "test", IP: "127.0.0.1"}
+
+	cases := []struct {
+		name string
+		// steps refreshes with tokens from the pairs issued so far: 0 is
+		// the login's, 1 the first refresh's, and so on. The last step is
+		// checked against want.
+		steps   []int
+		prepare func(s *SessionService, repo *fakeSessionRepo)
+		want    error
+		revoked bool
+	}{
+		{name: "each refresh rotates", steps: []int{0, 1, 2}},
+		{name: "reused token revokes the family", steps: []int{0, 0}, want: ErrRefreshTokenReused, revoked: true},
+		{name: "successor of a reused token is dead", steps: []int{0, 0, 1}, want: ErrInvalidRefreshToken, revoked: true},
+		{
+			name:    "losing a rotation race counts as reuse",
+			steps:   []int{0},
+			prepare: func(s *SessionService, repo *fakeSessionRepo) { repo.loseRace = true },
+			want:    ErrRefreshTokenReused,
+			revoked: true,
+		},
+		{
+			name:  "expired",
+			steps: []int{0},
+			prepare: func(s *SessionService, repo *fakeSessionRepo) {
+				repo.sessions[0].ExpiresAt = time.Now().Add(-time.Second)
+			},
+			want: ErrInvalidRefreshToken,
+		},
+		{
+			name:  "logged out",
+			steps: []int{0},
+			prepare: func(s *SessionService, repo *fakeSessionRepo) {
+				s.Logout(context.Background(), repo.sessions[0].FamilyID)
+			},
+			want:    ErrInvalidRefreshToken,
+			revoked: true,
+		},
+		{
+			name:    "user deleted",
+			steps:   []int{0},
+			prepare: func(s *SessionService, repo *fakeSessionRepo) { delete(s.Users.(*fakeUserRepo).users, 1) },
+			want:    ErrInvalidRefreshToken,
+		},
+	}
+	for _, tc := range cases {
+		ctx := context.Background()
+		repo := &fakeSessionRepo{}
+		s := NewSessionService(repo, &fakeUserRepo{users: map[int64]*models.User{1: alice}}, time.Hour)
+
+		pair, err := s.Start(ctx, alice, client)
+		if err != nil {
+			t.Fatalf("%s: Start: %v", tc.name, err)
+		}
+		tokens := []string{pair.RefreshToken}
+		if tc.prepare != nil {
+			tc.prepare(s, repo)
+		}
+
+		for i, step := range tc.steps {
+			pair, err = s.Refresh(ctx, tokens[step], client)
+			if i < len(tc.steps)-1 {
+				if pair != nil {
+					tokens = append(tokens, pair.RefreshToken)
+				}
+				continue
+			}
+			if tc.want == nil {
+				if err != nil {
+					t.Errorf("%s: refresh %d: %v", tc.name, i+1, err)
+				} else if pair.RefreshToken == tokens[step] || pair.AccessToken == "" {
+					t.Errorf("%s: refresh %d did not issue a new pair", tc.name, i+1)
+				}
+			} else if !errors.Is(err, tc.want) {
+				t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+			}
+		}
+
+		active, _ := repo.IsFamilyActive(ctx, repo.sessions[0].FamilyID)
+		if active == tc.revoked {
+			t.Errorf("%s: family active = %v, want %v", tc.name, active, !tc.revoked)
+		}
+		for _, sess := range repo.sessions {
+			if sess.FamilyID != repo.sessions[0].FamilyID || sess.TokenHash == "" {
+				t.Errorf("%s: session %+v is not in the login's family", tc.name, sess)
+			}
+		}
+	}
+}
+
+func TestDemotionRevokesSessions(t *testing.T) {
+	cases := []struct {
+		name    string
+		from    string
+		to      string
+		revoked bool
+	}{
+		{"demoted admin", models.RoleAdmin, models.RoleUser, true},
+		{"promoted user", models.RoleUser, models.RoleAdmin, false},
+		{"admin stays admin", models.RoleAdmin, models.RoleAdmin, false},
+	}
+	for _, tc := range cases {
+		alice := &models.User{ID: 1, Username: "alice", Role: tc.from}
+		sessions := &fakeSessionRepo{sessions: []*models.AuthSession{{ID: 1, UserID: 1, FamilyID: "a"}}}
+		s := &UserService{Repo: &fakeUserRepo{users: map[int64]*models.User{1: alice}}, Sessions: sessions}
+
+		u, err := s.SetRole(context.Background(), "alice", tc.to)
+		if err != nil {
+			t.Fatalf("%s: SetRole: %v", tc.name, err)
+		}
+		if u.Role != tc.to {
+			t.Errorf("%s: role = %q, want %q", tc.name, u.Role, tc.to)
+		}
+		if revoked := sessions.sessions[0].RevokedAt != nil; revoked != tc.revoked {
+			t.Errorf("%s: session revoked = %v, want %v", tc.name, revoked, tc.revoked)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// streamBuffer is how many events a subscriber may fall behind by before it
+// is dropped; a client that cannot keep up reconnects rather than stalling
+// everyone else.
+const streamBuffer = 64
+
+// StreamFilter narrows a subscription. Empty fields do not narrow.
+//
+// Types picks event types. PlayerIDs limits price changes, trades and
+// dividends to those players; UserIDs limits trades to those traders. Either
+// ID filter also opens up trades on the global islands by anyone, which are
+// otherwise only sent for islands the subscriber holds.
+type StreamFilter struct {
+	Types     []string
+	PlayerIDs []int64
+	UserIDs   []int64
+}
+
+type island struct {
+	leagueID int64
+	playerID int64
+}
+
+// Subscription receives market events on C until Close. C is closed early
+// if the subscriber falls behind; Dropped then reports true.
+type Subscription struct {
+	C <-chan *models.MarketEvent
+
+	ch      chan *models.MarketEvent
+	userID  int64
+	filter  StreamFilter
+	held    map[island]bool
+	dropped bool
+	service *StreamService
+}
+
+// StreamService fans market events out to subscribers on this replica. The
+// events arrive over Postgres LISTEN, so a change made through any replica,
+// or by a database trigger, reaches every subscriber.
+type StreamService struct {
+	Events    repository.EventRepository
+	Positions repository.TransactionRepository
+
+	mu       sync.Mutex
+	subs     map[*Subscription]struct{}
+	watchers map[string][]func(*models.MarketEvent)
+}
+
+func NewStreamService(events repository.EventRepository, positions repository.TransactionRepository) *StreamService {
+	return &StreamService{
+		Events:    events,
+		Positions: positions,
+		subs:      make(map[*Subscription]struct{}),
+		watchers:  make(map[string][]func(*models.MarketEvent)),
+	}
+}
+
+// Run relays events from Postgres to subscribers until ctx ends, listening
+// again with backoff whenever the connection drops.
+func (s *StreamService) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := s.Events.Listen(ctx, func() {
+			backoff = time.Second
+			logger.Log.Info("Listening for market events")
+		}, s.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Log.Warn("Market event listener stopped, retrying",
+			zap.Error(err),
+			zap.Duration("backoff", backoff),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// Watch calls fn with every event of type eventType heard on this replica.
+// Unlike subscribers, watchers are never dropped, so fn must not block.
+func (s *StreamService) Watch(eventType string, fn func(*models.MarketEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[eventType] = append(s.watchers[eventType], fn)
+}
+
+// Publish sends e to subscribers on every replica and queues it for the
+// webhooks subscribed to it.
+func (s *StreamService) Publish(ctx context.Context, e *models.MarketEvent) error {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	return s.Events.Publish(ctx, e)
+}
+
+// Subscribe starts a subscription for userID. Trades on the islands the
+// user holds now, or buys into later, are included.
+func (s *StreamService) Subscribe(ctx context.Context, userID int64, filter StreamFilter) (*Subscription, error) {
+	held, err := s.heldIslands(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *models.MarketEvent, streamBuffer)
+	sub := &Subscription{C: ch, ch: ch, userID: userID, filter: filter, held: held, service: s}
+
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	return sub, nil
+}
+
+func (s *StreamService) heldIslands(ctx context.Context, userID int64) (map[island]bool, error) {
+	held := make(map[island]bool)
+	req := models.PageRequest{Limit: 200, Sort: models.PositionSortQuantity}
+	for {
+		page, err := s.Positions.ListPositions(ctx, models.PositionFilter{UserID: userID}, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Items {
+			held[island{leagueID: p.LeagueID, playerID: p.AssetID}] = true
+		}
+		if page.NextCursor == "" {
+			return held, nil
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (sub *Subscription) Close() {
+	s := sub.service
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[sub]; ok {
+		delete(s.subs, sub)
+		close(sub.ch)
+	}
+}
+
+func (sub *Subscription) Dropped() bool {
+	s := sub.service
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sub.dropped
+}
+
+func (s *StreamService) deliver(e *models.MarketEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fn := range s.watchers[e.Type] {
+		fn(e)
+	}
+	for sub := range s.subs {
+		if !sub.wants(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			logger.Log.Warn("Dropping slow stream subscriber", zap.Int64("user_id", sub.userID))
+			sub.dropped = true
+			delete(s.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// wants reports whether e goes to sub. It runs under the service lock.
+func (sub *Subscription) wants(e *models.MarketEvent) bool {
+	if e.Type == models.EventTradeExecuted && e.UserID == sub.userID {
+		sub.held[island{leagueID: e.LeagueID, playerID: e.PlayerID}] = true
+	}
+
+	f := sub.filter
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.PlayerIDs) > 0 && !slices.Contains(f.PlayerIDs, e.PlayerID) {
+		return false
+	}
+
+	switch e.Type {
+	case models.EventPriceChanged:
+		return true
+	case models.EventDividendPaid:
+		return e.UserID == sub.userID
+	case models.EventTradeExecuted:
+		if len(f.UserIDs) > 0 && !slices.Contains(f.UserIDs, e.UserID) {
+			return false
+		}
+		if e.UserID == sub.userID || sub.held[island{leagueID: e.LeagueID, playerID: e.PlayerID}] {
+			return true
+		}
+		return e.LeagueID == 0 && (len(f.PlayerIDs) > 0 || len(f.UserIDs) > 0)
+	}
+	return false
+}
@@ -2,32 +2,36 @@ package service
 
 import ( 
 	"context"
+	"errors"
 	"fmt"
 	"time"
+	"go.uber.org/zap"
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
 	"github.com/nbaisland/nbaisland/internal/models"
     "github.com/nbaisland/nbaisland/internal/repository"
 )
-type TransactionError struct {
-	Code string
-	Msg string
-}
-
-func (e *TransactionError) Error() string {
-    return fmt.Sprintf("%s: %s", e.Code, e.Msg)
-}
-
 type TransactionService struct {
 	TransactionRepo repository.TransactionRepository
 	PlayerRepo repository.PlayerRepository
 	UserRepo repository.UserRepository
+	Fees FeeSchedule
+	Holding HoldingRules
+	Claims *ClaimService
+	Seasons *SeasonService
+	Leagues repository.LeagueRepository
+	Drafts *DraftService
+	// RequireVerifiedEmail blocks trading until the user confirms their email.
+	RequireVerifiedEmail bool
 }
 
 func NewTransactionService(transactionRepo repository.TransactionRepository, playerRepo repository.PlayerRepository, userRepo repository.UserRepository) *TransactionService {
-	return &TransactionService{TransactionRepo: transactionRepo, PlayerRepo: playerRepo, UserRepo: userRepo}
+	return &TransactionService{TransactionRepo: transactionRepo, PlayerRepo: playerRepo, UserRepo: userRepo, Fees: DefaultFeeSchedule(), Holding: DefaultHoldingRules()}
 }
 
-func (s *TransactionService) GetAll(ctx context.Context) ([]*models.Transaction, error){
-	return s.TransactionRepo.GetAll(ctx)
+func (s *TransactionService) List(ctx context.Context, filter models.TransactionFilter, req models.PageRequest) (*models.Page[*models.Transaction], error) {
+	page, err := s.TransactionRepo.List(ctx, filter, req)
+	return page, listError(err)
 }
 
 func (s *TransactionService) GetTransactionByID(ctx context.Context, id int64) (*models.Transaction, error){
@@ -45,127 +49,308 @@ func (s *TransactionService) GetByPlayerID(ctx context.Context, id int64) ([]*mo
 	return t, err
 }
 
-func (s *TransactionService) Buy(ctx context.Context, userID int64, playerID int64, quantity int) (error) {
+func (s *TransactionService) Buy(ctx context.Context, userID int64, playerID int64, quantity int, leagueID int64) (*models.Transaction, error) {
+	if quantity <= 0 {
+		return nil, apperror.Invalid("QUANTITY_INVALID", "Must provide a number greater than 0 to buy")
+	}
+	if err := s.ensureTradingOpen(ctx); err != nil {
+		return nil, err
+	}
 	userDetail, err := s.UserRepo.GetByID(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if userDetail == nil {
-		return &TransactionError{
-			Code: "UserNotFound",
-			Msg: "Could not find user",
-		}
+		return nil, apperror.NotFound("USER_NOT_FOUND", "Could not find user")
+	}
+	if s.RequireVerifiedEmail && !userDetail.EmailVerified {
+		return nil, apperror.Forbidden("EMAIL_NOT_VERIFIED", "Verify your email address before trading")
 	}
 	playerDetail, err := s.PlayerRepo.GetByID(ctx, playerID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if playerDetail == nil {
-		return &TransactionError{
-			Code: "PlayerNotFound",
-			Msg: "Could not find player",
-		}
+		return nil, apperror.NotFound("PLAYER_NOT_FOUND", "Could not find player")
+	}
+	account, err := s.tradingAccount(ctx, userDetail, playerDetail, leagueID)
+	if err != nil {
+		return nil, err
 	}
 	cost := float64(playerDetail.Value) * float64(quantity)
-	if cost > userDetail.Currency {
-		return &TransactionError{
-			Code: "USER_LACKS_MONEY",
-			Msg: fmt.Sprintf("This trade would cost %v, user only has %v", cost, userDetail.Currency),
-		}
+	fee := s.Fees.BuyFee(cost)
+	if cost+fee > account.Currency {
+		return nil, apperror.Invalid("USER_LACKS_MONEY", fmt.Sprintf("This trade would cost %v (including %v fee), user only has %v", cost+fee, fee, account.Currency))
 	}
-	if quantity > playerDetail.Capacity {
-		return &TransactionError{
-			Code: "NO_CAPACITY",
-			Msg: fmt.Sprintf("Player only has %v capacity remaining, exceeding %v requested", playerDetail.Capacity, quantity),
-		}
+	noCapacity := apperror.Invalid("NO_CAPACITY", fmt.Sprintf("Player only has %v capacity remaining, exceeding %v requested", account.Capacity, quantity))
+	if quantity > account.Capacity {
+		return nil, noCapacity
 	}
-	// TODO: Check for capacity or something
 	buyT := &models.Transaction{
 		UserID:   userID,
         AssetID:  playerID,
         Type:     "BUY",
         Quantity: quantity,
         Price:    playerDetail.Value,
+        Fee:      fee,
+        LeagueID: leagueID,
         Timestamp: time.Now(),
 	}
-	err = s.TransactionRepo.CreateTransaction(ctx, buyT)
-	if err != nil {
-		return err
+	// The buy's claim, if it earns one, is chained in the same transaction.
+	var seal func(*models.Claim)
+	if s.Claims != nil {
+		seal = sealClaim
 	}
-	newCurrencyValue := userDetail.Currency - cost
-	err = s.UserRepo.UpdateCurrency(ctx, userID, newCurrencyValue)
-	if err != nil {
-		return err
+	_, err = s.TransactionRepo.ExecuteTrade(ctx, buyT, seal)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		return nil, apperror.Invalid("USER_LACKS_MONEY", fmt.Sprintf("This trade would cost %v (including %v fee), more than the user has", cost+fee, fee))
+	}
+	if errors.Is(err, repository.ErrNoCapacity) {
+		return nil, noCapacity
+	}
+	if errors.Is(err, repository.ErrTradingClosed) {
+		return nil, ErrTradingClosed
 	}
-    err = s.TransactionRepo.RefreshPositionsMV(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	s.refreshPositions(ctx, buyT)
+	return buyT, nil
 }
 
-func (s *TransactionService) Sell(ctx context.Context, userID int64, playerID int64, quantity int) (float64, error) {
+// Sell returns the recorded transaction; the user is credited
+// Quantity*Price less Fee.
+func (s *TransactionService) Sell(ctx context.Context, userID int64, playerID int64, quantity int, leagueID int64) (*models.Transaction, error) {
     if quantity <= 0 {
-        return 0, &TransactionError{
-            Code: "QUANTITY_INVALID",
-            Msg:  "Must provide a number greater than 0 to sell",
-        }
+        return nil, apperror.Invalid("QUANTITY_INVALID", "Must provide a number greater than 0 to sell")
     }
+	if err := s.ensureTradingOpen(ctx); err != nil {
+		return nil, err
+	}
+	userDetail, err := s.UserRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if userDetail == nil {
+		return nil, apperror.NotFound("USER_NOT_FOUND", "Could not find user")
+	}
+	if s.RequireVerifiedEmail && !userDetail.EmailVerified {
+		return nil, apperror.Forbidden("EMAIL_NOT_VERIFIED", "Verify your email address before trading")
+	}
     playerDetail, err := s.PlayerRepo.GetByID(ctx, playerID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-    position, err := s.TransactionRepo.GetPositionsByUserIDAndPlayerID(ctx, userID, playerID)
+	if playerDetail == nil {
+		return nil, apperror.NotFound("PLAYER_NOT_FOUND", "Could not find player")
+	}
+	// The wallet is checked when the trade is booked; this only checks the
+	// user may trade in leagueID.
+	if _, err := s.tradingAccount(ctx, userDetail, playerDetail, leagueID); err != nil {
+		return nil, err
+	}
+    position, err := s.TransactionRepo.GetPositionsByUserIDAndPlayerID(ctx, userID, playerID, leagueID)
     if err != nil {
-        return 0, err
+        return nil, err
     }
     if position == nil {
-        return 0, &TransactionError{
-            Code: "NO_POSITION",
-            Msg:  "Could not find position",
-        }
+        return nil, apperror.Invalid("NO_POSITION", "Could not find position")
     }
     if position.Quantity < quantity {
-        return 0, &TransactionError{
-            Code: "QUANTITY_EXCEEDS_POSITION",
-            Msg:  fmt.Sprintf("Request to sell %v exceeds held position (%v)", quantity, position.Quantity),
-        }
+        return nil, apperror.Invalid("QUANTITY_EXCEEDS_POSITION", fmt.Sprintf("Request to sell %v exceeds held position (%v)", quantity, position.Quantity))
+    }
+    now := time.Now()
+    newestAcquired, err := s.checkHoldPeriod(ctx, userID, playerID, leagueID, quantity, now)
+    if err != nil {
+        return nil, err
     }
     totalValue := float64(quantity) * playerDetail.Value
+    fee := s.Fees.SellFee(totalValue, newestAcquired, now)
 	sellT := &models.Transaction{
         UserID:   userID,
         AssetID:  playerID,
         Type:     "SELL",
         Quantity: quantity,
         Price:    playerDetail.Value,
-        Timestamp: time.Now(),
+        Fee:      fee,
+        LeagueID: leagueID,
+        Timestamp: now,
     }
 
-    err = s.TransactionRepo.CreateTransaction(ctx, sellT)
-    if err != nil {
-        return 0, err
-    }
-	userDetail, err := s.UserRepo.GetByID(ctx, userID)
-	newCurrencyValue := userDetail.Currency + totalValue
-	s.UserRepo.UpdateCurrency(ctx, userID, newCurrencyValue)
-	// TODO: Update capacity... 
-	s.PlayerRepo.UpdateCapacity(ctx, playerID, quantity)
-    err = s.TransactionRepo.RefreshPositionsMV(ctx)
+    _, err = s.TransactionRepo.ExecuteTrade(ctx, sellT, nil)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		return nil, apperror.Invalid("USER_LACKS_MONEY", fmt.Sprintf("The %v fee exceeds the sale and what the user has", fee))
+	}
+	if errors.Is(err, repository.ErrInsufficientShares) {
+		return nil, apperror.Invalid("QUANTITY_EXCEEDS_POSITION", fmt.Sprintf("Request to sell %v exceeds held position", quantity))
+	}
+	if errors.Is(err, repository.ErrTradingClosed) {
+		return nil, ErrTradingClosed
+	}
 	if err != nil {
-		return totalValue, err
+		return nil, err
 	}
-    return totalValue, nil
+	s.refreshPositions(ctx, sellT)
+    return sellT, nil
 }
 
+// refreshPositions brings positions_mv up to date after t. t is committed by
+// then, so a failure is logged rather than failing the trade; the next
+// refresh catches up.
+func (s *TransactionService) refreshPositions(ctx context.Context, t *models.Transaction) {
+	if err := s.TransactionRepo.RefreshPositionsMV(ctx); err != nil {
+		logger.Log.Error("Failed to refresh positions after trade",
+			zap.Int64("transaction_id", t.ID),
+			zap.Error(err),
+		)
+	}
+}
 
-func (s *TransactionService) GetPositions(ctx context.Context) ([]*models.Position, error){
-	return s.TransactionRepo.GetAllPositions(ctx)
+// wallet is the cash and island capacity a trade draws on: the user's
+// global ones, or their league wallet and the league's islands.
+type wallet struct {
+	Currency float64
+	Capacity int
 }
 
-func (s *TransactionService) GetPositionsByUserID(ctx context.Context, id int64) ([]*models.Position, error){
-	return s.TransactionRepo.GetPositionsByUserID(ctx, id)
+func (s *TransactionService) tradingAccount(ctx context.Context, user *models.User, player *models.Player, leagueID int64) (*wallet, error) {
+	if leagueID == 0 {
+		return &wallet{Currency: user.Currency, Capacity: player.Capacity}, nil
+	}
+	if s.Leagues == nil {
+		return nil, apperror.NotFound("LEAGUE_NOT_FOUND", "Could not find league")
+	}
+	league, err := s.Leagues.GetByID(ctx, leagueID)
+	if err != nil {
+		return nil, err
+	}
+	if league == nil {
+		return nil, apperror.NotFound("LEAGUE_NOT_FOUND", "Could not find league")
+	}
+	member, err := s.Leagues.GetMember(ctx, leagueID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, apperror.Forbidden("NOT_LEAGUE_MEMBER", "User is not a member of this league")
+	}
+	if !league.Contained() || member.Currency == nil {
+		return nil, apperror.Invalid("LEAGUE_NOT_CONTAINED", "This league ranks members on their global islands; trade without a league_id")
+	}
+	if s.Drafts != nil {
+		drafting, err := s.Drafts.InProgress(ctx, leagueID)
+		if err != nil {
+			return nil, err
+		}
+		if drafting {
+			return nil, apperror.Conflict("DRAFT_IN_PROGRESS", "League islands cannot be traded while the league is drafting")
+		}
+	}
+	held, err := s.Leagues.GetHeldQuantity(ctx, leagueID, player.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet{Currency: *member.Currency, Capacity: *league.IslandCapacity - held}, nil
+}
+
+// EnsureLeagueVisible lets userID read the trades and positions booked in
+// leagueID. The global islands (0) are public and admins see every league;
+// anyone else must be a member, and is told ErrLeagueNotFound otherwise,
+// just as LeagueService does.
+func (s *TransactionService) EnsureLeagueVisible(ctx context.Context, leagueID int64, userID int64, admin bool) error {
+	if leagueID == 0 || admin {
+		return nil
+	}
+	if s.Leagues == nil {
+		return ErrLeagueNotFound
+	}
+	member, err := s.Leagues.GetMember(ctx, leagueID, userID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrLeagueNotFound
+	}
+	return nil
+}
+
+// ensureTradingOpen fails fast before a trade is priced. ExecuteTrade checks
+// the season again under lock, so a season ending meanwhile still stops it.
+func (s *TransactionService) ensureTradingOpen(ctx context.Context) error {
+	if s.Seasons == nil {
+		return nil
+	}
+	return s.Seasons.EnsureTradingOpen(ctx)
 }
 
-func (s *TransactionService) GetPositionsByPlayerID(ctx context.Context, id int64) ([]*models.Position, error){
-	return s.TransactionRepo.GetPositionsByPlayerID(ctx, id)
-}
\ No newline at end of file
+// checkHoldPeriod enforces the minimum hold on the shares a sell would
+// consume and returns when the newest of those shares was acquired.
+func (s *TransactionService) checkHoldPeriod(ctx context.Context, userID int64, playerID int64, leagueID int64, quantity int, now time.Time) (time.Time, error) {
+	if !s.Holding.FIFOLots {
+		lastBuy, err := s.TransactionRepo.GetLastBuyTime(ctx, userID, playerID, leagueID)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if unlocksAt := lastBuy.Add(s.Holding.MinHoldPeriod); !lastBuy.IsZero() && now.Before(unlocksAt) {
+			return time.Time{}, holdPeriodError(unlocksAt)
+		}
+		return lastBuy, nil
+	}
+
+	transactions, err := s.TransactionRepo.GetByUserIDAndPlayerID(ctx, userID, playerID, leagueID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest, unlocksAt time.Time
+	for _, l := range consumeLots(BuildLots(transactions, s.Holding.MinHoldPeriod), quantity) {
+		if l.AcquiredAt.After(newest) {
+			newest = l.AcquiredAt
+		}
+		if l.UnlocksAt.After(unlocksAt) {
+			unlocksAt = l.UnlocksAt
+		}
+	}
+	if now.Before(unlocksAt) {
+		return time.Time{}, holdPeriodError(unlocksAt)
+	}
+	return newest, nil
+}
+
+func (s *TransactionService) GetEconomySummary(ctx context.Context) (*models.EconomySummary, error) {
+	return s.TransactionRepo.GetEconomySummary(ctx)
+}
+
+
+// ListPositions returns a page of positions. When the filter pins a user or
+// a player to one league, each position carries its lots.
+func (s *TransactionService) ListPositions(ctx context.Context, filter models.PositionFilter, req models.PageRequest) (*models.Page[*models.Position], error) {
+	page, err := s.TransactionRepo.ListPositions(ctx, filter, req)
+	if err != nil {
+		return nil, listError(err)
+	}
+	if !s.Holding.FIFOLots || filter.LeagueID == nil || len(page.Items) == 0 {
+		return page, nil
+	}
+
+	switch {
+	case filter.UserID != 0:
+		transactions, err := s.TransactionRepo.GetByUserID(ctx, filter.UserID)
+		if err != nil {
+			return nil, err
+		}
+		byPlayer := groupTransactionsByPlayer(inLeague(transactions, *filter.LeagueID))
+		for _, p := range page.Items {
+			p.Lots = BuildLots(latestSeasonOnly(byPlayer[p.AssetID]), s.Holding.MinHoldPeriod)
+		}
+	case filter.PlayerID != 0:
+		transactions, err := s.TransactionRepo.GetByPlayerID(ctx, filter.PlayerID)
+		if err != nil {
+			return nil, err
+		}
+		byUser := groupTransactionsByUser(inLeague(transactions, *filter.LeagueID))
+		for _, p := range page.Items {
+			p.Lots = BuildLots(latestSeasonOnly(byUser[p.UserID]), s.Holding.MinHoldPeriod)
+		}
+	}
+	return page, nil
+}
@@ -2,13 +2,24 @@ package service
 
 import ( 
 	"context"
+	"errors"
 	"log"
+	"strings"
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/auth"
 	"github.com/nbaisland/nbaisland/internal/models"
     "github.com/nbaisland/nbaisland/internal/repository"
 )
 
+var (
+	ErrUserNotFound = apperror.NotFound("USER_NOT_FOUND", "user not found")
+	ErrInvalidRole  = apperror.Invalid("INVALID_ROLE", "unknown role")
+)
+
 type UserService struct {
 	Repo repository.UserRepository
+	// Sessions, when set, lets SetRole sign a demoted user out everywhere.
+	Sessions repository.SessionRepository
 }
 
 func NewUserService(repo repository.UserRepository) *UserService {
@@ -19,6 +30,11 @@ func(s *UserService) GetAll(ctx context.Context) ([]*models.User, error) {
 	return s.Repo.GetAll(ctx)
 }
 
+func(s *UserService) List(ctx context.Context, filter models.UserFilter, req models.PageRequest) (*models.Page[*models.User], error) {
+	page, err := s.Repo.List(ctx, filter, req)
+	return page, listError(err)
+}
+
 func(s *UserService) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	return s.Repo.GetByID(ctx, id)
 }
@@ -35,6 +51,110 @@ func(s *UserService) CreateUser(ctx context.Context, u *models.User) (error) {
 	return err
 }
 
+type RegisterInput struct {
+	Username string
+	Password string
+	Email    string
+	Name     string
+}
+
+// Register validates a sign-up and creates the account with startingCurrency.
+// Invalid or already-taken fields come back together as a *ValidationError.
+func(s *UserService) Register(ctx context.Context, in RegisterInput, startingCurrency float64) (*models.User, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	v := &ValidationError{}
+	validateUsername(v, in.Username)
+	validateEmail(v, in.Email)
+	validatePassword(v, in.Password, in.Username)
+	validateName(v, in.Name)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Repo.GetByUsername(ctx, in.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		v.add("username", "already taken")
+	}
+	existing, err = s.Repo.GetByEmail(ctx, in.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		v.add("email", "already registered")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	hashed, err := auth.HashPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Username: in.Username,
+		Name:     in.Name,
+		Password: hashed,
+		Email:    in.Email,
+		Currency: startingCurrency,
+	}
+
+	// The checks above can race another sign-up; the constraints decide.
+	err = s.Repo.Create(ctx, user)
+	switch {
+	case errors.Is(err, repository.ErrUsernameTaken):
+		v.add("username", "already taken")
+	case errors.Is(err, repository.ErrEmailTaken):
+		v.add("email", "already registered")
+	case errors.Is(err, repository.ErrInvalidUsername):
+		v.add("username", "must be 3-20 lowercase letters, digits or underscores")
+	case err != nil:
+		return nil, err
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func(s *UserService) GetPrivacy(ctx context.Context, id int64) (*models.PrivacySettings, error) {
+	return s.Repo.GetPrivacy(ctx, id)
+}
+
+func(s *UserService) UpdatePrivacy(ctx context.Context, id int64, settings *models.PrivacySettings) error {
+	return s.Repo.UpdatePrivacy(ctx, id, settings)
+}
+
+// SetRole changes a user's role. Access tokens carry the role, so a
+// promotion takes effect on the user's next token refresh. A demotion
+// revokes every session the user has, which the auth middleware checks on
+// each request, so admin access ends right away.
+func(s *UserService) SetRole(ctx context.Context, username string, role string) (*models.User, error) {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	user, err := s.Repo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	demoted := user.Role == models.RoleAdmin && role != models.RoleAdmin
+	if err := s.Repo.UpdateRole(ctx, user.ID, role); err != nil {
+		return nil, err
+	}
+	if demoted && s.Sessions != nil {
+		if err := s.Sessions.RevokeAllForUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	user.Role = role
+	return user, nil
+}
+
 func(s *UserService) DeleteUser(ctx context.Context, id int64) error {
 	return s.Repo.Delete(ctx, id)
 }
\ No newline at end of file
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+)
+
+// ValidationError collects a message per invalid input field.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + e.Fields[name]
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field string, msg string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	if _, ok := e.Fields[field]; !ok {
+		e.Fields[field] = msg
+	}
+}
+
+// err returns e as a VALIDATION_FAILED error when any field failed, so
+// callers can return it directly.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return apperror.Validation(e.Fields).Wrap(e)
+}
+
+// usernamePattern mirrors the username_chars and username_length constraints.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,20}$`)
+
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"auth":          true,
+	"me":            true,
+	"moderator":     true,
+	"nbaisland":     true,
+	"null":          true,
+	"root":          true,
+	"staff":         true,
+	"support":       true,
+	"system":        true,
+	"undefined":     true,
+}
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordBytes = 72
+	maxEmailLength   = 100
+	maxNameLength    = 50
+)
+
+func validateUsername(v *ValidationError, username string) {
+	switch {
+	case username == "":
+		v.add("username", "required")
+	case !usernamePattern.MatchString(username):
+		v.add("username", "must be 3-20 lowercase letters, digits or underscores")
+	case reservedUsernames[username]:
+		v.add("username", "is reserved")
+	}
+}
+
+func validateEmail(v *ValidationError, email string) {
+	if email == "" {
+		v.add("email", "required")
+		return
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		v.add("email", "must be a valid email address")
+		return
+	}
+	if len(email) > maxEmailLength {
+		v.add("email", "must be at most 100 characters")
+	}
+}
+
+// validatePassword enforces the password policy: 8-72 bytes with at least one
+// letter and one digit, and not the username.
+func validatePassword(v *ValidationError, password string, username string) {
+	if password == "" {
+		v.add("password", "required")
+		return
+	}
+	if len(password) < minPasswordLength {
+		v.add("password", "must be at least 8 characters")
+		return
+	}
+	if len(password) > maxPasswordBytes {
+		v.add("password", "must be at most 72 bytes")
+		return
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		v.add("password", "must contain a letter and a digit")
+		return
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		v.add("password", "must not be your username")
+	}
+}
+
+func validateName(v *ValidationError, name string) {
+	if len([]rune(name)) > maxNameLength {
+		v.add("name", "must be at most 50 characters")
+	}
+}
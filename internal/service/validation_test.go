@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// fieldError returns the message err gives for field, or "" when err is not
+// a ValidationError or does not flag field.
+func fieldError(err error, field string) string {
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		return ""
+	}
+	return v.Fields[field]
+}
+
+func TestRegistrationValidators(t *testing.T) {
+	cases := []struct {
+		name     string
+		validate func(v *ValidationError)
+		field    string
+		want     string
+	}{
+		{"username ok", func(v *ValidationError) { validateUsername(v, "jalen_11") }, "username", ""},
+		{"username missing", func(v *ValidationError) { validateUsername(v, "") }, "username", "required"},
+		{"username too short", func(v *ValidationError) { validateUsername(v, "jb") }, "username", "must be 3-20 lowercase letters, digits or underscores"},
+		{"username too long", func(v *ValidationError) { validateUsername(v, strings.Repeat("a", 21)) }, "username", "must be 3-20 lowercase letters, digits or underscores"},
+		{"username uppercase", func(v *ValidationError) { validateUsername(v, "Jalen") }, "username", "must be 3-20 lowercase letters, digits or underscores"},
+		{"username reserved", func(v *ValidationError) { validateUsername(v, "admin") }, "username", "is reserved"},
+
+		{"email ok", func(v *ValidationError) { validateEmail(v, "fan@example.com") }, "email", ""},
+		{"email missing", func(v *ValidationError) { validateEmail(v, "") }, "email", "required"},
+		{"email with display name", func(v *ValidationError) { validateEmail(v, "Fan <fan@example.com>") }, "email", "must be a valid email address"},
+		{"email without dotted domain", func(v *ValidationError) { validateEmail(v, "fan@localhost") }, "email", "must be a valid email address"},
+		{"email not an address", func(v *ValidationError) { validateEmail(v, "fan.example.com") }, "email", "must be a valid email address"},
+		{"email too long", func(v *ValidationError) { validateEmail(v, strings.Repeat("a", 90)+"@example.com") }, "email", "must be at most 100 characters"},
+
+		{"password ok", func(v *ValidationError) { validatePassword(v, "hoops2025", "jalen") }, "password", ""},
+		{"password missing", func(v *ValidationError) { validatePassword(v, "", "jalen") }, "password", "required"},
+		{"password too short", func(v *ValidationError) { validatePassword(v, "abc123", "jalen") }, "password", "must be at least 8 characters"},
+		{"password past bcrypt's limit", func(v *ValidationError) { validatePassword(v, strings.Repeat("a1", 37), "jalen") }, "password", "must be at most 72 bytes"},
+		{"password without digit", func(v *ValidationError) { validatePassword(v, "hoopshoops", "jalen") }, "password", "must contain a letter and a digit"},
+		{"password without letter", func(v *ValidationError) { validatePassword(v, "12345678", "jalen") }, "password", "must contain a letter and a digit"},
+		{"password is username", func(v *ValidationError) { validatePassword(v, "Jalen_11", "jalen_11") }, "password", "must not be your username"},
+
+		{"name ok", func(v *ValidationError) { validateName(v, strings.Repeat("é", 50)) }, "name", ""},
+		{"name too long", func(v *ValidationError) { validateName(v, strings.Repeat("é", 51)) }, "name", "must be at most 50 characters"},
+	}
+	for _, tc := range cases {
+		v := &ValidationError{}
+		tc.validate(v)
+		if got := v.Fields[tc.field]; got != tc.want {
+			t.Errorf("%s: %s = %q, want %q", tc.name, tc.field, got, tc.want)
+		}
+		if len(v.Fields) > 1 {
+			t.Errorf("%s: flagged %v, want only %s", tc.name, v.Fields, tc.field)
+		}
+	}
+}
+
+func TestValidationErrorKeepsFirstMessagePerField(t *testing.T) {
+	v := &ValidationError{}
+	if v.err() != nil {
+		t.Fatal("err() with no failures is not nil")
+	}
+	v.add("username", "required")
+	v.add("username", "already taken")
+	v.add("email", "already registered")
+
+	err := v.err()
+	var appErr *apperror.Error
+	var fields *ValidationError
+	if !errors.As(err, &appErr) || !errors.As(err, &fields) {
+		t.Fatalf("err() = %v, want an apperror wrapping the ValidationError", err)
+	}
+	if got, want := fields.Error(), "validation failed: email: already registered; username: required"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterReportsTakenFields(t *testing.T) {
+	taken := &models.User{ID: 1, Username: "jalen", Email: "jalen@example.com"}
+	valid := RegisterInput{Username: "brunson", Email: "brunson@example.com", Password: "hoops2025"}
+	cases := []struct {
+		name      string
+		in        RegisterInput
+		createErr error
+		want      map[string]string
+	}{
+		{"ok", valid, nil, nil},
+		{
+			"both taken",
+			RegisterInput{Username: "jalen", Email: "jalen@example.com", Password: "hoops2025"},
+			nil,
+			map[string]string{"username": "already taken", "email": "already registered"},
+		},
+		{"username lost to a race", valid, repository.ErrUsernameTaken, map[string]string{"username": "already taken"}},
+		{"email lost to a race", valid, repository.ErrEmailTaken, map[string]string{"email": "already registered"}},
+		{
+			"invalid fields stop before lookups",
+			RegisterInput{Username: "jalen", Email: "nope", Password: "short"},
+			nil,
+			map[string]string{"email": "must be a valid email address", "password": "must be at least 8 characters"},
+		},
+	}
+	for _, tc := range cases {
+		users := &fakeUserRepo{users: map[int64]*models.User{1: taken}, createErr: tc.createErr}
+		s := NewUserService(users)
+		user, err := s.Register(context.Background(), tc.in, 1000)
+
+		if tc.want == nil {
+			if err != nil || user == nil || user.Currency != 1000 || user.Password == tc.in.Password {
+				t.Errorf("%s: user = %+v, err = %v", tc.name, user, err)
+			}
+			continue
+		}
+		var v *ValidationError
+		if !errors.As(err, &v) {
+			t.Errorf("%s: err = %v, want a ValidationError", tc.name, err)
+			continue
+		}
+		if len(v.Fields) != len(tc.want) {
+			t.Errorf("%s: fields = %v, want %v", tc.name, v.Fields, tc.want)
+		}
+		for field, msg := range tc.want {
+			if v.Fields[field] != msg {
+				t.Errorf("%s: %s = %q, want %q", tc.name, field, v.Fields[field], msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nbaisland/nbaisland/internal/apperror"
+	"github.com/nbaisland/nbaisland/internal/logger"
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+var (
+	ErrWebhookNotFound = apperror.NotFound("WEBHOOK_NOT_FOUND", "webhook not found")
+	ErrTooManyWebhooks = apperror.Conflict("TOO_MANY_WEBHOOKS", "too many webhooks; delete one first")
+)
+
+// Headers sent with every delivery. The signature header reads
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the secret>",
+// so receivers can reject both forged and replayed requests.
+const (
+	WebhookEventHeader     = "X-NBAIsland-Event"
+	WebhookDeliveryHeader  = "X-NBAIsland-Delivery"
+	WebhookSignatureHeader = "X-NBAIsland-Signature"
+)
+
+// WebhookEventTypes are the events a webhook may subscribe to.
+var WebhookEventTypes = []string{
+	models.EventTradeExecuted,
+	models.EventPriceChanged,
+	models.EventDividendPaid,
+	models.EventIngestionFailed,
+}
+
+// WebhookService manages webhook subscriptions and sends their queued
+// deliveries. Deliveries are queued in Postgres by the same statement that
+// publishes the event, so each is sent by exactly one replica.
+type WebhookService struct {
+	Repo   repository.WebhookRepository
+	Client *http.Client
+
+	// RequireHTTPS rejects plain http URLs; production sets it.
+	RequireHTTPS bool
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses, for trying receivers out locally. Otherwise the
+	// client refuses to connect to them, whatever a host resolves to at the
+	// time.
+	AllowPrivateNetworks bool
+	MaxWebhooksPerUser   int
+
+	// A failed delivery is retried after RetryBase, doubling each time up to
+	// RetryMax, until it has been attempted MaxAttempts times.
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+
+	BatchSize   int
+	Concurrency int
+}
+
+func NewWebhookService(repo repository.WebhookRepository) *WebhookService {
+	s := &WebhookService{
+		Repo:               repo,
+		MaxWebhooksPerUser: 5,
+		MaxAttempts:        8,
+		RetryBase:          30 * time.Second,
+		RetryMax:           time.Hour,
+		BatchSize:          50,
+		Concurrency:        8,
+	}
+	// The address is checked as the connection is made rather than when the
+	// URL is saved, so a host cannot later be pointed at an internal one.
+	// Proxies are not used; they would dial on the client's behalf.
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return s.checkDialAddress(address)
+		},
+	}
+	s.Client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// A redirect could point the signed payload anywhere; receivers
+		// must give their final URL.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+var errBlockedAddress = errors.New("webhook address is not publicly routable")
+
+// blockedPrefixes are the ranges a webhook may not reach besides loopback,
+// private, link-local, multicast and unspecified addresses: shared address
+// space, the IPv4 "this network" block and IPv6 site-local addresses.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("fec0::/10"),
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDialAddress refuses connections to non-public addresses. address is
+// the resolved ip:port being dialed.
+func (s *WebhookService) checkDialAddress(address string) error {
+	if s.AllowPrivateNetworks {
+		return nil
+	}
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddr(ap.Addr()) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+type CreateWebhookRequest struct {
+	URL           string   `json:"url"`
+	EventTypes    []string `json:"event_types"`
+	MinTradeValue *float64 `json:"min_trade_value"`
+}
+
+// Create stores a new webhook and returns it with its signing secret, which
+// is shown to the user once. Only admins may subscribe to ingestion.failed.
+func (s *WebhookService) Create(ctx context.Context, userID int64, isAdmin bool, req CreateWebhookRequest) (*models.Webhook, string, error) {
+	req.URL = strings.TrimSpace(req.URL)
+
+	v := &ValidationError{}
+	if msg := s.checkURL(req.URL); msg != "" {
+		v.add("url", msg)
+	}
+	if len(req.EventTypes) == 0 {
+		v.add("event_types", "at least one event type is required")
+	}
+	for _, t := range req.EventTypes {
+		if !slices.Contains(WebhookEventTypes, t) {
+			v.add("event_types", "must be among "+strings.Join(WebhookEventTypes, ", "))
+		} else if t == models.EventIngestionFailed && !isAdmin {
+			v.add("event_types", "ingestion.failed is only for admins")
+		}
+	}
+	if req.MinTradeValue != nil && *req.MinTradeValue < 0 {
+		v.add("min_trade_value", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		return nil, "", err
+	}
+
+	count, err := s.Repo.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= s.MaxWebhooksPerUser {
+		return nil, "", ErrTooManyWebhooks
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	types := slices.Clone(req.EventTypes)
+	slices.Sort(types)
+
+	w := &models.Webhook{
+		UserID:        userID,
+		URL:           req.URL,
+		Secret:        secret,
+		EventTypes:    slices.Compact(types),
+		MinTradeValue: req.MinTradeValue,
+	}
+	if err := s.Repo.Create(ctx, w); err != nil {
+		return nil, "", err
+	}
+	return w, secret, nil
+}
+
+// checkURL returns what is wrong with raw, or "" if it can be delivered to.
+func (s *WebhookService) checkURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "must be an absolute http or https URL"
+	}
+	if u.Scheme == "http" && s.RequireHTTPS {
+		return "must use https"
+	}
+	if u.User != nil {
+		return "must not contain credentials"
+	}
+	if !s.AllowPrivateNetworks {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+			return "must not point to a private or local network address"
+		}
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return "must not point to a private or local network address"
+		}
+	}
+	if len(raw) > 2000 {
+		return "must be at most 2000 characters"
+	}
+	return ""
+}
+
+func (s *WebhookService) GetByUserID(ctx context.Context, userID int64) ([]*models.Webhook, error) {
+	return s.Repo.GetByUserID(ctx, userID)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, userID int64, id int64) error {
+	deleted, err := s.Repo.Delete(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries lists the delivery log of one of the user's webhooks, newest
+// first by default. status, if set, narrows it to one delivery state.
+func (s *WebhookService) Deliveries(ctx context.Context, userID int64, webhookID int64, status string, req models.PageRequest) (*models.Page[*models.WebhookDelivery], error) {
+	w, err := s.Repo.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil || w.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	page, err := s.Repo.ListDeliveries(ctx, webhookID, status, req)
+	return page, listError(err)
+}
+
+// webhookPayload is the body of every delivery. Data is the event as
+// published on the stream.
+type webhookPayload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// DeliverDue sends the deliveries that are due and records the outcomes.
+// It is run on an interval; a delivery claimed by one run, or replica, is
+// leased long enough that no other run sends it meanwhile.
+func (s *WebhookService) DeliverDue(ctx context.Context) error {
+	lease := 2*s.Client.Timeout + 30*time.Second
+	due, err := s.Repo.ClaimDue(ctx, s.BatchSize, lease)
+	if err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, max(s.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, d *models.DueDelivery) {
+	attempt := s.send(ctx, d)
+	attempts := d.Attempts + 1
+
+	status := models.DeliveryPending
+	var next time.Time
+	switch {
+	case attempt.Error == "":
+		status = models.DeliveryDelivered
+	case attempts >= s.MaxAttempts:
+		status = models.DeliveryFailed
+	default:
+		next = time.Now().Add(s.retryDelay(attempts))
+	}
+
+	if status != models.DeliveryDelivered {
+		logger.Log.Warn("Webhook delivery failed",
+			zap.Int64("delivery_id", d.ID),
+			zap.Int64("webhook_id", d.WebhookID),
+			zap.Int("attempts", attempts),
+			zap.Int("status_code", attempt.StatusCode),
+			zap.String("error", attempt.Error),
+			zap.String("status", status),
+		)
+	}
+	if err := s.Repo.RecordAttempt(ctx, d.ID, attempt, status, next); err != nil {
+		logger.Log.Error("Failed to record webhook delivery attempt",
+			zap.Int64("delivery_id", d.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// retryDelay is how long to wait after the attempts-th failed attempt.
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.RetryBase
+	for i := 1; i < attempts && delay < s.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.RetryMax)
+}
+
+// send posts d once. Any 2xx response counts as delivered.
+func (s *WebhookService) send(ctx context.Context, d *models.DueDelivery) models.DeliveryAttempt {
+	body, err := json.Marshal(webhookPayload{
+		ID:        d.ID,
+		Type:      d.EventType,
+		CreatedAt: d.CreatedAt,
+		Data:      d.Payload,
+	})
+	if err != nil {
+		return models.DeliveryAttempt{Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return models.DeliveryAttempt{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NBAIsland-Webhooks/1")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.Secret, time.Now(), body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		logger.Log.Debug("Webhook request failed", zap.Int64("delivery_id", d.ID), zap.Error(err))
+		return models.DeliveryAttempt{Error: requestError(err)}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt := models.DeliveryAttempt{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("receiver responded %s", resp.Status)
+	}
+	return attempt
+}
+
+// requestError describes a failed request for the user-visible delivery log.
+// Dial errors are not passed on as they are; they would tell the user what
+// answers, and how, at addresses they choose.
+func requestError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		return "receiver address is not allowed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "could not reach the receiver"
+	}
+}
+
+// SignWebhook returns the signature header value for body sent at t.
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PruneDeliveries drops finished deliveries older than age from the log.
+func (s *WebhookService) PruneDeliveries(ctx context.Context, age time.Duration) error {
+	n, err := s.Repo.PruneDeliveries(ctx, time.Now().Add(-age))
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Pruned webhook deliveries", zap.Int64("deleted", n))
+	return nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbaisland/nbaisland/internal/models"
+	"github.com/nbaisland/nbaisland/internal/repository"
+)
+
+// fakeWebhookRepo embeds the interface so only the methods a test exercises
+// need implementing; anything else panics.
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+	due      []*models.DueDelivery
+	attempts []recordedAttempt
+}
+
+type recordedAttempt struct {
+	id      int64
+	attempt models.DeliveryAttempt
+	status  string
+	next    time.Time
+}
+
+func (r *fakeWebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.DueDelivery, error) {
+	due := r.due
+	r.due = nil
+	return due, nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt, status string, next time.Time) error {
+	r.attempts = append(r.attempts, recordedAttempt{id: id, attempt: attempt, status: status, next: next})
+	return nil
+}
+
+const testWebhookSecret = "test-secret"
+
+// newLocalWebhookService delivers to httptest receivers, which listen on
+// loopback.
+func newLocalWebhookService(repo *fakeWebhookRepo) *WebhookService {
+	s := NewWebhookService(repo)
+	s.AllowPrivateNetworks = true
+	return s
+}
+
+func dueTrade(url string, attempts int) *models.DueDelivery {
+	return &models.DueDelivery{
+		WebhookDelivery: &models.WebhookDelivery{
+			ID:        42,
+			WebhookID: 7,
+			EventType: models.EventTradeExecuted,
+			Payload:   json.RawMessage(`{"type":"trade.executed","player_id":3,"user_id":12}`),
+			Status:    models.DeliveryPending,
+			Attempts:  attempts,
+			CreatedAt: time.Now(),
+		},
+		URL:    url,
+		Secret: testWebhookSecret,
+	}
+}
+
+// verifySignature checks a signature header the way a receiver would.
+func verifySignature(header string, body []byte) bool {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Since(time.Unix(unix, 0)) > 5*time.Minute {
+		return false
+	}
+	want := SignWebhook(testWebhookSecret, time.Unix(unix, 0), body)
+	return hmac.Equal([]byte(want), []byte("t="+ts+",v1="+sig))
+}
+
+func TestWebhookDeliveryIsSignedAndRecorded(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepo{due: []*models.DueDelivery{dueTrade(receiver.URL, 0)}}
+	s := newLocalWebhookService(repo)
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+
+	r := <-received
+	if got := r.Header.Get(WebhookEventHeader); got != models.EventTradeExecuted {
+		t.Errorf("%s = %q", WebhookEventHeader, got)
+	}
+	if got := r.Header.Get(WebhookDeliveryHeader); got != "42" {
+		t.Errorf("%s = %q, want 42", WebhookDeliveryHeader, got)
+	}
+	if !verifySignature(r.Header.Get(WebhookSignatureHeader), body) {
+		t.Errorf("signature %q does not verify", r.Header.Get(WebhookSignatureHeader))
+	}
+	if verifySignature(r.Header.Get(WebhookSignatureHeader), append(body, ' ')) {
+		t.Error("signature verifies a tampered body")
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if payload.ID != 42 || payload.Type != models.EventTradeExecuted || !strings.Contains(string(payload.Data), `"player_id":3`) {
+		t.Errorf("payload = %s", body)
+	}
+
+	if len(repo.attempts) != 1 || repo.attempts[0].status != models.DeliveryDelivered || repo.attempts[0].attempt.StatusCode != http.StatusNoContent {
+		t.Errorf("recorded %+v, want one delivered attempt", repo.attempts)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepo{due: []*models.DueDelivery{dueTrade(receiver.URL, 2)}}
+	s := newLocalWebhookService(repo)
+	start := time.Now()
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+
+	if len(repo.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(repo.attempts))
+	}
+	got := repo.attempts[0]
+	if got.status != models.DeliveryPending || got.attempt.StatusCode != http.StatusInternalServerError || got.attempt.Error == "" {
+		t.Errorf("recorded %+v, want a pending retry with the 500", got)
+	}
+	// The third attempt failed, so the next waits 4x the base.
+	if wait := got.next.Sub(start); wait < 4*s.RetryBase || wait > 4*s.RetryBase+time.Minute {
+		t.Errorf("next attempt in %v, want about %v", wait, 4*s.RetryBase)
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	repo := &fakeWebhookRepo{}
+	s := newLocalWebhookService(repo)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	repo.due = []*models.DueDelivery{dueTrade(receiver.URL, s.MaxAttempts-1)}
+	if err := s.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].status != models.DeliveryFailed {
+		t.Errorf("recorded %+v, want one failed attempt", repo.attempts)
+	}
+}
+
+func TestWebhookDeliveryDoesNotFollowRedirects(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect was followed")
+	}))
+	defer elsewhere.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(elsewhere.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepo{due: []*models.DueDelivery{dueTrade(receiver.URL, 0)}}
+	if err := newLocalWebhookService(repo).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].status != models.DeliveryPending {
+		t.Errorf("recorded %+v, want a pending retry", repo.attempts)
+	}
+}
+
+func TestWebhookDeliveryRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("loopback receiver was reached")
+	}))
+	defer receiver.Close()
+	// Queued deliveries are not checked again before sending, so a name
+	// resolving to loopback must be caught by the dialer.
+	url := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+
+	repo := &fakeWebhookRepo{due: []*models.DueDelivery{dueTrade(receiver.URL, 0), dueTrade(url, 0)}}
+	if err := NewWebhookService(repo).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if len(repo.attempts) != 2 {
+		t.Fatalf("recorded %d attempts, want 2", len(repo.attempts))
+	}
+	for _, a := range repo.attempts {
+		if a.status != models.DeliveryPending || a.attempt.StatusCode != 0 {
+			t.Errorf("recorded %+v, want a pending retry without a response", a)
+		}
+		if a.attempt.Error != "receiver address is not allowed" {
+			t.Errorf("recorded error %q, want the generic refusal", a.attempt.Error)
+		}
+	}
+}
+
+func TestWebhookDialAddressCheck(t *testing.T) {
+	s := NewWebhookService(&fakeWebhookRepo{})
+	cases := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::1]:443", true},
+		{"127.0.0.1:8080", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+	for _, tc := range cases {
+		if err := s.checkDialAddress(tc.address); (err == nil) != tc.allowed {
+			t.Errorf("%s: allowed = %v, want %v", tc.address, err == nil, tc.allowed)
+		}
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	s := NewWebhookService(&fakeWebhookRepo{})
+	s.RequireHTTPS = true
+
+	trades := []string{models.EventTradeExecuted}
+	negative := -1.0
+	cases := []struct {
+		name  string
+		req   CreateWebhookRequest
+		field string
+	}{
+		{"plain http", CreateWebhookRequest{URL: "http://example.com/hook", EventTypes: trades}, "url"},
+		{"relative url", CreateWebhookRequest{URL: "/hook", EventTypes: trades}, "url"},
+		{"no events", CreateWebhookRequest{URL: "https://example.com/hook"}, "event_types"},
+		{"unknown event", CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: []string{"player.created"}}, "event_types"},
+		{"admin event", CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: []string{models.EventIngestionFailed}}, "event_types"},
+		{"negative trade value", CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: trades, MinTradeValue: &negative}, "min_trade_value"},
+		{"loopback", CreateWebhookRequest{URL: "https://127.0.0.1:8080/hook", EventTypes: trades}, "url"},
+		{"metadata", CreateWebhookRequest{URL: "https://169.254.169.254/latest", EventTypes: trades}, "url"},
+		{"localhost", CreateWebhookRequest{URL: "https://localhost/hook", EventTypes: trades}, "url"},
+	}
+	for _, tc := range cases {
+		_, _, err := s.Create(context.Background(), 1, false, tc.req)
+		if fieldError(err, tc.field) == "" {
+			t.Errorf("%s: err = %v, want %s flagged", tc.name, err, tc.field)
+		}
+	}
+}